@@ -5,59 +5,266 @@ import (
 	"fmt"
 	"github.com/diggs/connectrix/config"
 	"github.com/diggs/glog"
+	"sync"
 )
 
-var pubChannels map[string]PubChannel
-var subChannels map[string]SubChannel
+// pubChannelState is the config and args a publish channel was last started with
+type pubChannelState struct {
+	Config map[string]string
+	Args   []map[string]string
+}
+
+var pubChannelStates = struct {
+	sync.Mutex
+	m map[string]*pubChannelState
+}{m: make(map[string]*pubChannelState)}
+
+// startedSubChannels are the subscription channels that have been started, they are never stopped
+var startedSubChannels = struct {
+	sync.Mutex
+	m map[string]bool
+}{m: make(map[string]bool)}
+
+// NAMESPACE_ARG is added to the args a publish channel is started with, naming the namespace of the source the args
+// came from so the channel can create its events in that namespace
+const NAMESPACE_ARG string = "Namespace"
 
 // TODO move to config.go
-func getPubChannelArgs(channelName string) []map[string]string {
+func getPubChannelArgs(config_ *config.ConnectrixConfig, channelName string) []map[string]string {
 	var pubChannelArgs []map[string]string
-	sources := config.Get().Sources
-	for _, source := range sources {
-		if source.PubChannelName == channelName {
-			pubChannelArgs = append(pubChannelArgs, source.PubChannelArgs)
+	for _, namespace := range config_.AllNamespaces() {
+		for _, source := range namespace.Sources {
+			if source.PubChannelName == channelName {
+				// copy the args, the config must not be modified
+				args := make(map[string]string, len(source.PubChannelArgs)+1)
+				for key, val := range source.PubChannelArgs {
+					args[key] = val
+				}
+				args[NAMESPACE_ARG] = namespace.Name
+				pubChannelArgs = append(pubChannelArgs, args)
+			}
 		}
 	}
 	return pubChannelArgs
 }
 
-func LoadChannels(pub map[string]PubChannel, sub map[string]SubChannel) error {
+func makePubChannelState(config_ *config.ConnectrixConfig, channelName string) *pubChannelState {
+	return &pubChannelState{
+		Config: config_.Channels[channelName].Config,
+		Args:   getPubChannelArgs(config_, channelName),
+	}
+}
 
-	pubChannels = pub
-	subChannels = sub
+func equalArgs(a map[string]string, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, val := range a {
+		if otherVal, exists := b[key]; !exists || otherVal != val {
+			return false
+		}
+	}
+	return true
+}
 
-	glog.Info("Loading publishers...")
-	for key, val := range pubChannels {
-		go func(name string, channel PubChannel) {
-			glog.Infof("Starting publish channel %s...", name)
-			channel_config := config.Get().Channels[name].Config
-			channel_args := getPubChannelArgs(name)
-			err := channel.StartPubChannel(channel_config, channel_args)
+func (s *pubChannelState) equals(other *pubChannelState) bool {
+	if !equalArgs(s.Config, other.Config) || len(s.Args) != len(other.Args) {
+		return false
+	}
+	for i := range s.Args {
+		if !equalArgs(s.Args[i], other.Args[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func startPubChannel(name string, channel PubChannel, state *pubChannelState) {
+	go func() {
+		glog.Infof("Starting publish channel %s...", name)
+		err := channel.StartPubChannel(state.Config, state.Args)
+		if err != nil {
+			glog.Warningf("%s failed to start publish channel: %s", channel.Name(), err.Error())
+		}
+	}()
+}
+
+// usedChannels returns the names of the publish channels that are configured or used by a source, and the names
+// of the subscription channels that are configured or used by a route, in any namespace
+func usedChannels(config_ *config.ConnectrixConfig) (map[string]bool, map[string]bool) {
+
+	pub := make(map[string]bool)
+	sub := make(map[string]bool)
+	for _, namespace := range config_.AllNamespaces() {
+		for name := range namespace.Channels {
+			pub[name] = true
+			sub[name] = true
+		}
+		for _, source := range namespace.Sources {
+			pub[source.PubChannelName] = true
+		}
+		for _, route := range namespace.Routes {
+			sub[route.SubChannelName] = true
+		}
+	}
+	return pub, sub
+}
+
+// restartChangedPubChannels starts each publish channel that has become used, stops each one that is no longer
+// used, and restarts each one whose config or args differ from those it was started with
+func restartChangedPubChannels(config_ *config.ConnectrixConfig) {
+
+	pubChannelStates.Lock()
+	defer pubChannelStates.Unlock()
+
+	used, _ := usedChannels(config_)
+	for name, channel := range pubChannels() {
+		existing, running := pubChannelStates.m[name]
+		if !used[name] {
+			if running {
+				glog.Infof("Publish channel %s is no longer used, stopping...", name)
+				err := channel.StopPubChannel()
+				if err != nil {
+					glog.Warningf("%s failed to stop publish channel: %s", channel.Name(), err.Error())
+					continue
+				}
+				delete(pubChannelStates.m, name)
+			}
+			continue
+		}
+
+		state := makePubChannelState(config_, name)
+		if running {
+			if existing.equals(state) {
+				continue
+			}
+			glog.Infof("Config for publish channel %s changed, restarting...", name)
+			err := channel.StopPubChannel()
 			if err != nil {
-				glog.Warningf("%s failed to start publish channel: %s", channel.Name(), err.Error())
+				glog.Warningf("%s failed to stop publish channel: %s", channel.Name(), err.Error())
+				continue
 			}
-		}(key, val)
+		}
+		pubChannelStates.m[name] = state
+		startPubChannel(name, channel, state)
 	}
+}
+
+// startUsedSubChannels starts each subscription channel that is used and hasn't been started yet
+func startUsedSubChannels(config_ *config.ConnectrixConfig) {
 
-	glog.Info("Loading subscrbers...")
-	for key, val := range subChannels {
-		go func(name string, channel SubChannel) {
+	startedSubChannels.Lock()
+	defer startedSubChannels.Unlock()
+
+	_, used := usedChannels(config_)
+	for name, channel := range subChannels() {
+		if !used[name] || startedSubChannels.m[name] {
+			continue
+		}
+		startedSubChannels.m[name] = true
+		go func(name string, channel SubChannel, channelConfig map[string]string) {
 			glog.Infof("Starting subscription channel %s...", name)
-			err := channel.StartSubChannel(config.Get().Channels[name].Config)
+			err := channel.StartSubChannel(channelConfig)
 			if err != nil {
 				glog.Warningf("%s failed to start subscription channel: %s", channel.Name(), err.Error())
 			}
-		}(key, val)
+		}(name, channel, config_.Channels[name].Config)
 	}
+}
+
+// LoadChannels starts the registered channels that are configured or used by a source or route, and starts or stops
+// them as the config changes
+func LoadChannels() error {
+
+	config.OnChange(restartChangedPubChannels)
+	config.OnChange(startUsedSubChannels)
+
+	glog.Info("Loading publishers...")
+	restartChangedPubChannels(config.Get())
+
+	glog.Info("Loading subscribers...")
+	startUsedSubChannels(config.Get())
 
 	return nil
 }
 
-func GetSubChannel(channelName string) (SubChannel, error) {
-	if channel, exists := subChannels[channelName]; exists {
-		return channel, nil
-	} else {
-		return nil, errors.New(fmt.Sprintf("Unknown channel: %s", channelName))
+// WithDefaults returns a copy of args with the default value set for each arg that wasn't supplied.
+// An error is returned if a required arg has neither a value nor a default.
+func WithDefaults(channelArgs []*Arg, args map[string]string) (map[string]string, error) {
+	argsWithDefaults := make(map[string]string, len(args))
+	for key, val := range args {
+		argsWithDefaults[key] = val
+	}
+	for _, arg := range channelArgs {
+		if val, exists := argsWithDefaults[arg.Name]; !exists || val == "" {
+			if arg.Default == "" && arg.Required {
+				return nil, errors.New(fmt.Sprintf("Missing required arg: '%s'", arg.Name))
+			}
+			argsWithDefaults[arg.Name] = arg.Default
+		}
+	}
+	return argsWithDefaults, nil
+}
+
+// ValidatePubChannelArgs validates the args of a source in the namespace against the named publish channel, after
+// applying defaults. The namespace is passed to the channel as the NAMESPACE_ARG, as it is when the channel is started.
+func ValidatePubChannelArgs(channelName string, namespace string, args map[string]string) error {
+	channel, err := GetPubChannel(channelName)
+	if err != nil {
+		return err
+	}
+	argsWithDefaults, err := WithDefaults(channel.PubChannelArgs(), args)
+	if err != nil {
+		return err
+	}
+	argsWithDefaults[NAMESPACE_ARG] = namespace
+	return channel.ValidatePubChannelArgs(argsWithDefaults)
+}
+
+// IsSecretArg returns true if the named arg of the channel is secret, as either a publish or a subscription arg
+func IsSecretArg(channelName string, argName string) bool {
+	var args []*Arg
+	if pub, err := GetPubChannel(channelName); err == nil {
+		args = append(args, pub.PubChannelArgs()...)
+	}
+	if sub, err := GetSubChannel(channelName); err == nil {
+		args = append(args, sub.SubChannelArgs()...)
+	}
+	for _, arg := range args {
+		if arg.Name == argName && arg.Secret {
+			return true
+		}
+	}
+	return false
+}
+
+// PubChannelClaim returns what the args of a source claim from the named publish channel, after applying defaults.
+// An empty string is returned if the channel isn't an ExclusivePubChannel or the args don't claim anything.
+func PubChannelClaim(channelName string, args map[string]string) string {
+	channel, err := GetPubChannel(channelName)
+	if err != nil {
+		return ""
+	}
+	exclusive, isExclusive := channel.(ExclusivePubChannel)
+	if !isExclusive {
+		return ""
+	}
+	argsWithDefaults, err := WithDefaults(channel.PubChannelArgs(), args)
+	if err != nil {
+		return ""
+	}
+	return exclusive.PubChannelClaim(argsWithDefaults)
+}
+
+// ValidateSubChannelArgs validates args against the named subscription channel, after applying defaults.
+func ValidateSubChannelArgs(channelName string, args map[string]string) error {
+	channel, err := GetSubChannel(channelName)
+	if err != nil {
+		return err
+	}
+	argsWithDefaults, err := WithDefaults(channel.SubChannelArgs(), args)
+	if err != nil {
+		return err
 	}
+	return channel.ValidateSubChannelArgs(argsWithDefaults)
 }
@@ -0,0 +1,138 @@
+package channels
+
+import (
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestWithDefaults(t *testing.T) {
+
+	channelArgs := []*Arg{
+		&Arg{Name: "Required", Required: true},
+		&Arg{Name: "Optional", Default: "foo"},
+	}
+
+	args, err := WithDefaults(channelArgs, map[string]string{"Required": "bar"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"Required": "bar", "Optional": "foo"}, args)
+
+	_, err = WithDefaults(channelArgs, map[string]string{"Optional": "baz"})
+	assert.NotNil(t, err)
+}
+
+func TestPubChannelStateEquals(t *testing.T) {
+
+	state := &pubChannelState{
+		Config: map[string]string{"port": "9096"},
+		Args:   []map[string]string{{"IRC Server": "irc.freenode.net"}},
+	}
+
+	same := &pubChannelState{
+		Config: map[string]string{"port": "9096"},
+		Args:   []map[string]string{{"IRC Server": "irc.freenode.net"}},
+	}
+	assert.True(t, state.equals(same))
+
+	changedArgs := &pubChannelState{
+		Config: map[string]string{"port": "9096"},
+		Args:   []map[string]string{{"IRC Server": "irc.example.com"}},
+	}
+	assert.False(t, state.equals(changedArgs))
+
+	changedConfig := &pubChannelState{
+		Config: map[string]string{"port": "9097"},
+		Args:   []map[string]string{{"IRC Server": "irc.freenode.net"}},
+	}
+	assert.False(t, state.equals(changedConfig))
+
+	// nil and empty config are treated the same
+	assert.True(t, (&pubChannelState{}).equals(&pubChannelState{Config: map[string]string{}}))
+}
+
+// fakeChannel is a publish and subscription channel that does nothing
+type fakeChannel struct {
+	name string
+}
+
+func (ch *fakeChannel) Name() string                                              { return ch.name }
+func (*fakeChannel) Description() string                                          { return "A fake channel." }
+func (*fakeChannel) StartPubChannel(map[string]string, []map[string]string) error { return nil }
+func (*fakeChannel) StopPubChannel() error                                        { return nil }
+func (*fakeChannel) PubChannelArgs() []*Arg                                       { return []*Arg{&Arg{Name: "Pub"}} }
+func (*fakeChannel) ValidatePubChannelArgs(map[string]string) error               { return nil }
+func (*fakeChannel) PubChannelInfo(map[string]string) []*Info                     { return nil }
+func (*fakeChannel) StartSubChannel(map[string]string) error                      { return nil }
+func (*fakeChannel) SubChannelArgs() []*Arg                                       { return []*Arg{&Arg{Name: "Sub", Required: true}} }
+func (*fakeChannel) ValidateSubChannelArgs(map[string]string) error               { return nil }
+func (*fakeChannel) SubChannelInfo(map[string]string) []*Info                     { return nil }
+func (*fakeChannel) Drain(map[string]string, *event.Event, string) error          { return nil }
+
+// nameOnlyChannel is neither a publish nor a subscription channel
+type nameOnlyChannel struct{}
+
+func (nameOnlyChannel) Name() string        { return "name-only" }
+func (nameOnlyChannel) Description() string { return "Not a channel." }
+
+// unregister removes a channel registered by a test, so the test can be run again
+func unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.pub, name)
+	delete(registry.sub, name)
+}
+
+func TestRegistersChannels(t *testing.T) {
+
+	Register(&fakeChannel{name: "fake"})
+	defer unregister("fake")
+	assert.Contains(t, Registered(), "fake")
+
+	_, err := GetPubChannel("fake")
+	assert.Nil(t, err)
+	_, err = GetSubChannel("fake")
+	assert.Nil(t, err)
+	_, err = GetPubChannel("missing")
+	assert.NotNil(t, err)
+
+	summary, err := Summarize("fake")
+	assert.Nil(t, err)
+	assert.Equal(t, &Summary{
+		Name:        "fake",
+		Description: "A fake channel.",
+		Publish:     true,
+		Subscribe:   true,
+		PubArgs:     []*Arg{&Arg{Name: "Pub"}},
+		SubArgs:     []*Arg{&Arg{Name: "Sub", Required: true}},
+	}, summary)
+	_, err = Summarize("missing")
+	assert.NotNil(t, err)
+
+	assert.Panics(t, func() { Register(&fakeChannel{name: "fake"}) })
+	assert.Panics(t, func() { Register(&fakeChannel{}) })
+	assert.Panics(t, func() { Register(nameOnlyChannel{}) })
+	assert.NotContains(t, Registered(), "name-only")
+}
+
+func TestUsedChannels(t *testing.T) {
+
+	config_ := &config.ConnectrixConfig{
+		Channels: map[string]config.Channel{"http": config.Channel{Config: map[string]string{"port": "9096"}}},
+		Sources:  []*config.EventSource{&config.EventSource{Name: "GitHub", PubChannelName: "smtp"}},
+		Namespaces: []*config.Namespace{
+			&config.Namespace{
+				Name:   "ops",
+				Routes: []*config.Route{&config.Route{Name: "page", SubChannelName: "irc"}},
+			},
+		},
+	}
+
+	pub, sub := usedChannels(config_)
+	assert.True(t, pub["http"])
+	assert.True(t, pub["smtp"])
+	assert.False(t, pub["irc"])
+	assert.True(t, sub["http"])
+	assert.True(t, sub["irc"])
+	assert.False(t, sub["smtp"])
+}
@@ -1,13 +1,39 @@
 package http
 
+import (
+	"github.com/diggs/connectrix/channels"
+	"net"
+	"sync"
+)
+
 const (
 	URL_ARG              string = "URL"
 	HEADERS              string = "Headers"
 	SELF_SIGNED_CERT_ARG string = "Self Signed Cert"
 	NAMESPACE_HEADER     string = "Connectrix-Namespace"
+	TOKEN_HEADER         string = "Connectrix-Token"
+
+	// config keys
+	PORT_CONFIG          string = "port"
+	PUBLIC_URL_CONFIG    string = "public_url"
+	REQUIRE_TOKEN_CONFIG string = "require_token"
+
+	// args accepted by PubChannelInfo
+	SOURCE_INFO_ARG    string = "Source"
+	NAMESPACE_INFO_ARG string = "Namespace"
 )
 
 type HttpChannel struct {
+	// lock guards listener and config
+	lock sync.Mutex
+	// listener is the listener the publish channel is serving requests on, nil when stopped
+	listener net.Listener
+	// config is the channel config the publish channel was started with
+	config map[string]string
+}
+
+func init() {
+	channels.Register(&HttpChannel{})
 }
 
 func (*HttpChannel) Name() string {
@@ -1,17 +1,34 @@
 package http
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
 	"github.com/diggs/connectrix/events"
+	"github.com/diggs/connectrix/events/event"
 	"github.com/diggs/glog"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
+)
+
+const (
+	HEADER_HINT string = "header:"
+	QUERY_HINT  string = "query:"
+
+	REDACTED string = "<redacted>"
 )
 
 var ignoreHeadersInHints map[string]int
 
+// eventCreatedResponse is returned to the caller once an event has been stored
+type eventCreatedResponse struct {
+	ID int `json:"id"`
+}
+
 func (*HttpChannel) PubChannelArgs() []*channels.Arg {
 	return nil
 }
@@ -20,9 +37,46 @@ func (*HttpChannel) ValidatePubChannelArgs(args map[string]string) error {
 	return nil
 }
 
-func (*HttpChannel) PubChannelInfo(args map[string]string) []*channels.Info {
-	// TODO we want to return the full url (including secret) that can be pushed to
-	return nil
+// PubChannelInfo returns the URL events for a source should be sent to, which includes the ingest token for the
+// source and namespace. Pass the source and namespace as the Source and Namespace args. Tokens aren't generated
+// here, so until one has been generated with the management API a Token with no value explains how to generate it.
+func (ch *HttpChannel) PubChannelInfo(args map[string]string) []*channels.Info {
+
+	source := args[SOURCE_INFO_ARG]
+	if source == "" {
+		return nil
+	}
+	namespace := args[NAMESPACE_INFO_ARG]
+	if namespace == "" {
+		namespace = config.DEFAULT_NAMESPACE
+	}
+
+	token, err := events.FindIngestToken(namespace, source)
+	if err != nil {
+		glog.Warningf("Unable to get ingest token for %s in namespace %s: %v", source, namespace, err)
+		return nil
+	}
+	if token == nil {
+		// tokens are only generated on request, so explain how to get one rather than returning nothing
+		return []*channels.Info{
+			&channels.Info{Name: "Token", Description: fmt.Sprintf("%s doesn't have an ingest token yet, generate one with POST /sources/%s/tokens?namespace=%s on the management API and then ask for the URL again", source, source, namespace)},
+		}
+	}
+
+	return []*channels.Info{
+		&channels.Info{Name: "URL", Description: "The URL to send events to, e.g. as the webhook URL", Value: fmt.Sprintf("%s/events/%s", ch.publicUrl(), token.Token)},
+		&channels.Info{Name: "Token", Description: fmt.Sprintf("The ingest token, which can also be sent in the %s header", TOKEN_HEADER), Value: token.Token},
+	}
+}
+
+// publicUrl returns the URL the channel can be reached at from outside, which defaults to localhost
+func (ch *HttpChannel) publicUrl() string {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if publicUrl := ch.config[PUBLIC_URL_CONFIG]; publicUrl != "" {
+		return strings.TrimRight(publicUrl, "/")
+	}
+	return fmt.Sprintf("http://localhost:%s", ch.config[PORT_CONFIG])
 }
 
 func (ch *HttpChannel) StartPubChannel(config map[string]string, pubChannelArgs []map[string]string) error {
@@ -37,21 +91,61 @@ func (ch *HttpChannel) StartPubChannel(config map[string]string, pubChannelArgs
 		"Connection":       0,
 		"Origin":           0,
 		"X-Requested-With": 0,
+		TOKEN_HEADER:       0,
 	}
 
-	port := config["port"]
-	http.HandleFunc("/events", ch.handleWebRequest)
+	port := config[PORT_CONFIG]
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return err
+	}
+	ch.lock.Lock()
+	ch.listener = listener
+	ch.config = config
+	ch.lock.Unlock()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/events", ch.handleWebRequest)
+	mux.HandleFunc("/events/", ch.handleWebRequest)
 	glog.Infof("Starting HTTP channel on %s...", port)
-	return http.ListenAndServe(fmt.Sprintf(":%s", port), LogHandler(http.DefaultServeMux))
+	err = http.Serve(listener, LogHandler(mux))
+
+	// serve always returns an error, ignore it if the listener was closed by StopPubChannel
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.listener != listener {
+		return nil
+	}
+	return err
+}
+
+func (ch *HttpChannel) StopPubChannel() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.listener == nil {
+		return nil
+	}
+	err := ch.listener.Close()
+	ch.listener = nil
+	return err
 }
 
+// LogHandler logs each request before handling it. Only the path is logged, with any ingest token redacted.
 func LogHandler(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		glog.Debugf("HTTP %s to %s", r.Method, r.URL)
+		glog.Debugf("HTTP %s to %s", r.Method, redactPath(r.URL.Path))
 		handler.ServeHTTP(w, r)
 	})
 }
 
+// redactPath hides the ingest token in /events/<token> paths, as anyone who has it can send events to its source
+func redactPath(path string) string {
+	if strings.HasPrefix(path, "/events/") && path != "/events/" {
+		return "/events/" + REDACTED
+	}
+	return path
+}
+
 func (ch *HttpChannel) handleWebRequest(w http.ResponseWriter, r *http.Request) {
 
 	if r.Method != "POST" {
@@ -65,19 +159,54 @@ func (ch *HttpChannel) handleWebRequest(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	namespace, err := getNamespace(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
-	}
+	var id int
+	token := getToken(r)
+	if token != "" {
+		// the token determines the namespace and source
+		id, err = events.ParseAndCreateEventWithToken(ch.Name(), token, &body, getHints(r), verifier(r, body))
+	} else {
+		ch.lock.Lock()
+		requireToken := ch.config[REQUIRE_TOKEN_CONFIG] == "true"
+		ch.lock.Unlock()
+		if requireToken {
+			http.Error(w, fmt.Sprintf("An ingest token is required, send events to /events/<token> or set the '%s' header.", TOKEN_HEADER), http.StatusUnauthorized)
+			return
+		}
 
-	_, err = events.ParseAndCreateEventFromChannel(ch.Name(), namespace, &body, getHints(r))
+		var namespace string
+		namespace, err = getNamespace(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// anyone could name another tenant's namespace, so only the token can put an event in one
+		if namespace != config.DEFAULT_NAMESPACE {
+			http.Error(w, fmt.Sprintf("An ingest token is required for events in namespace %s.", namespace), http.StatusUnauthorized)
+			return
+		}
+		id, err = events.ParseAndCreateEventFromChannel(ch.Name(), namespace, &body, getHints(r), verifier(r, body))
+	}
 	if err != nil {
+		if _, failedVerification := err.(*verificationError); failedVerification || err == events.ErrUnknownIngestToken {
+			glog.Warningf("Rejected request from %s: %v", r.RemoteAddr, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	w.WriteHeader(204)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(&eventCreatedResponse{ID: id})
+}
+
+// getToken returns the ingest token from the path (/events/<token>) or the token header, if there is one
+func getToken(r *http.Request) string {
+	if token := strings.TrimPrefix(r.URL.Path, "/events/"); token != r.URL.Path && token != "" {
+		return token
+	}
+	return r.Header.Get(TOKEN_HEADER)
 }
 
 func getNamespace(r *http.Request) (string, error) {
@@ -96,15 +225,16 @@ func getNamespace(r *http.Request) (string, error) {
 	return "", errors.New(fmt.Sprintf("Unable to determine event namespace. Ensure '?namespace=' query param or '%s' header is set.", NAMESPACE_HEADER))
 }
 
-func getHints(r *http.Request) []string {
-	hints := []string{}
+// getHints returns a header:<name> hint for each header and a query:<name> hint for each query param
+func getHints(r *http.Request) []event.Hint {
+	hints := []event.Hint{}
 	for key, val := range r.Header {
 		if _, exists := ignoreHeadersInHints[key]; !exists {
-			hints = append(hints, fmt.Sprintf("%s:%s", key, val[0]))
+			hints = append(hints, event.Hint{Key: HEADER_HINT + key, Value: val[0], Text: fmt.Sprintf("%s:%s", key, val[0])})
 		}
 	}
 	for key, val := range r.URL.Query() {
-		hints = append(hints, fmt.Sprintf("%s=%s", key, val[0]))
+		hints = append(hints, event.Hint{Key: QUERY_HINT + key, Value: val[0], Text: fmt.Sprintf("%s=%s", key, val[0])})
 	}
 	return hints
 }
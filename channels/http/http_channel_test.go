@@ -2,6 +2,9 @@ package http
 
 import (
 	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
@@ -32,3 +35,45 @@ func TestSubChannelArgValidation(t *testing.T) {
 	err = httpChannel.ValidateSubChannelArgs(map[string]string{"URL": "http://foo.com", "Self Signed Cert": "imnotabool"})
 	assert.NotNil(t, err)
 }
+
+func TestGetToken(t *testing.T) {
+
+	r, _ := http.NewRequest("POST", "http://localhost/events/abc123", nil)
+	assert.Equal(t, "abc123", getToken(r))
+
+	r, _ = http.NewRequest("POST", "http://localhost/events", nil)
+	assert.Equal(t, "", getToken(r))
+
+	r.Header.Set(TOKEN_HEADER, "def456")
+	assert.Equal(t, "def456", getToken(r))
+}
+
+func TestRedactPath(t *testing.T) {
+	assert.Equal(t, "/events/<redacted>", redactPath("/events/abc123"))
+	assert.Equal(t, "/events/<redacted>", redactPath("/events/abc123/extra"))
+	assert.Equal(t, "/events", redactPath("/events"))
+	assert.Equal(t, "/events/", redactPath("/events/"))
+}
+
+func TestPublicUrl(t *testing.T) {
+	channel := &HttpChannel{config: map[string]string{PORT_CONFIG: "9096"}}
+	assert.Equal(t, "http://localhost:9096", channel.publicUrl())
+
+	channel.config[PUBLIC_URL_CONFIG] = "https://hooks.example.com/"
+	assert.Equal(t, "https://hooks.example.com", channel.publicUrl())
+}
+
+func TestNamespaceRequiresToken(t *testing.T) {
+
+	channel := &HttpChannel{config: map[string]string{}}
+	r, _ := http.NewRequest("POST", "http://localhost/events?namespace=team-a", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	channel.handleWebRequest(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r, _ = http.NewRequest("POST", "http://localhost/events", strings.NewReader("{}"))
+	r.Header.Set(NAMESPACE_HEADER, "team-a")
+	w = httptest.NewRecorder()
+	channel.handleWebRequest(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,33 @@
+package http
+
+import (
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/verification"
+	"net/http"
+	"time"
+)
+
+// verificationError is returned when a request fails its source's verification
+type verificationError struct {
+	source string
+	reason string
+}
+
+func (e *verificationError) Error() string {
+	return fmt.Sprintf("Request failed verification for source %s: %s", e.source, e.reason)
+}
+
+// verifier returns a function that checks the request against the verification configured for its event source
+func verifier(r *http.Request, body []byte) func(*config.EventSource) error {
+	return func(eventSource *config.EventSource) error {
+		if eventSource.Verify == nil {
+			return nil
+		}
+		reason := verification.Verify(eventSource.Verify, r.Header, body, time.Now())
+		if reason != "" {
+			return &verificationError{source: eventSource.Name, reason: reason}
+		}
+		return nil
+	}
+}
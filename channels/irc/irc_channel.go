@@ -2,6 +2,7 @@ package irc
 
 import (
 	"fmt"
+	"github.com/diggs/connectrix/channels"
 	"github.com/diggs/glog"
 	irc "github.com/fluffle/goirc/client"
 	"sync"
@@ -21,6 +22,15 @@ var connections = struct {
 }{m: make(map[string]*irc.Conn)}
 
 type IrcChannel struct {
+	// watchers holds the handlers watching for messages to publish, keyed by connection key
+	watchers struct {
+		sync.Mutex
+		m map[string]irc.Remover
+	}
+}
+
+func init() {
+	channels.Register(&IrcChannel{})
 }
 
 func (*IrcChannel) Name() string {
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
 	"github.com/diggs/connectrix/events"
+	"github.com/diggs/connectrix/events/event"
 	"github.com/diggs/glog"
 	irc "github.com/fluffle/goirc/client"
 	"regexp"
@@ -45,15 +47,34 @@ func (ch *IrcChannel) StartPubChannel(channelArgs map[string]string, pubChannelA
 	return nil
 }
 
+// StopPubChannel stops watching for messages. Connections are left open as they are shared with the sub channel.
+func (ch *IrcChannel) StopPubChannel() error {
+	ch.watchers.Lock()
+	defer ch.watchers.Unlock()
+	for key, watcher := range ch.watchers.m {
+		glog.Debugf("No longer watching %s", key)
+		watcher.Remove()
+	}
+	ch.watchers.m = nil
+	return nil
+}
+
 func (ch *IrcChannel) connectAndWatch(args map[string]string) {
 
+	// events are created in the namespace of the source the args came from
+	namespace := args[channels.NAMESPACE_ARG]
+	if namespace == "" {
+		namespace = config.DEFAULT_NAMESPACE
+	}
+
 	connection, err := ch.findOrCreateConnection(args[IRC_SERVER], args[SERVER_PASSWORD], args[IRC_CHANNEL], args[NICKNAME])
 	if err != nil {
 		// TODO: Want to add some retrying here...
 		glog.Debugf("Unable to establish connection to %s:%s: %v", args[IRC_SERVER], args[IRC_CHANNEL], err)
+		return
 	}
 
-	connection.HandleFunc(irc.PRIVMSG, func(conn *irc.Conn, line *irc.Line) {
+	watcher := connection.HandleFunc(irc.PRIVMSG, func(conn *irc.Conn, line *irc.Line) {
 
 		senderRegex := regexp.MustCompile("^(.+)!~")
 		senderMatches := senderRegex.FindStringSubmatch(line.Src)
@@ -75,22 +96,38 @@ func (ch *IrcChannel) connectAndWatch(args map[string]string) {
 		rawBytes := []byte(line.Raw)
 		hints := ch.getHints(args, m)
 
-		// TODO: How to support namespaces for multitenancy? Could base it on server/channel/nick tuple
-		_, err = events.CreateEventFromChannel(ch.Name(), "0", m, &rawBytes, hints)
+		_, err = events.CreateEventFromChannel(ch.Name(), namespace, m, &rawBytes, hints)
 		if err != nil {
 			ch.handleIrcError(args[IRC_CHANNEL], conn, line, err)
 			return
 		}
 	})
+
+	ch.watchers.Lock()
+	defer ch.watchers.Unlock()
+	if ch.watchers.m == nil {
+		ch.watchers.m = make(map[string]irc.Remover)
+	}
+	// the same connection can be watched for more than one namespace
+	ch.watchers.m[namespace+"/"+makeConnectionKey(args[IRC_SERVER], args[IRC_CHANNEL], args[NICKNAME])] = watcher
 }
 
-func (ch *IrcChannel) getHints(args map[string]string, msg *ircMessage) []string {
+// getHints returns irc:server, irc:channel, irc:nickname, irc:connection (server:channel:nickname), irc:command
+// (the first word of the message) and irc:sender hints
+func (ch *IrcChannel) getHints(args map[string]string, msg *ircMessage) []event.Hint {
 	serverTuple := strings.Join([]string{args[IRC_SERVER], args[IRC_CHANNEL], args[NICKNAME]}, ":")
-	return []string{args[IRC_SERVER], args[IRC_CHANNEL], args[NICKNAME], serverTuple, msg.Args["0"]}
+	return []event.Hint{
+		{Key: "irc:server", Value: args[IRC_SERVER], Text: args[IRC_SERVER]},
+		{Key: "irc:channel", Value: args[IRC_CHANNEL], Text: args[IRC_CHANNEL]},
+		{Key: "irc:nickname", Value: args[NICKNAME], Text: args[NICKNAME]},
+		{Key: "irc:connection", Value: serverTuple, Text: serverTuple},
+		{Key: "irc:command", Value: msg.Args["0"], Text: msg.Args["0"]},
+		{Key: "irc:sender", Value: msg.Sender},
+	}
 }
 
 func (ch *IrcChannel) handleIrcError(ircChannel string, connection *irc.Conn, line *irc.Line, err error) {
 	errText := fmt.Sprintf("Unable to handle line: %v - %v", line, err)
-	glog.Warningf(errText)
+	glog.Warning(errText)
 	connection.Privmsg(ircChannel, errText)
 }
@@ -16,6 +16,7 @@ func (*IrcChannel) SubChannelArgs() []*channels.Arg {
 			Name:        SERVER_PASSWORD,
 			Description: "The password to connect to the IRC server with.",
 			Default:     "",
+			Secret:      true,
 		},
 		&channels.Arg{
 			Name:        IRC_CHANNEL,
@@ -0,0 +1,135 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/connectrix/kv"
+	"strconv"
+	"strings"
+)
+
+const (
+	KEY_ARG       string = "Key"
+	VALUE_ARG     string = "Value"
+	OPERATION_ARG string = "Operation"
+	TTL_ARG       string = "TTL"
+	BY_ARG        string = "By"
+
+	OPERATION_SET       string = "set"
+	OPERATION_INCREMENT string = "increment"
+	OPERATION_DELETE    string = "delete"
+)
+
+// KvChannel is a subscription channel that writes to the key/value store of the event's namespace, so routes can
+// remember things about the events they see
+type KvChannel struct{}
+
+func init() {
+	channels.Register(&KvChannel{})
+}
+
+func (*KvChannel) Name() string {
+	return "kv"
+}
+
+func (*KvChannel) Description() string {
+	return "The KV channel stores values in the key/value store of the event's namespace."
+}
+
+func (*KvChannel) SubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        KEY_ARG,
+			Description: "The key to write to.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        VALUE_ARG,
+			Description: "The value to store when setting a value. Defaults to the event content.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        OPERATION_ARG,
+			Description: "What to do to the value: set, increment or delete.",
+			Default:     OPERATION_SET,
+		},
+		&channels.Arg{
+			Name:        TTL_ARG,
+			Description: "How long to keep the value for, e.g. 24h. Values are kept until they are deleted by default.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        BY_ARG,
+			Description: "The amount to add when incrementing a value.",
+			Default:     "1",
+		},
+	}
+}
+
+// ValidateSubChannelArgs checks the operation, by and ttl args. Args are templated per event before Drain checks
+// them again, so values containing a template aren't checked here.
+func (*KvChannel) ValidateSubChannelArgs(args map[string]string) error {
+
+	isTemplate := func(name string) bool {
+		return strings.Contains(args[name], "{{")
+	}
+
+	switch {
+	case isTemplate(OPERATION_ARG):
+	case args[OPERATION_ARG] == OPERATION_SET, args[OPERATION_ARG] == OPERATION_DELETE:
+	case args[OPERATION_ARG] == OPERATION_INCREMENT:
+		if _, err := strconv.ParseInt(args[BY_ARG], 10, 64); err != nil && !isTemplate(BY_ARG) {
+			return errors.New(fmt.Sprintf("Invalid %s: %s", BY_ARG, args[BY_ARG]))
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unknown operation '%s', expected %s, %s or %s", args[OPERATION_ARG], OPERATION_SET, OPERATION_INCREMENT, OPERATION_DELETE))
+	}
+
+	if isTemplate(TTL_ARG) {
+		return nil
+	}
+	_, err := kv.ParseTTL(args[TTL_ARG])
+	return err
+}
+
+func (*KvChannel) SubChannelInfo(map[string]string) []*channels.Info {
+	return nil
+}
+
+func (*KvChannel) StartSubChannel(config map[string]string) error {
+	return nil
+}
+
+func (*KvChannel) Drain(args map[string]string, event *event.Event, content string) error {
+
+	// args are templated per event, so they are checked again here
+	ttl, err := kv.ParseTTL(args[TTL_ARG])
+	if err != nil {
+		return err
+	}
+
+	switch args[OPERATION_ARG] {
+	case OPERATION_INCREMENT:
+		by := int64(1)
+		if args[BY_ARG] != "" {
+			by, err = strconv.ParseInt(args[BY_ARG], 10, 64)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Invalid %s: %s", BY_ARG, args[BY_ARG]))
+			}
+		}
+		_, err = kv.Increment(event.Namespace, args[KEY_ARG], by, ttl)
+		return err
+	case OPERATION_DELETE:
+		return kv.Delete(event.Namespace, args[KEY_ARG])
+	case OPERATION_SET, "":
+		value := args[VALUE_ARG]
+		if value == "" {
+			value = content
+		}
+		return kv.Set(event.Namespace, args[KEY_ARG], value, ttl)
+	default:
+		return errors.New(fmt.Sprintf("Unknown operation '%s'", args[OPERATION_ARG]))
+	}
+}
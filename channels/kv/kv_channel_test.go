@@ -0,0 +1,60 @@
+package kv
+
+import (
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/connectrix/kv"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSubChannelArgValidation(t *testing.T) {
+
+	kvChannel := KvChannel{}
+	assert.Nil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "last_deploy", "Operation": "set", "TTL": "24h"}))
+	assert.Nil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "failures", "Operation": "increment", "By": "2"}))
+	assert.Nil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "failures", "Operation": "delete"}))
+
+	assert.NotNil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "failures", "Operation": "increment", "By": "two"}))
+	assert.NotNil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "failures", "Operation": "append"}))
+	assert.NotNil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "last_deploy", "Operation": "set", "TTL": "tomorrow"}))
+
+	// templated args are checked once they have been templated for an event
+	assert.Nil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "status", "Operation": "{{if .failed}}increment{{else}}delete{{end}}", "By": "1"}))
+	assert.Nil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "failures", "Operation": "increment", "By": "{{.count}}"}))
+	assert.Nil(t, kvChannel.ValidateSubChannelArgs(map[string]string{"Key": "last_deploy", "Operation": "set", "TTL": "{{.ttl}}"}))
+	assert.NotNil(t, kvChannel.Drain(map[string]string{"Key": "last_deploy", "Operation": "set", "TTL": "tomorrow"}, &event.Event{}, ""))
+}
+
+func TestDrainWritesToTheEventNamespace(t *testing.T) {
+
+	defer kv.UseStore(kv.UseStore(kv.NewMemoryStore()))
+	kvChannel := KvChannel{}
+	teamA := &event.Event{Namespace: "team-a"}
+	teamB := &event.Event{Namespace: "team-b"}
+
+	assert.Nil(t, kvChannel.Drain(map[string]string{"Key": "last_deploy", "Operation": "set"}, teamA, "abc123"))
+	assert.Nil(t, kvChannel.Drain(map[string]string{"Key": "last_deploy", "Operation": "set", "Value": "def456"}, teamB, "ignored"))
+	assert.Nil(t, kvChannel.Drain(map[string]string{"Key": "failures", "Operation": "increment", "By": "2"}, teamA, ""))
+	assert.Nil(t, kvChannel.Drain(map[string]string{"Key": "failures", "Operation": "increment"}, teamA, ""))
+
+	value, err := kv.Get("team-a", "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", value.Value)
+	value, err = kv.Get("team-b", "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "def456", value.Value)
+	value, err = kv.Get("team-a", "failures")
+	assert.Nil(t, err)
+	assert.Equal(t, "3", value.Value)
+	value, err = kv.Get("team-b", "failures")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	assert.Nil(t, kvChannel.Drain(map[string]string{"Key": "last_deploy", "Operation": "delete"}, teamA, ""))
+	value, err = kv.Get("team-a", "last_deploy")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+	value, err = kv.Get("team-b", "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "def456", value.Value)
+}
@@ -0,0 +1,122 @@
+package mailbox
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// client is a connection to a mailbox that has been logged in to
+type client interface {
+	// unseen returns the ids of the messages that haven't been processed yet, oldest first
+	unseen() ([]string, error)
+	// fetch returns the message with the id, without marking it as processed
+	fetch(id string) ([]byte, error)
+	// done marks the message with the id as processed, deleting it if remove is true
+	done(id string, remove bool) error
+	// close logs out, completing any deletes, and closes the connection
+	close() error
+}
+
+// conn is a line based connection to a mail server, which can be upgraded to TLS part way through
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func newConn(c net.Conn) *conn {
+	return &conn{Conn: c, reader: bufio.NewReader(c)}
+}
+
+// readLine reads a line without its line ending, extending the deadline first
+func (c *conn) readLine() (string, error) {
+	c.SetDeadline(time.Now().Add(TIMEOUT))
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) >= 2 && line[len(line)-2] == '\r' {
+		return line[:len(line)-2], nil
+	}
+	return line[:len(line)-1], nil
+}
+
+func (c *conn) writeLine(line string) error {
+	c.SetDeadline(time.Now().Add(TIMEOUT))
+	_, err := c.Write([]byte(line + "\r\n"))
+	return err
+}
+
+// startTLS upgrades the connection to TLS once the server has agreed to it
+func (c *conn) startTLS(tlsConfig *tls.Config) error {
+	tlsConn := tls.Client(c.Conn, tlsConfig)
+	tlsConn.SetDeadline(time.Now().Add(TIMEOUT))
+	err := tlsConn.Handshake()
+	if err != nil {
+		return err
+	}
+	c.Conn = tlsConn
+	c.reader = bufio.NewReader(tlsConn)
+	return nil
+}
+
+// defaultPort returns the standard port for the protocol and security
+func defaultPort(protocol string, security string) string {
+	switch {
+	case protocol == PROTOCOL_POP3 && security == SECURITY_TLS:
+		return "995"
+	case protocol == PROTOCOL_POP3:
+		return "110"
+	case security == SECURITY_TLS:
+		return "993"
+	}
+	return "143"
+}
+
+// dial connects and logs in to the mailbox described by the args. seen is the ids of messages already processed,
+// which is only used by POP3.
+func dial(args map[string]string, seen map[string]bool) (client, error) {
+
+	host := args[HOST_ARG]
+	port := args[PORT_ARG]
+	if port == "" {
+		port = defaultPort(args[PROTOCOL_ARG], args[SECURITY_ARG])
+	}
+	selfSignedCert, _ := strconv.ParseBool(args[SELF_SIGNED_CERT_ARG])
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: selfSignedCert}
+	address := net.JoinHostPort(host, port)
+
+	var c net.Conn
+	var err error
+	if args[SECURITY_ARG] == SECURITY_TLS {
+		c, err = tls.DialWithDialer(&net.Dialer{Timeout: TIMEOUT}, "tcp", address, tlsConfig)
+	} else {
+		c, err = net.DialTimeout("tcp", address, TIMEOUT)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var mailbox client
+	switch args[PROTOCOL_ARG] {
+	case PROTOCOL_POP3:
+		mailbox, err = loginPop3(newConn(c), args[SECURITY_ARG] == SECURITY_STARTTLS, tlsConfig, args[USERNAME_ARG], args[PASSWORD_ARG], seen)
+	case PROTOCOL_IMAP, "":
+		folder := args[FOLDER_ARG]
+		if folder == "" {
+			folder = DEFAULT_FOLDER
+		}
+		mailbox, err = loginImap(newConn(c), args[SECURITY_ARG] == SECURITY_STARTTLS, tlsConfig, args[USERNAME_ARG], args[PASSWORD_ARG], folder)
+	default:
+		err = errors.New(fmt.Sprintf("Unknown protocol '%s'", args[PROTOCOL_ARG]))
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return mailbox, nil
+}
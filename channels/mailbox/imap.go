@@ -0,0 +1,172 @@
+package mailbox
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// literalRegex matches the size of a literal at the end of a response line, e.g. * 1 FETCH (UID 7 BODY[] {1024}
+var literalRegex = regexp.MustCompile(`\{(\d+)\}$`)
+
+// imapClient is just enough of an IMAP client to read and flag the messages in a folder
+type imapClient struct {
+	conn *conn
+	tag  int
+	// deleted is true once a message has been flagged for deletion, so the folder is expunged on close
+	deleted bool
+}
+
+// imapResponse is the untagged responses to a command and the literals they contained
+type imapResponse struct {
+	lines    []string
+	literals [][]byte
+}
+
+func loginImap(c *conn, startTLS bool, tlsConfig *tls.Config, username string, password string, folder string) (*imapClient, error) {
+
+	greeting, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		return nil, errors.New(fmt.Sprintf("IMAP server refused the connection: %s", greeting))
+	}
+
+	client := &imapClient{conn: c}
+	if startTLS {
+		_, err = client.command("STARTTLS")
+		if err != nil {
+			return nil, err
+		}
+		err = c.startTLS(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !strings.HasPrefix(greeting, "* PREAUTH") {
+		_, err = client.command("LOGIN %s %s", quote(username), quote(password))
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = client.command("SELECT %s", quote(folder))
+	if err != nil {
+		return nil, err
+	}
+
+	return client, nil
+}
+
+// quote returns the string as an IMAP quoted string
+func quote(str string) string {
+	return `"` + strings.Replace(strings.Replace(str, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
+
+// command sends a command and reads its responses up to the tagged response, which is returned as an error unless
+// it is OK
+func (c *imapClient) command(format string, args ...interface{}) (*imapResponse, error) {
+
+	c.tag++
+	tag := fmt.Sprintf("c%d", c.tag)
+	err := c.conn.writeLine(tag + " " + fmt.Sprintf(format, args...))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &imapResponse{}
+	for {
+		line, err := c.conn.readLine()
+		if err != nil {
+			return nil, err
+		}
+
+		// read any literals, the response carries on after each one
+		for {
+			match := literalRegex.FindStringSubmatch(line)
+			if match == nil {
+				break
+			}
+			size, err := strconv.Atoi(match[1])
+			if err != nil || size > MAX_MESSAGE_SIZE {
+				return nil, errors.New(fmt.Sprintf("IMAP server sent a literal of %s bytes, the most allowed is %d", match[1], MAX_MESSAGE_SIZE))
+			}
+			literal := make([]byte, size)
+			_, err = io.ReadFull(c.conn.reader, literal)
+			if err != nil {
+				return nil, err
+			}
+			response.literals = append(response.literals, literal)
+			rest, err := c.conn.readLine()
+			if err != nil {
+				return nil, err
+			}
+			line += rest
+		}
+
+		if strings.HasPrefix(line, tag+" ") {
+			status := strings.TrimPrefix(line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return nil, errors.New(fmt.Sprintf("IMAP %s failed: %s", strings.SplitN(format, " ", 2)[0], status))
+			}
+			return response, nil
+		}
+		response.lines = append(response.lines, line)
+	}
+}
+
+func (c *imapClient) unseen() ([]string, error) {
+
+	response, err := c.command("UID SEARCH UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, line := range response.lines {
+		if strings.HasPrefix(line, "* SEARCH") {
+			ids = append(ids, strings.Fields(strings.TrimPrefix(line, "* SEARCH"))...)
+		}
+	}
+	return ids, nil
+}
+
+func (c *imapClient) fetch(id string) ([]byte, error) {
+
+	// BODY.PEEK leaves the message unread, it is only marked as read once it has been processed
+	response, err := c.command("UID FETCH %s (BODY.PEEK[])", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(response.literals) == 0 {
+		return nil, errors.New(fmt.Sprintf("IMAP server didn't return message %s", id))
+	}
+	return response.literals[0], nil
+}
+
+func (c *imapClient) done(id string, remove bool) error {
+	if remove {
+		c.deleted = true
+		_, err := c.command(`UID STORE %s +FLAGS.SILENT (\Seen \Deleted)`, id)
+		return err
+	}
+	_, err := c.command(`UID STORE %s +FLAGS.SILENT (\Seen)`, id)
+	return err
+}
+
+func (c *imapClient) close() error {
+
+	defer c.conn.Close()
+	if c.deleted {
+		_, err := c.command("EXPUNGE")
+		if err != nil {
+			return err
+		}
+	}
+	_, err := c.command("LOGOUT")
+	return err
+}
@@ -0,0 +1,75 @@
+package mailbox
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"sync"
+	"time"
+)
+
+const (
+	PROTOCOL_ARG         string = "Protocol"
+	HOST_ARG             string = "Host"
+	PORT_ARG             string = "Port"
+	SECURITY_ARG         string = "Security"
+	USERNAME_ARG         string = "Username"
+	PASSWORD_ARG         string = "Password"
+	FOLDER_ARG           string = "Folder"
+	AFTER_ARG            string = "After Processing"
+	INTERVAL_ARG         string = "Interval"
+	SELF_SIGNED_CERT_ARG string = "Self Signed Cert"
+
+	PROTOCOL_IMAP string = "imap"
+	PROTOCOL_POP3 string = "pop3"
+
+	SECURITY_NONE     string = "none"
+	SECURITY_STARTTLS string = "starttls"
+	SECURITY_TLS      string = "tls"
+
+	AFTER_READ   string = "read"
+	AFTER_DELETE string = "delete"
+
+	DEFAULT_FOLDER   string = "INBOX"
+	DEFAULT_INTERVAL string = "1m"
+
+	// MAILBOX_HINT is the hint naming the mailbox a message was read from, e.g. alerts@imap.example.com/INBOX
+	MAILBOX_HINT string = "mailbox"
+
+	// MIN_INTERVAL is the shortest time allowed between polls, to avoid hammering the mail server
+	MIN_INTERVAL time.Duration = 10 * time.Second
+	// TIMEOUT is how long connecting to the mail server, or a single command, may take
+	TIMEOUT time.Duration = time.Minute
+	// MAX_MESSAGES is the most messages processed in a single poll, the rest are processed by the next poll
+	MAX_MESSAGES int = 100
+	// MAX_MESSAGE_SIZE is the largest message read from the mail server, so a bad server can't exhaust memory
+	MAX_MESSAGE_SIZE int = 10 * 1024 * 1024
+)
+
+// MailboxChannel is a publish channel that polls mailboxes over IMAP or POP3, creating an event for each new message
+type MailboxChannel struct {
+	// lock guards stop and seen
+	lock sync.Mutex
+	// stop is closed to stop the mailboxes being polled, nil when stopped
+	stop chan struct{}
+	// seen is the ids of the messages already processed in each POP3 mailbox, which has no read flag to keep track
+	// of them, keyed by mailbox. It's kept when the channel is restarted.
+	seen map[string]*seenIds
+}
+
+// seenIds is the ids of the messages already processed in a mailbox. The lock is held while the mailbox is polled,
+// so the watchers of a restarted channel can't poll the same mailbox at once.
+type seenIds struct {
+	sync.Mutex
+	ids map[string]bool
+}
+
+func init() {
+	channels.Register(&MailboxChannel{})
+}
+
+func (*MailboxChannel) Name() string {
+	return "mailbox"
+}
+
+func (*MailboxChannel) Description() string {
+	return "The mailbox channel polls mailboxes over IMAP or POP3 and receives each new message as an event."
+}
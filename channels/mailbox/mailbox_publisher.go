@@ -0,0 +1,265 @@
+package mailbox
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/connectrix/parsers/email"
+	"github.com/diggs/glog"
+	"strconv"
+	"time"
+)
+
+func (*MailboxChannel) PubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        PROTOCOL_ARG,
+			Description: "The protocol to read the mailbox with: imap or pop3.",
+			Default:     PROTOCOL_IMAP,
+		},
+		&channels.Arg{
+			Name:        HOST_ARG,
+			Description: "The mail server the mailbox is on.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        PORT_ARG,
+			Description: "The port of the mail server, defaults to the standard port for the protocol and security.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        SECURITY_ARG,
+			Description: "How to secure the connection: none, starttls or tls.",
+			Default:     SECURITY_TLS,
+		},
+		&channels.Arg{
+			Name:        USERNAME_ARG,
+			Description: "The username to log in with.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        PASSWORD_ARG,
+			Description: "The password to log in with.",
+			Default:     "",
+			Secret:      true,
+		},
+		&channels.Arg{
+			Name:        FOLDER_ARG,
+			Description: "The folder to read messages from, IMAP only.",
+			Default:     DEFAULT_FOLDER,
+		},
+		&channels.Arg{
+			Name:        AFTER_ARG,
+			Description: "What to do with a message once it has been received as an event: read to mark it as read, or delete. Defaults to read for IMAP and delete for POP3, as POP3 has no read flag and read messages would be received again after a restart.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        INTERVAL_ARG,
+			Description: "How often to check the mailbox for new messages, e.g. 5m",
+			Default:     DEFAULT_INTERVAL,
+		},
+		&channels.Arg{
+			Name:        SELF_SIGNED_CERT_ARG,
+			Description: "Set to true if the mail server is using a self signed SSL cert.",
+			Default:     "false",
+		},
+	}
+}
+
+func (*MailboxChannel) ValidatePubChannelArgs(args map[string]string) error {
+
+	if protocol := args[PROTOCOL_ARG]; protocol != PROTOCOL_IMAP && protocol != PROTOCOL_POP3 {
+		return errors.New(fmt.Sprintf("Unknown protocol '%s', expected %s or %s", protocol, PROTOCOL_IMAP, PROTOCOL_POP3))
+	}
+	if security := args[SECURITY_ARG]; security != SECURITY_NONE && security != SECURITY_STARTTLS && security != SECURITY_TLS {
+		return errors.New(fmt.Sprintf("Unknown security '%s', expected %s, %s or %s", security, SECURITY_NONE, SECURITY_STARTTLS, SECURITY_TLS))
+	}
+	if after := args[AFTER_ARG]; after != "" && after != AFTER_READ && after != AFTER_DELETE {
+		return errors.New(fmt.Sprintf("Unknown %s '%s', expected %s or %s", AFTER_ARG, after, AFTER_READ, AFTER_DELETE))
+	}
+	if port := args[PORT_ARG]; port != "" {
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return errors.New(fmt.Sprintf("Invalid port '%s'", port))
+		}
+	}
+	interval, err := time.ParseDuration(args[INTERVAL_ARG])
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid interval: %v", err))
+	}
+	if interval < MIN_INTERVAL {
+		return errors.New(fmt.Sprintf("The interval must be at least %v", MIN_INTERVAL))
+	}
+	if _, err := strconv.ParseBool(args[SELF_SIGNED_CERT_ARG]); err != nil {
+		return errors.New(fmt.Sprintf("%s must be true or false", SELF_SIGNED_CERT_ARG))
+	}
+
+	return nil
+}
+
+func (*MailboxChannel) PubChannelInfo(args map[string]string) []*channels.Info {
+	if args[HOST_ARG] == "" {
+		return nil
+	}
+	return []*channels.Info{
+		&channels.Info{Name: "Mailbox", Description: fmt.Sprintf("The mailbox being polled, provided as the %s hint", MAILBOX_HINT), Value: mailboxName(args)},
+	}
+}
+
+// mailboxName names the mailbox described by the args, e.g. alerts@imap.example.com/INBOX
+func mailboxName(args map[string]string) string {
+	if args[PROTOCOL_ARG] == PROTOCOL_POP3 {
+		return fmt.Sprintf("%s@%s", args[USERNAME_ARG], args[HOST_ARG])
+	}
+	folder := args[FOLDER_ARG]
+	if folder == "" {
+		folder = DEFAULT_FOLDER
+	}
+	return fmt.Sprintf("%s@%s/%s", args[USERNAME_ARG], args[HOST_ARG], folder)
+}
+
+// StartPubChannel polls each mailbox the sources of the channel are configured with
+func (ch *MailboxChannel) StartPubChannel(config map[string]string, pubChannelArgs []map[string]string) error {
+
+	stop := make(chan struct{})
+	ch.lock.Lock()
+	ch.stop = stop
+	if ch.seen == nil {
+		ch.seen = make(map[string]*seenIds)
+	}
+	ch.lock.Unlock()
+
+	// sources of the same namespace can share a mailbox, it only needs polling once
+	polling := make(map[string]bool)
+	for _, args := range pubChannelArgs {
+		argsWithDefaults, err := channels.WithDefaults(ch.PubChannelArgs(), args)
+		if err != nil {
+			glog.Warningf("Unable to poll mailbox: %v", err)
+			continue
+		}
+		if argsWithDefaults[AFTER_ARG] == "" {
+			argsWithDefaults[AFTER_ARG] = afterProcessing(argsWithDefaults[PROTOCOL_ARG])
+		}
+		key := fmt.Sprintf("%s://%s/%s", argsWithDefaults[PROTOCOL_ARG], mailboxName(argsWithDefaults), argsWithDefaults[channels.NAMESPACE_ARG])
+		if polling[key] {
+			continue
+		}
+		polling[key] = true
+		go ch.watch(key, argsWithDefaults, stop)
+	}
+	return nil
+}
+
+// afterProcessing returns what to do with messages by default once they are received, POP3 messages are deleted as
+// the ids of read messages are only remembered until Connectrix restarts
+func afterProcessing(protocol string) string {
+	if protocol == PROTOCOL_POP3 {
+		return AFTER_DELETE
+	}
+	return AFTER_READ
+}
+
+func (ch *MailboxChannel) StopPubChannel() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.stop != nil {
+		close(ch.stop)
+		ch.stop = nil
+	}
+	return nil
+}
+
+// watch polls the mailbox every interval until stop is closed
+func (ch *MailboxChannel) watch(key string, args map[string]string, stop chan struct{}) {
+
+	interval, err := time.ParseDuration(args[INTERVAL_ARG])
+	if err != nil || interval < MIN_INTERVAL {
+		interval = MIN_INTERVAL
+	}
+	glog.Infof("Polling mailbox %s every %v", mailboxName(args), interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		err := ch.poll(key, args)
+		if err != nil {
+			glog.Warningf("Unable to poll mailbox %s: %v", mailboxName(args), err)
+		}
+		select {
+		case <-stop:
+			glog.Debugf("No longer polling mailbox %s", mailboxName(args))
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll creates an event for each new message in the mailbox. Messages that can't be received as an event are
+// marked as read but not deleted, so they aren't received again but are still there to look at.
+func (ch *MailboxChannel) poll(key string, args map[string]string) error {
+
+	// events are created in the namespace of the source the args came from
+	namespace := args[channels.NAMESPACE_ARG]
+	if namespace == "" {
+		namespace = config.DEFAULT_NAMESPACE
+	}
+
+	ch.lock.Lock()
+	seen, exists := ch.seen[key]
+	if !exists {
+		seen = &seenIds{ids: make(map[string]bool)}
+		ch.seen[key] = seen
+	}
+	ch.lock.Unlock()
+
+	seen.Lock()
+	defer seen.Unlock()
+	mailbox, err := dial(args, seen.ids)
+	if err != nil {
+		return err
+	}
+	defer mailbox.close()
+
+	ids, err := mailbox.unseen()
+	if err != nil {
+		return err
+	}
+	if len(ids) > MAX_MESSAGES {
+		ids = ids[:MAX_MESSAGES]
+	}
+
+	for _, id := range ids {
+		data, err := mailbox.fetch(id)
+		if err != nil {
+			return err
+		}
+
+		err = ch.receive(namespace, args, &data)
+		if err != nil {
+			glog.Warningf("Unable to receive message %s from mailbox %s: %v", id, mailboxName(args), err)
+		}
+
+		err = mailbox.done(id, err == nil && args[AFTER_ARG] == AFTER_DELETE)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// receive creates an event from a message, identifying the source from the email hints and the mailbox hint
+func (ch *MailboxChannel) receive(namespace string, args map[string]string, data *[]byte) error {
+
+	hints, err := email.Hints(data, nil)
+	if err != nil {
+		return err
+	}
+	name := mailboxName(args)
+	hints = append(hints, event.Hint{Key: MAILBOX_HINT, Value: name, Text: "Mailbox:" + name})
+
+	_, err = events.ParseAndCreateEventFromChannel(ch.Name(), namespace, data, hints, nil)
+	return err
+}
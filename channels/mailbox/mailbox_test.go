@@ -0,0 +1,207 @@
+package mailbox
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var message = "From: backup@nas.example.com\r\nTo: alerts@example.com\r\nSubject: Backup failed\r\n\r\nThe nightly backup failed.\r\n.hidden\r\n"
+
+// standIn starts a mail server on localhost that greets each connection and replies to each command with respond,
+// recording the commands it was sent
+func standIn(t *testing.T, greeting string, respond func(command string) string) (net.Conn, *[]string) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commands := &[]string{}
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte(greeting + "\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			*commands = append(*commands, line)
+			conn.Write([]byte(respond(line)))
+		}
+	}()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, commands
+}
+
+func imapResponder(command string) string {
+	fields := strings.SplitN(command, " ", 2)
+	tag, rest := fields[0], fields[1]
+	switch {
+	case strings.HasPrefix(rest, "LOGIN"):
+		if rest != `LOGIN "alerts" "p\"ss"` {
+			return tag + " NO Invalid credentials\r\n"
+		}
+	case strings.HasPrefix(rest, "SELECT"):
+		return "* 3 EXISTS\r\n* FLAGS (\\Seen \\Deleted)\r\n" + tag + " OK [READ-WRITE] SELECT completed\r\n"
+	case rest == "UID SEARCH UNSEEN":
+		return "* SEARCH 7 9\r\n" + tag + " OK SEARCH completed\r\n"
+	case rest == "UID FETCH 7 (BODY.PEEK[])":
+		return "* 1 FETCH (UID 7 BODY[] {" + strconv.Itoa(len(message)) + "}\r\n" + message + ")\r\n" + tag + " OK FETCH completed\r\n"
+	case rest == "UID FETCH 9 (BODY.PEEK[])":
+		return tag + " NO No such message\r\n"
+	}
+	return tag + " OK done\r\n"
+}
+
+func TestImap(t *testing.T) {
+
+	conn, commands := standIn(t, "* OK IMAP ready", imapResponder)
+	client, err := loginImap(newConn(conn), false, nil, "alerts", `p"ss`, "Alerts/Backups")
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ids, err := client.unseen()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"7", "9"}, ids)
+
+	data, err := client.fetch("7")
+	assert.Nil(t, err)
+	assert.Equal(t, message, string(data))
+	_, err = client.fetch("9")
+	assert.NotNil(t, err)
+
+	assert.Nil(t, client.done("7", true))
+	assert.Nil(t, client.close())
+
+	assert.Equal(t, []string{
+		`c1 LOGIN "alerts" "p\"ss"`,
+		`c2 SELECT "Alerts/Backups"`,
+		"c3 UID SEARCH UNSEEN",
+		"c4 UID FETCH 7 (BODY.PEEK[])",
+		"c5 UID FETCH 9 (BODY.PEEK[])",
+		`c6 UID STORE 7 +FLAGS.SILENT (\Seen \Deleted)`,
+		"c7 EXPUNGE",
+		"c8 LOGOUT",
+	}, *commands)
+}
+
+func TestImapLoginFails(t *testing.T) {
+	conn, _ := standIn(t, "* OK IMAP ready", imapResponder)
+	_, err := loginImap(newConn(conn), false, nil, "alerts", "wrong", "INBOX")
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "IMAP LOGIN failed: NO Invalid credentials", err.Error())
+	}
+}
+
+func TestImapRejectsHugeLiteral(t *testing.T) {
+
+	for _, size := range []string{strconv.Itoa(MAX_MESSAGE_SIZE + 1), "99999999999999999999"} {
+		conn, _ := standIn(t, "* PREAUTH IMAP ready", func(command string) string {
+			tag := strings.SplitN(command, " ", 2)[0]
+			if strings.Contains(command, "FETCH") {
+				return "* 1 FETCH (UID 7 BODY[] {" + size + "}\r\n"
+			}
+			return tag + " OK done\r\n"
+		})
+		client, err := loginImap(newConn(conn), false, nil, "alerts", "pass", "INBOX")
+		if !assert.Nil(t, err) {
+			return
+		}
+		_, err = client.fetch("7")
+		if assert.NotNil(t, err, size) {
+			assert.Contains(t, err.Error(), "the most allowed is", size)
+		}
+		conn.Close()
+	}
+}
+
+func pop3Responder(command string) string {
+	switch command {
+	case "PASS wrong":
+		return "-ERR Invalid credentials\r\n"
+	case "UIDL":
+		return "+OK\r\n1 uid-a\r\n2 uid-b\r\n3 uid-c\r\n.\r\n"
+	case "RETR 2":
+		// lines starting with a dot are dot stuffed
+		return "+OK\r\n" + strings.Replace(message, "\r\n.hidden", "\r\n..hidden", 1) + ".\r\n"
+	}
+	return "+OK\r\n"
+}
+
+func TestPop3(t *testing.T) {
+
+	conn, commands := standIn(t, "+OK POP3 ready", pop3Responder)
+	seen := map[string]bool{"uid-a": true, "uid-gone": true}
+	client, err := loginPop3(newConn(conn), false, nil, "alerts", "secret", seen)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	ids, err := client.unseen()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"uid-b", "uid-c"}, ids)
+	// messages no longer in the mailbox are forgotten
+	assert.Equal(t, map[string]bool{"uid-a": true}, seen)
+
+	data, err := client.fetch("uid-b")
+	assert.Nil(t, err)
+	assert.Equal(t, message, string(data))
+
+	assert.Nil(t, client.done("uid-b", false))
+	assert.Nil(t, client.done("uid-c", true))
+	assert.Equal(t, map[string]bool{"uid-a": true, "uid-b": true, "uid-c": true}, seen)
+	assert.Nil(t, client.close())
+
+	assert.Equal(t, []string{"USER alerts", "PASS secret", "UIDL", "RETR 2", "DELE 3", "QUIT"}, *commands)
+}
+
+func TestPop3LoginFails(t *testing.T) {
+	conn, _ := standIn(t, "+OK POP3 ready", pop3Responder)
+	_, err := loginPop3(newConn(conn), false, nil, "alerts", "wrong", map[string]bool{})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "POP3 login failed", err.Error())
+	}
+}
+
+func TestValidatesArgs(t *testing.T) {
+
+	ch := &MailboxChannel{}
+	valid := func() map[string]string {
+		return map[string]string{PROTOCOL_ARG: "imap", HOST_ARG: "imap.example.com", SECURITY_ARG: "tls", USERNAME_ARG: "alerts", AFTER_ARG: "read", INTERVAL_ARG: "1m", SELF_SIGNED_CERT_ARG: "false"}
+	}
+	assert.Nil(t, ch.ValidatePubChannelArgs(valid()))
+
+	for arg, val := range map[string]string{PROTOCOL_ARG: "smtp", SECURITY_ARG: "ssl", AFTER_ARG: "archive", INTERVAL_ARG: "1s", PORT_ARG: "imap"} {
+		args := valid()
+		args[arg] = val
+		assert.NotNil(t, ch.ValidatePubChannelArgs(args), arg)
+	}
+}
+
+func TestMailboxName(t *testing.T) {
+	assert.Equal(t, "alerts@imap.example.com/INBOX", mailboxName(map[string]string{HOST_ARG: "imap.example.com", USERNAME_ARG: "alerts"}))
+	assert.Equal(t, "alerts@pop.example.com", mailboxName(map[string]string{PROTOCOL_ARG: "pop3", HOST_ARG: "pop.example.com", USERNAME_ARG: "alerts", FOLDER_ARG: "Backups"}))
+	assert.Equal(t, "995", defaultPort(PROTOCOL_POP3, SECURITY_TLS))
+	assert.Equal(t, "143", defaultPort(PROTOCOL_IMAP, SECURITY_STARTTLS))
+}
+
+func TestPop3DeletesByDefault(t *testing.T) {
+	assert.Equal(t, AFTER_DELETE, afterProcessing(PROTOCOL_POP3))
+	assert.Equal(t, AFTER_READ, afterProcessing(PROTOCOL_IMAP))
+}
@@ -0,0 +1,162 @@
+package mailbox
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// pop3Client is just enough of a POP3 client to read and delete the messages in a mailbox. POP3 has no read flag,
+// so messages that are kept are remembered by their unique id.
+type pop3Client struct {
+	conn *conn
+	// numbers is the message number of each unique id, which commands take
+	numbers map[string]string
+	// seen is the unique ids of the messages already processed
+	seen map[string]bool
+}
+
+func loginPop3(c *conn, startTLS bool, tlsConfig *tls.Config, username string, password string, seen map[string]bool) (*pop3Client, error) {
+
+	client := &pop3Client{conn: c, seen: seen}
+	greeting, err := c.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return nil, errors.New(fmt.Sprintf("POP3 server refused the connection: %s", greeting))
+	}
+
+	if startTLS {
+		_, err = client.command("STLS")
+		if err != nil {
+			return nil, err
+		}
+		err = c.startTLS(tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = client.command("USER %s", username)
+	if err != nil {
+		return nil, err
+	}
+	_, err = client.command("PASS %s", password)
+	if err != nil {
+		return nil, errors.New("POP3 login failed")
+	}
+
+	return client, nil
+}
+
+// command sends a command and returns the rest of its +OK line, or its -ERR line as an error
+func (c *pop3Client) command(format string, args ...interface{}) (string, error) {
+
+	err := c.conn.writeLine(fmt.Sprintf(format, args...))
+	if err != nil {
+		return "", err
+	}
+	line, err := c.conn.readLine()
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(line, "+OK") {
+		return "", errors.New(fmt.Sprintf("POP3 %s failed: %s", strings.SplitN(format, " ", 2)[0], line))
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, "+OK")), nil
+}
+
+// multiline reads a multi-line response up to the terminating dot, removing the dot stuffing. Responses longer than
+// MAX_MESSAGE_SIZE are an error.
+func (c *pop3Client) multiline() ([]string, error) {
+	lines := []string{}
+	size := 0
+	for {
+		line, err := c.conn.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "." {
+			return lines, nil
+		}
+		size += len(line) + 2
+		if size > MAX_MESSAGE_SIZE {
+			return nil, errors.New(fmt.Sprintf("POP3 response is longer than %d bytes", MAX_MESSAGE_SIZE))
+		}
+		lines = append(lines, strings.TrimPrefix(line, "."))
+	}
+}
+
+func (c *pop3Client) unseen() ([]string, error) {
+
+	_, err := c.command("UIDL")
+	if err != nil {
+		return nil, err
+	}
+	lines, err := c.multiline()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	c.numbers = make(map[string]string)
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		c.numbers[fields[1]] = fields[0]
+		if !c.seen[fields[1]] {
+			ids = append(ids, fields[1])
+		}
+	}
+
+	// forget messages that are no longer in the mailbox
+	for id := range c.seen {
+		if _, exists := c.numbers[id]; !exists {
+			delete(c.seen, id)
+		}
+	}
+	return ids, nil
+}
+
+func (c *pop3Client) fetch(id string) ([]byte, error) {
+
+	number, exists := c.numbers[id]
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("Unknown message %s", id))
+	}
+	_, err := c.command("RETR %s", number)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := c.multiline()
+	if err != nil {
+		return nil, err
+	}
+
+	var message bytes.Buffer
+	for _, line := range lines {
+		message.WriteString(line)
+		message.WriteString("\r\n")
+	}
+	return message.Bytes(), nil
+}
+
+func (c *pop3Client) done(id string, remove bool) error {
+	c.seen[id] = true
+	if remove {
+		_, err := c.command("DELE %s", c.numbers[id])
+		return err
+	}
+	return nil
+}
+
+// close quits, which is when the server deletes the messages
+func (c *pop3Client) close() error {
+	defer c.conn.Close()
+	_, err := c.command("QUIT")
+	return err
+}
@@ -8,6 +8,8 @@ type PubChannel interface {
 	Description() string
 	// Start initializes the channel
 	StartPubChannel(map[string]string, []map[string]string) error
+	// StopPubChannel stops the channel publishing events, it may be started again with different args
+	StopPubChannel() error
 	// PubChannelArgs are a a list of names of arguments needed to connect the channel
 	PubChannelArgs() []*Arg
 	// ValidatePubChannelArgs validates the supplied channel args
@@ -15,3 +17,11 @@ type PubChannel interface {
 	// PubChannelInfo returns info needed to configure the channel
 	PubChannelInfo(map[string]string) []*Info
 }
+
+// ExclusivePubChannel can be implemented by publish channels that decide the namespace of an event from one of its
+// source's args, e.g. the address an email was sent to. Sources in different namespaces can't make the same claim,
+// or one namespace would receive the events of another.
+type ExclusivePubChannel interface {
+	// PubChannelClaim returns what the source's args claim, e.g. "recipient alerts@example.com", or "" if nothing
+	PubChannelClaim(map[string]string) string
+}
@@ -0,0 +1,145 @@
+package channels
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Channel is implemented by every channel, which must also implement PubChannel, SubChannel or both
+type Channel interface {
+	// Name returns the name of the channel
+	Name() string
+	// Description returns a description of the channel
+	Description() string
+}
+
+// Summary describes a registered channel and the args it takes. Publish is true if sources can use the channel and
+// Subscribe is true if routes can use it.
+type Summary struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Publish     bool   `json:"publish"`
+	Subscribe   bool   `json:"subscribe"`
+	PubArgs     []*Arg `json:"pub_args,omitempty"`
+	SubArgs     []*Arg `json:"sub_args,omitempty"`
+}
+
+var registry = struct {
+	sync.RWMutex
+	pub map[string]PubChannel
+	sub map[string]SubChannel
+}{pub: make(map[string]PubChannel), sub: make(map[string]SubChannel)}
+
+// Register makes a channel available to sources if it's a PubChannel and to routes if it's a SubChannel, under the
+// name it returns. It's meant to be called from the init of the channel's package, and panics if the name is already
+// registered, so that a channel can't be replaced by accident.
+func Register(channel Channel) {
+
+	registry.Lock()
+	defer registry.Unlock()
+	if channel == nil || channel.Name() == "" {
+		panic("channels: a channel must have a name")
+	}
+	name := channel.Name()
+	_, pubExists := registry.pub[name]
+	_, subExists := registry.sub[name]
+	if pubExists || subExists {
+		panic(fmt.Sprintf("channels: channel %s is already registered", name))
+	}
+
+	pub, isPub := channel.(PubChannel)
+	sub, isSub := channel.(SubChannel)
+	if !isPub && !isSub {
+		panic(fmt.Sprintf("channels: channel %s is neither a publish nor a subscription channel", name))
+	}
+	if isPub {
+		registry.pub[name] = pub
+	}
+	if isSub {
+		registry.sub[name] = sub
+	}
+}
+
+// Registered returns the names of the registered channels in alphabetical order
+func Registered() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.pub)+len(registry.sub))
+	for name := range registry.pub {
+		names = append(names, name)
+	}
+	for name := range registry.sub {
+		if _, exists := registry.pub[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Summarize returns the description of the named channel and the args its sources and routes take
+func Summarize(channelName string) (*Summary, error) {
+
+	pub, pubErr := GetPubChannel(channelName)
+	sub, subErr := GetSubChannel(channelName)
+	if pubErr != nil && subErr != nil {
+		return nil, pubErr
+	}
+
+	summary := &Summary{Name: channelName}
+	if pub != nil {
+		summary.Description = pub.Description()
+		summary.Publish = true
+		summary.PubArgs = pub.PubChannelArgs()
+	}
+	if sub != nil {
+		summary.Description = sub.Description()
+		summary.Subscribe = true
+		summary.SubArgs = sub.SubChannelArgs()
+	}
+	return summary, nil
+}
+
+func GetPubChannel(channelName string) (PubChannel, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	if channel, exists := registry.pub[channelName]; exists {
+		return channel, nil
+	} else {
+		return nil, errors.New(fmt.Sprintf("Unknown channel: %s", channelName))
+	}
+}
+
+func GetSubChannel(channelName string) (SubChannel, error) {
+	registry.RLock()
+	defer registry.RUnlock()
+	if channel, exists := registry.sub[channelName]; exists {
+		return channel, nil
+	} else {
+		return nil, errors.New(fmt.Sprintf("Unknown channel: %s", channelName))
+	}
+}
+
+// pubChannels returns a copy of the registered publish channels, so they can be started without holding the lock
+func pubChannels() map[string]PubChannel {
+	registry.RLock()
+	defer registry.RUnlock()
+	channels := make(map[string]PubChannel, len(registry.pub))
+	for name, channel := range registry.pub {
+		channels[name] = channel
+	}
+	return channels
+}
+
+// subChannels returns a copy of the registered subscription channels
+func subChannels() map[string]SubChannel {
+	registry.RLock()
+	defer registry.RUnlock()
+	channels := make(map[string]SubChannel, len(registry.sub))
+	for name, channel := range registry.sub {
+		channels[name] = channel
+	}
+	return channels
+}
@@ -0,0 +1,67 @@
+package smtp
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	HOST_ARG             string = "Host"
+	PORT_ARG             string = "Port"
+	SECURITY_ARG         string = "Security"
+	USERNAME_ARG         string = "Username"
+	PASSWORD_ARG         string = "Password"
+	FROM_ARG             string = "From"
+	TO_ARG               string = "To"
+	CC_ARG               string = "Cc"
+	SUBJECT_ARG          string = "Subject"
+	HTML_ARG             string = "HTML"
+	SELF_SIGNED_CERT_ARG string = "Self Signed Cert"
+	RECIPIENT_ARG        string = "Recipient"
+
+	SECURITY_NONE     string = "none"
+	SECURITY_STARTTLS string = "starttls"
+	SECURITY_TLS      string = "tls"
+
+	DEFAULT_PORT string = "25"
+
+	// config keys of the SMTP listener
+	PORT_CONFIG     string = "port"
+	BIND_CONFIG     string = "bind"
+	DOMAIN_CONFIG   string = "domain"
+	MAX_SIZE_CONFIG string = "max_size"
+
+	DEFAULT_LISTEN_PORT string = "2525"
+	DEFAULT_DOMAIN      string = "localhost"
+	DEFAULT_MAX_SIZE    int64  = 10 * 1024 * 1024
+	// DEFAULT_BIND only accepts email from the local machine, as there's no authentication
+	DEFAULT_BIND string = "127.0.0.1"
+
+	// SEND_TIMEOUT is how long sending a single email may take, from connecting to quitting
+	SEND_TIMEOUT time.Duration = 30 * time.Second
+)
+
+type SmtpChannel struct {
+	// lock guards listener, config and namespaces
+	lock sync.Mutex
+	// listener is the listener the publish channel is accepting connections on, nil when stopped
+	listener net.Listener
+	// config is the channel config the publish channel was started with
+	config map[string]string
+	// namespaces is the namespace of the source each recipient was configured for
+	namespaces map[string]string
+}
+
+func init() {
+	channels.Register(&SmtpChannel{})
+}
+
+func (*SmtpChannel) Name() string {
+	return "smtp"
+}
+
+func (*SmtpChannel) Description() string {
+	return "The SMTP channel sends events as email, and receives email as events."
+}
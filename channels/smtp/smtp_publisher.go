@@ -0,0 +1,171 @@
+package smtp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events"
+	"github.com/diggs/connectrix/parsers/email"
+	"github.com/diggs/glog"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+func (*SmtpChannel) PubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        RECIPIENT_ARG,
+			Description: "The address email for the source is sent to, which decides the namespace the events are created in. Match it with an email:to hint.",
+			Default:     "",
+		},
+	}
+}
+
+func (*SmtpChannel) ValidatePubChannelArgs(args map[string]string) error {
+	if recipient := args[RECIPIENT_ARG]; recipient != "" {
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return errors.New(fmt.Sprintf("Invalid %s address '%s': %v", RECIPIENT_ARG, recipient, err))
+		}
+	}
+	return nil
+}
+
+// PubChannelClaim returns the source's recipient, as email sent to it is created in the source's namespace
+func (*SmtpChannel) PubChannelClaim(args map[string]string) string {
+	address, err := mail.ParseAddress(args[RECIPIENT_ARG])
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("recipient %s", strings.ToLower(address.Address))
+}
+
+// PubChannelInfo returns the address of the SMTP listener, which systems sending alert emails should relay through
+func (ch *SmtpChannel) PubChannelInfo(args map[string]string) []*channels.Info {
+
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.listener == nil {
+		return nil
+	}
+
+	info := []*channels.Info{
+		&channels.Info{Name: "Server", Description: "The SMTP server to send email to", Value: fmt.Sprintf("%s:%s", ch.config[DOMAIN_CONFIG], ch.port())},
+	}
+	if recipient := args[RECIPIENT_ARG]; recipient != "" {
+		info = append(info, &channels.Info{Name: "Recipient", Description: "The address to send email to", Value: recipient})
+	}
+	return info
+}
+
+func (ch *SmtpChannel) port() string {
+	if port := ch.config[PORT_CONFIG]; port != "" {
+		return port
+	}
+	return DEFAULT_LISTEN_PORT
+}
+
+// StartPubChannel listens for email. The listener is only started if the port is configured or a source uses the
+// channel, so that sending email doesn't require opening a port.
+func (ch *SmtpChannel) StartPubChannel(config map[string]string, pubChannelArgs []map[string]string) error {
+
+	if config[PORT_CONFIG] == "" && len(pubChannelArgs) == 0 {
+		return nil
+	}
+
+	maxSize := DEFAULT_MAX_SIZE
+	if config[MAX_SIZE_CONFIG] != "" {
+		var err error
+		maxSize, err = strconv.ParseInt(config[MAX_SIZE_CONFIG], 10, 64)
+		if err != nil || maxSize <= 0 {
+			return errors.New(fmt.Sprintf("Invalid %s '%s', expected a number of bytes", MAX_SIZE_CONFIG, config[MAX_SIZE_CONFIG]))
+		}
+	}
+	domain := config[DOMAIN_CONFIG]
+	if domain == "" {
+		domain = DEFAULT_DOMAIN
+	}
+
+	// events are created in the namespace of the source whose recipient the email was sent to, validation makes sure
+	// a recipient is only used by one namespace
+	namespaces := make(map[string]string)
+	for _, args := range pubChannelArgs {
+		if address, err := mail.ParseAddress(args[RECIPIENT_ARG]); err == nil {
+			namespaces[strings.ToLower(address.Address)] = args[channels.NAMESPACE_ARG]
+		}
+	}
+
+	ch.lock.Lock()
+	ch.config = map[string]string{DOMAIN_CONFIG: domain, PORT_CONFIG: config[PORT_CONFIG]}
+	port := ch.port()
+	ch.lock.Unlock()
+
+	bind := config[BIND_CONFIG]
+	if bind == "" {
+		bind = DEFAULT_BIND
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort(bind, port))
+	if err != nil {
+		return err
+	}
+	ch.lock.Lock()
+	ch.listener = listener
+	ch.namespaces = namespaces
+	ch.lock.Unlock()
+
+	glog.Infof("Starting SMTP channel on %s...", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			// ignore the error if the listener was closed by StopPubChannel
+			ch.lock.Lock()
+			defer ch.lock.Unlock()
+			if ch.listener != listener {
+				return nil
+			}
+			return err
+		}
+		glog.Debugf("SMTP connection from %s", conn.RemoteAddr())
+		s := &session{conn: conn, domain: domain, maxSize: maxSize, deliver: ch.deliver}
+		go s.serve()
+	}
+}
+
+func (ch *SmtpChannel) StopPubChannel() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.listener == nil {
+		return nil
+	}
+	err := ch.listener.Close()
+	ch.listener = nil
+	return err
+}
+
+// deliver creates an event from a received email, in the namespace of the first recipient that a source is
+// configured for
+func (ch *SmtpChannel) deliver(recipients []string, data *[]byte) (string, error) {
+
+	namespace := config.DEFAULT_NAMESPACE
+	ch.lock.Lock()
+	for _, recipient := range recipients {
+		if ns, exists := ch.namespaces[strings.ToLower(recipient)]; exists && ns != "" {
+			namespace = ns
+			break
+		}
+	}
+	ch.lock.Unlock()
+
+	hints, err := email.Hints(data, recipients)
+	if err != nil {
+		return "", err
+	}
+	id, err := events.ParseAndCreateEventFromChannel(ch.Name(), namespace, data, hints, nil)
+	if err != nil {
+		glog.Warningf("Unable to create event from email to %s: %v", strings.Join(recipients, ", "), err)
+		return "", err
+	}
+	return fmt.Sprintf("OK, created event %d", id), nil
+}
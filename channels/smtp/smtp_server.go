@@ -0,0 +1,162 @@
+package smtp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+const (
+	// COMMAND_TIMEOUT is how long a client has to send each command, and the whole message after DATA
+	COMMAND_TIMEOUT time.Duration = 5 * time.Minute
+	// MAX_RECIPIENTS is the most recipients accepted for a single message, as required by RFC 5321
+	MAX_RECIPIENTS int = 100
+)
+
+// session is a connection from an SMTP client. Only what is needed to receive mail is supported: there is no
+// relaying, authentication or TLS.
+type session struct {
+	conn    net.Conn
+	text    *textproto.Conn
+	domain  string
+	maxSize int64
+	// mail is true once MAIL has been accepted, as the empty sender used by bounces is a valid sender
+	mail       bool
+	from       string
+	recipients []string
+	// deliver turns a received message into an event, returning a description of what happened to it
+	deliver func(recipients []string, data *[]byte) (string, error)
+}
+
+func (s *session) reply(code int, format string, args ...interface{}) error {
+	return s.text.PrintfLine("%d %s", code, fmt.Sprintf(format, args...))
+}
+
+// serve speaks SMTP to the client until it quits or the connection fails
+func (s *session) serve() {
+
+	defer s.conn.Close()
+	s.text = textproto.NewConn(s.conn)
+	s.reply(220, "%s Connectrix ESMTP ready", s.domain)
+
+	for {
+		s.conn.SetDeadline(time.Now().Add(COMMAND_TIMEOUT))
+		line, err := s.text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg := line, ""
+		if i := strings.Index(line, " "); i >= 0 {
+			verb, arg = line[:i], strings.TrimSpace(line[i+1:])
+		}
+
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			s.reset()
+			s.reply(250, "%s", s.domain)
+		case "EHLO":
+			s.reset()
+			s.text.PrintfLine("250-%s", s.domain)
+			s.text.PrintfLine("250-SIZE %d", s.maxSize)
+			s.text.PrintfLine("250-8BITMIME")
+			s.reply(250, "PIPELINING")
+		case "MAIL":
+			address, ok := parsePath(arg, "FROM:")
+			if !ok {
+				s.reply(501, "Syntax: MAIL FROM:<address>")
+				continue
+			}
+			s.reset()
+			s.mail = true
+			s.from = address
+			s.reply(250, "OK")
+		case "RCPT":
+			address, ok := parsePath(arg, "TO:")
+			switch {
+			case !ok || address == "":
+				s.reply(501, "Syntax: RCPT TO:<address>")
+			case !s.mail:
+				s.reply(503, "MAIL is required first")
+			case len(s.recipients) >= MAX_RECIPIENTS:
+				s.reply(452, "Too many recipients")
+			default:
+				s.recipients = append(s.recipients, address)
+				s.reply(250, "OK")
+			}
+		case "DATA":
+			if len(s.recipients) == 0 {
+				s.reply(503, "RCPT is required first")
+				continue
+			}
+			s.receive()
+		case "RSET":
+			s.reset()
+			s.reply(250, "OK")
+		case "NOOP":
+			s.reply(250, "OK")
+		case "QUIT":
+			s.reply(221, "Bye")
+			return
+		default:
+			s.reply(502, "Command not implemented")
+		}
+	}
+}
+
+func (s *session) reset() {
+	s.mail = false
+	s.from = ""
+	s.recipients = nil
+}
+
+// receive reads the message sent after DATA and delivers it
+func (s *session) receive() {
+
+	s.reply(354, "End data with <CR><LF>.<CR><LF>")
+	s.conn.SetDeadline(time.Now().Add(COMMAND_TIMEOUT))
+
+	// read one more byte than allowed so we can tell if the message is too big, then discard the rest
+	dotReader := s.text.DotReader()
+	data, err := ioutil.ReadAll(io.LimitReader(dotReader, s.maxSize+1))
+	if err == nil {
+		_, err = io.Copy(ioutil.Discard, dotReader)
+	}
+	recipients := s.recipients
+	s.reset()
+	if err != nil {
+		s.reply(451, "Unable to read message: %v", err)
+		return
+	}
+	if int64(len(data)) > s.maxSize {
+		s.reply(552, "Message is bigger than %d bytes", s.maxSize)
+		return
+	}
+
+	result, err := s.deliver(recipients, &data)
+	if err != nil {
+		s.reply(554, "%s", strings.Replace(err.Error(), "\n", " ", -1))
+		return
+	}
+	s.reply(250, "%s", result)
+}
+
+// parsePath extracts the address from the argument of MAIL or RCPT, e.g. FROM:<cron@example.com> SIZE=1024
+func parsePath(arg string, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	if !strings.HasPrefix(path, "<") {
+		return "", false
+	}
+	end := strings.Index(path, ">")
+	if end < 0 {
+		return "", false
+	}
+	return path[1:end], true
+}
@@ -0,0 +1,141 @@
+package smtp
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+// serveOne starts a session for a single connection on localhost, delivering with deliver
+func serveOne(t *testing.T, maxSize int64, deliver func([]string, *[]byte) (string, error)) string {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		s := &session{conn: conn, domain: "connectrix.test", maxSize: maxSize, deliver: deliver}
+		s.serve()
+	}()
+	return listener.Addr().String()
+}
+
+func TestReceivesEmail(t *testing.T) {
+
+	var recipients []string
+	var data string
+	addr := serveOne(t, DEFAULT_MAX_SIZE, func(to []string, message *[]byte) (string, error) {
+		recipients = to
+		data = string(*message)
+		return "OK, created event 1", nil
+	})
+
+	message := "From: cron@example.com\r\nSubject: Backup failed\r\n\r\nThe nightly backup failed.\r\n.and a line starting with a dot\r\n"
+	err := smtp.SendMail(addr, nil, "cron@example.com", []string{"alerts@connectrix.test", "ops@connectrix.test"}, []byte(message))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"alerts@connectrix.test", "ops@connectrix.test"}, recipients)
+	assert.Equal(t, "From: cron@example.com\nSubject: Backup failed\n\nThe nightly backup failed.\n.and a line starting with a dot\n", data)
+}
+
+func TestRejectsEmail(t *testing.T) {
+
+	addr := serveOne(t, DEFAULT_MAX_SIZE, func(to []string, message *[]byte) (string, error) {
+		return "", errors.New("Unable to identify event source")
+	})
+
+	err := smtp.SendMail(addr, nil, "cron@example.com", []string{"alerts@connectrix.test"}, []byte("Subject: Hello\r\n\r\nHi\r\n"))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, 554, err.(*textproto.Error).Code)
+		assert.Contains(t, err.Error(), "Unable to identify event source")
+	}
+}
+
+func TestRejectsLargeEmail(t *testing.T) {
+
+	delivered := false
+	addr := serveOne(t, 16, func(to []string, message *[]byte) (string, error) {
+		delivered = true
+		return "OK", nil
+	})
+
+	err := smtp.SendMail(addr, nil, "cron@example.com", []string{"alerts@connectrix.test"}, []byte("Subject: Hello\r\n\r\nThis is more than sixteen bytes\r\n"))
+	if assert.NotNil(t, err) {
+		assert.Equal(t, 552, err.(*textproto.Error).Code)
+	}
+	assert.False(t, delivered)
+}
+
+func TestRequiresMailBeforeRcpt(t *testing.T) {
+
+	addr := serveOne(t, DEFAULT_MAX_SIZE, nil)
+	conn, err := textproto.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	command := func(line string, expectCode int) {
+		id, err := conn.Cmd("%s", line)
+		assert.Nil(t, err)
+		conn.StartResponse(id)
+		_, _, err = conn.ReadResponse(expectCode)
+		conn.EndResponse(id)
+		assert.Nil(t, err, line)
+	}
+	_, _, err = conn.ReadResponse(220)
+	assert.Nil(t, err)
+	command("HELO client.test", 250)
+	command("RCPT TO:<alerts@connectrix.test>", 503)
+	command("MAIL FROM:<>", 250)
+	command("RCPT TO:<alerts@connectrix.test>", 250)
+	command("RCPT TO:alerts@connectrix.test", 501)
+	command("VRFY alerts", 502)
+	command("QUIT", 221)
+}
+
+func TestParsePath(t *testing.T) {
+	address, ok := parsePath("FROM:<cron@example.com> SIZE=1024", "FROM:")
+	assert.True(t, ok)
+	assert.Equal(t, "cron@example.com", address)
+	address, ok = parsePath("to: <Alerts@Example.com>", "TO:")
+	assert.True(t, ok)
+	assert.Equal(t, "Alerts@Example.com", address)
+	_, ok = parsePath("FROM:cron@example.com", "FROM:")
+	assert.False(t, ok)
+}
+
+func TestValidatesRecipient(t *testing.T) {
+	ch := &SmtpChannel{}
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{RECIPIENT_ARG: "alerts@connectrix.test"}))
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{}))
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{RECIPIENT_ARG: "alerts"}))
+}
+
+func TestClaimsRecipient(t *testing.T) {
+	ch := &SmtpChannel{}
+	assert.Equal(t, "recipient alerts@connectrix.test", ch.PubChannelClaim(map[string]string{RECIPIENT_ARG: "Alerts <Alerts@Connectrix.test>"}))
+	assert.Equal(t, "", ch.PubChannelClaim(map[string]string{}))
+}
+
+func TestListensOnLocalhostByDefault(t *testing.T) {
+
+	ch := &SmtpChannel{}
+	go ch.StartPubChannel(map[string]string{PORT_CONFIG: "0"}, nil)
+	for i := 0; i < 100 && ch.PubChannelInfo(nil) == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	ch.lock.Lock()
+	addr := ch.listener.Addr().(*net.TCPAddr)
+	ch.lock.Unlock()
+	assert.True(t, addr.IP.IsLoopback())
+	assert.Nil(t, ch.StopPubChannel())
+}
@@ -0,0 +1,313 @@
+package smtp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/events/event"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (*SmtpChannel) SubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        HOST_ARG,
+			Description: "The SMTP server to send email through.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        PORT_ARG,
+			Description: "The port of the SMTP server.",
+			Default:     DEFAULT_PORT,
+		},
+		&channels.Arg{
+			Name:        SECURITY_ARG,
+			Description: "How to secure the connection: none, starttls or tls.",
+			Default:     SECURITY_NONE,
+		},
+		&channels.Arg{
+			Name:        USERNAME_ARG,
+			Description: "The username to authenticate with, leave blank to send without authenticating.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        PASSWORD_ARG,
+			Description: "The password to authenticate with.",
+			Default:     "",
+			Secret:      true,
+		},
+		&channels.Arg{
+			Name:        FROM_ARG,
+			Description: "The address to send the email from, e.g. Connectrix <connectrix@example.com>",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        TO_ARG,
+			Description: "A comma separated list of addresses to send the email to.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        CC_ARG,
+			Description: "A comma separated list of addresses to copy the email to.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        SUBJECT_ARG,
+			Description: "The subject of the email.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        HTML_ARG,
+			Description: "An HTML body to send alongside the plain text body, which is the event content.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        SELF_SIGNED_CERT_ARG,
+			Description: "Set to true if the SMTP server is using a self signed SSL cert.",
+			Default:     "false",
+		},
+	}
+}
+
+func (*SmtpChannel) ValidateSubChannelArgs(args map[string]string) error {
+
+	port, err := strconv.Atoi(args[PORT_ARG])
+	if err != nil || port <= 0 || port > 65535 {
+		return errors.New(fmt.Sprintf("Invalid port: %s", args[PORT_ARG]))
+	}
+
+	switch args[SECURITY_ARG] {
+	case SECURITY_NONE, SECURITY_STARTTLS, SECURITY_TLS:
+	default:
+		return errors.New(fmt.Sprintf("Unknown security '%s', expected %s, %s or %s", args[SECURITY_ARG], SECURITY_NONE, SECURITY_STARTTLS, SECURITY_TLS))
+	}
+
+	_, err = strconv.ParseBool(args[SELF_SIGNED_CERT_ARG])
+	if err != nil {
+		return err
+	}
+
+	// addresses can be templated, in which case they can only be checked once they have been templated
+	if !strings.Contains(args[FROM_ARG], "{{") {
+		if _, err := mail.ParseAddress(args[FROM_ARG]); err != nil {
+			return errors.New(fmt.Sprintf("Invalid from address: %v", err))
+		}
+	}
+	for _, name := range []string{TO_ARG, CC_ARG} {
+		if args[name] != "" && !strings.Contains(args[name], "{{") {
+			if _, err := mail.ParseAddressList(args[name]); err != nil {
+				return errors.New(fmt.Sprintf("Invalid %s address: %v", name, err))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (*SmtpChannel) SubChannelInfo(map[string]string) []*channels.Info {
+	return nil
+}
+
+func (*SmtpChannel) StartSubChannel(config map[string]string) error {
+	return nil
+}
+
+func (*SmtpChannel) Drain(args map[string]string, event *event.Event, content string) error {
+
+	from, err := mail.ParseAddress(args[FROM_ARG])
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid from address: %v", err))
+	}
+	to, err := parseAddresses(args[TO_ARG])
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid to address: %v", err))
+	}
+	cc, err := parseAddresses(args[CC_ARG])
+	if err != nil {
+		return errors.New(fmt.Sprintf("Invalid cc address: %v", err))
+	}
+	if len(to)+len(cc) == 0 {
+		return errors.New("The email has no recipients")
+	}
+
+	message, err := buildMessage(from, to, cc, args[SUBJECT_ARG], content, args[HTML_ARG], time.Now())
+	if err != nil {
+		return err
+	}
+
+	recipients := []string{}
+	for _, address := range append(to, cc...) {
+		recipients = append(recipients, address.Address)
+	}
+	return send(args, from.Address, recipients, message)
+}
+
+// parseAddresses parses a comma separated list of addresses, which may be empty
+func parseAddresses(list string) ([]*mail.Address, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	return mail.ParseAddressList(list)
+}
+
+func joinAddresses(addresses []*mail.Address) string {
+	formatted := make([]string, len(addresses))
+	for i, address := range addresses {
+		formatted[i] = address.String()
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// buildMessage formats the email. The text body is always sent, and when there is an HTML body the two are sent
+// as alternatives of each other.
+func buildMessage(from *mail.Address, to []*mail.Address, cc []*mail.Address, subject string, text string, html string, date time.Time) ([]byte, error) {
+
+	message := new(bytes.Buffer)
+	writeHeader := func(name string, value string) {
+		fmt.Fprintf(message, "%s: %s\r\n", name, value)
+	}
+
+	writeHeader("From", from.String())
+	if len(to) > 0 {
+		writeHeader("To", joinAddresses(to))
+	}
+	if len(cc) > 0 {
+		writeHeader("Cc", joinAddresses(cc))
+	}
+	writeHeader("Subject", mime.QEncoding.Encode("utf-8", subject))
+	writeHeader("Date", date.Format(time.RFC1123Z))
+	writeHeader("MIME-Version", "1.0")
+
+	if html == "" {
+		writeHeader("Content-Type", "text/plain; charset=utf-8")
+		writeHeader("Content-Transfer-Encoding", "quoted-printable")
+		message.WriteString("\r\n")
+		err := writeQuotedPrintable(message, text)
+		if err != nil {
+			return nil, err
+		}
+		return message.Bytes(), nil
+	}
+
+	parts := multipart.NewWriter(message)
+	writeHeader("Content-Type", fmt.Sprintf("multipart/alternative; boundary=%s", parts.Boundary()))
+	message.WriteString("\r\n")
+
+	// parts are in order of preference, least preferred first
+	for _, part := range []struct{ contentType, body string }{{"text/plain", text}, {"text/html", html}} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType+"; charset=utf-8")
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		writer, err := parts.CreatePart(header)
+		if err != nil {
+			return nil, err
+		}
+		err = writeQuotedPrintable(writer, part.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := parts.Close()
+	if err != nil {
+		return nil, err
+	}
+	return message.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, body string) error {
+	writer := quotedprintable.NewWriter(w)
+	_, err := writer.Write([]byte(body))
+	if err != nil {
+		return err
+	}
+	return writer.Close()
+}
+
+// send connects to the SMTP server, securing and authenticating the connection as configured, and sends the message
+func send(args map[string]string, from string, recipients []string, message []byte) error {
+
+	host := args[HOST_ARG]
+	port := args[PORT_ARG]
+	if port == "" {
+		port = DEFAULT_PORT
+	}
+	selfSignedCert, _ := strconv.ParseBool(args[SELF_SIGNED_CERT_ARG])
+	tlsConfig := &tls.Config{ServerName: host, InsecureSkipVerify: selfSignedCert}
+	address := net.JoinHostPort(host, port)
+
+	var conn net.Conn
+	var err error
+	if args[SECURITY_ARG] == SECURITY_TLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: SEND_TIMEOUT}, "tcp", address, tlsConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", address, SEND_TIMEOUT)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(SEND_TIMEOUT))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if args[SECURITY_ARG] == SECURITY_STARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.New(fmt.Sprintf("%s does not support STARTTLS", host))
+		}
+		err = client.StartTLS(tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	// net/smtp refuses to send the password over an unencrypted connection, unless the server is local
+	if args[USERNAME_ARG] != "" {
+		err = client.Auth(smtp.PlainAuth("", args[USERNAME_ARG], args[PASSWORD_ARG], host))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = client.Mail(from)
+	if err != nil {
+		return err
+	}
+	for _, recipient := range recipients {
+		err = client.Rcpt(recipient)
+		if err != nil {
+			return err
+		}
+	}
+
+	data, err := client.Data()
+	if err != nil {
+		return err
+	}
+	_, err = data.Write(message)
+	if err != nil {
+		return err
+	}
+	err = data.Close()
+	if err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
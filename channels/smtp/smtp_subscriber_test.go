@@ -0,0 +1,179 @@
+package smtp
+
+import (
+	"bufio"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+// received is what the stand-in SMTP server was sent
+type received struct {
+	auth       string
+	from       string
+	recipients []string
+	data       string
+}
+
+// standIn starts an SMTP server on localhost that accepts a single email and sends what it received on the
+// returned channel
+func standIn(t *testing.T) (string, chan *received) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := make(chan *received, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		email := &received{}
+		reply("220 localhost stand-in")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO":
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case "AUTH":
+				email.auth = line
+				reply("235 Authenticated")
+			case "MAIL":
+				email.from = line
+				reply("250 OK")
+			case "RCPT":
+				email.recipients = append(email.recipients, line)
+				reply("250 OK")
+			case "DATA":
+				reply("354 Go ahead")
+				data := ""
+				for {
+					dataLine, err := r.ReadString('\n')
+					if err != nil || dataLine == ".\r\n" {
+						break
+					}
+					data += dataLine
+				}
+				email.data = data
+				reply("250 Queued")
+			case "QUIT":
+				reply("221 Bye")
+				result <- email
+				return
+			default:
+				reply("502 Unknown command")
+			}
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	return port, result
+}
+
+func TestSendsPlainTextEmail(t *testing.T) {
+
+	port, result := standIn(t)
+	args := map[string]string{
+		HOST_ARG:     "127.0.0.1",
+		PORT_ARG:     port,
+		USERNAME_ARG: "connectrix",
+		PASSWORD_ARG: "s3cret",
+		FROM_ARG:     "Connectrix <connectrix@example.com>",
+		TO_ARG:       "dev@example.com, ops@example.com",
+		CC_ARG:       "Boss <boss@example.com>",
+		SUBJECT_ARG:  "Build failed: connectrix",
+	}
+
+	err := (&SmtpChannel{}).Drain(args, &event.Event{}, "The build failed")
+	assert.Nil(t, err)
+
+	email := <-result
+	assert.Contains(t, email.auth, "AUTH PLAIN")
+	assert.Equal(t, "MAIL FROM:<connectrix@example.com>", email.from)
+	assert.Equal(t, []string{"RCPT TO:<dev@example.com>", "RCPT TO:<ops@example.com>", "RCPT TO:<boss@example.com>"}, email.recipients)
+
+	message, err := mail.ReadMessage(strings.NewReader(email.data))
+	assert.Nil(t, err)
+	assert.Equal(t, "Build failed: connectrix", message.Header.Get("Subject"))
+	assert.Equal(t, `"Boss" <boss@example.com>`, message.Header.Get("Cc"))
+	assert.Equal(t, "text/plain; charset=utf-8", message.Header.Get("Content-Type"))
+	// the client ends the data with a line break
+	body, _ := ioutil.ReadAll(message.Body)
+	assert.Equal(t, "The build failed", strings.TrimRight(string(body), "\r\n"))
+}
+
+func TestSendsHtmlEmail(t *testing.T) {
+
+	port, result := standIn(t)
+	args := map[string]string{
+		HOST_ARG:    "127.0.0.1",
+		PORT_ARG:    port,
+		FROM_ARG:    "connectrix@example.com",
+		TO_ARG:      "dev@example.com",
+		SUBJECT_ARG: "Déploiement réussi",
+		HTML_ARG:    "<p>The build <b>passed</b></p>",
+	}
+
+	err := (&SmtpChannel{}).Drain(args, &event.Event{}, "The build passed")
+	assert.Nil(t, err)
+
+	email := <-result
+	message, err := mail.ReadMessage(strings.NewReader(email.data))
+	assert.Nil(t, err)
+	subject, _ := new(mime.WordDecoder).DecodeHeader(message.Header.Get("Subject"))
+	assert.Equal(t, "Déploiement réussi", subject)
+
+	mediaType, params, err := mime.ParseMediaType(message.Header.Get("Content-Type"))
+	assert.Nil(t, err)
+	assert.Equal(t, "multipart/alternative", mediaType)
+
+	parts := multipart.NewReader(message.Body, params["boundary"])
+	for _, expected := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "The build passed"},
+		{"text/html; charset=utf-8", "<p>The build <b>passed</b></p>"},
+	} {
+		part, err := parts.NextPart()
+		if assert.Nil(t, err) {
+			assert.Equal(t, expected.contentType, part.Header.Get("Content-Type"))
+			body, _ := ioutil.ReadAll(part)
+			assert.Equal(t, expected.body, string(body))
+		}
+	}
+}
+
+func TestSubChannelArgValidation(t *testing.T) {
+
+	smtpChannel := SmtpChannel{}
+	valid := func() map[string]string {
+		return map[string]string{
+			HOST_ARG: "smtp.example.com", PORT_ARG: "587", SECURITY_ARG: SECURITY_STARTTLS, SELF_SIGNED_CERT_ARG: "false",
+			FROM_ARG: "connectrix@example.com", TO_ARG: "{{.pusher.email}}",
+		}
+	}
+	assert.Nil(t, smtpChannel.ValidateSubChannelArgs(valid()))
+
+	for name, value := range map[string]string{PORT_ARG: "smtp", SECURITY_ARG: "ssl", FROM_ARG: "not an address", CC_ARG: "boss at example.com"} {
+		args := valid()
+		args[name] = value
+		assert.NotNil(t, smtpChannel.ValidateSubChannelArgs(args), name)
+	}
+}
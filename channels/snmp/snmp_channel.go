@@ -0,0 +1,55 @@
+package snmp
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"net"
+	"sync"
+)
+
+const (
+	COMMUNITY_ARG  string = "Community"
+	HOST_ARG       string = "Host"
+	PORT_ARG       string = "Port"
+	TRAP_OID_ARG   string = "Trap OID"
+	ENTERPRISE_ARG string = "Enterprise OID"
+	VARBINDS_ARG   string = "Varbinds"
+	MESSAGE_ARG    string = "Message OID"
+	INFORM_ARG     string = "Inform"
+	TIMEOUT_ARG    string = "Timeout"
+	RETRIES_ARG    string = "Retries"
+
+	// config keys of the trap listener, OIDs are named with keys starting with name:
+	PORT_CONFIG string = "port"
+
+	DEFAULT_PORT      string = "162"
+	DEFAULT_COMMUNITY string = "public"
+	DEFAULT_TIMEOUT   string = "5s"
+	DEFAULT_RETRIES   string = "2"
+
+	// MAX_PACKET_SIZE is the biggest UDP packet that can be received
+	MAX_PACKET_SIZE int = 65535
+)
+
+// SnmpChannel is a publish channel that receives SNMP v1 and v2c traps and informs over UDP
+type SnmpChannel struct {
+	// lock guards conn, names and communities
+	lock sync.Mutex
+	// conn is the connection traps are received on, nil when stopped
+	conn net.PacketConn
+	// names are the friendly names of OIDs
+	names map[string]string
+	// communities is the namespace of the source each community was configured for
+	communities map[string]string
+}
+
+func init() {
+	channels.Register(&SnmpChannel{})
+}
+
+func (*SnmpChannel) Name() string {
+	return "snmp"
+}
+
+func (*SnmpChannel) Description() string {
+	return "The SNMP channel receives SNMP v1 and v2c traps as events, and sends events as v2c traps or informs."
+}
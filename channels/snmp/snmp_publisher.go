@@ -0,0 +1,154 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events"
+	snmpparser "github.com/diggs/connectrix/parsers/snmp"
+	"github.com/diggs/glog"
+	"net"
+)
+
+func (*SnmpChannel) PubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        COMMUNITY_ARG,
+			Description: "The community the source's traps are sent with. Once a source sets a community, traps with other communities are ignored.",
+			Default:     "",
+		},
+	}
+}
+
+func (*SnmpChannel) ValidatePubChannelArgs(args map[string]string) error {
+	if len(args[COMMUNITY_ARG]) > 255 {
+		return errors.New("The community can't be longer than 255 characters")
+	}
+	// traps without a known community are created in the default namespace, so other namespaces must set one
+	if namespace := args[channels.NAMESPACE_ARG]; namespace != "" && namespace != config.DEFAULT_NAMESPACE && args[COMMUNITY_ARG] == "" {
+		return errors.New(fmt.Sprintf("A %s is required for sources outside the default namespace", COMMUNITY_ARG))
+	}
+	return nil
+}
+
+// PubChannelClaim returns the source's community, as traps sent with it are created in the source's namespace
+func (*SnmpChannel) PubChannelClaim(args map[string]string) string {
+	if community := args[COMMUNITY_ARG]; community != "" {
+		return fmt.Sprintf("community %s", community)
+	}
+	return ""
+}
+
+// PubChannelInfo returns the address traps should be sent to
+func (ch *SnmpChannel) PubChannelInfo(args map[string]string) []*channels.Info {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.conn == nil {
+		return nil
+	}
+	return []*channels.Info{
+		&channels.Info{Name: "Address", Description: "The UDP address to send traps to", Value: ch.conn.LocalAddr().String()},
+	}
+}
+
+// StartPubChannel listens for traps. The listener is only started if the port is configured or a source uses the
+// channel.
+func (ch *SnmpChannel) StartPubChannel(config map[string]string, pubChannelArgs []map[string]string) error {
+
+	port := config[PORT_CONFIG]
+	if port == "" {
+		if len(pubChannelArgs) == 0 {
+			return nil
+		}
+		port = DEFAULT_PORT
+	}
+
+	// traps are created in the namespace of the source whose community they were sent with, validation makes sure
+	// a community is only used by one namespace
+	communities := make(map[string]string)
+	for _, args := range pubChannelArgs {
+		if community := args[COMMUNITY_ARG]; community != "" {
+			communities[community] = args[channels.NAMESPACE_ARG]
+		}
+	}
+
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return err
+	}
+	ch.lock.Lock()
+	ch.conn = conn
+	ch.names = snmpparser.Names(config)
+	ch.communities = communities
+	ch.lock.Unlock()
+
+	glog.Infof("Starting SNMP channel on %s...", port)
+	buffer := make([]byte, MAX_PACKET_SIZE)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			// ignore the error if the connection was closed by StopPubChannel
+			ch.lock.Lock()
+			defer ch.lock.Unlock()
+			if ch.conn != conn {
+				return nil
+			}
+			return err
+		}
+		packet := append([]byte{}, buffer[:n]...)
+		go ch.receive(conn, addr, packet)
+	}
+}
+
+func (ch *SnmpChannel) StopPubChannel() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.conn == nil {
+		return nil
+	}
+	err := ch.conn.Close()
+	ch.conn = nil
+	return err
+}
+
+// receive creates an event from a trap, acknowledging it first if it is an inform
+func (ch *SnmpChannel) receive(conn net.PacketConn, addr net.Addr, packet []byte) {
+
+	trap, err := snmpparser.Decode(packet)
+	if err != nil {
+		glog.Debugf("Ignoring SNMP packet from %s: %v", addr, err)
+		return
+	}
+
+	ch.lock.Lock()
+	names := ch.names
+	namespace, known := ch.communities[trap.Community]
+	restricted := len(ch.communities) > 0
+	ch.lock.Unlock()
+
+	if restricted && !known {
+		glog.Warningf("Ignoring SNMP trap from %s with an unknown community", addr)
+		return
+	}
+	if namespace == "" {
+		namespace = config.DEFAULT_NAMESPACE
+	}
+
+	// the inform is acknowledged once it has been received, whether or not an event can be created from it
+	if trap.Inform {
+		_, err = conn.WriteTo(trap.Acknowledgement(packet), addr)
+		if err != nil {
+			glog.Warningf("Unable to acknowledge SNMP inform from %s: %v", addr, err)
+		}
+	}
+
+	sender := addr.String()
+	if host, _, err := net.SplitHostPort(sender); err == nil {
+		sender = host
+	}
+	_, err = events.ParseAndCreateEventFromChannel(ch.Name(), namespace, &packet, trap.Hints(sender, names), nil)
+	if err != nil {
+		glog.Warningf("Unable to create event from SNMP trap %s from %s: %v", trap.TrapOID, sender, err)
+	}
+}
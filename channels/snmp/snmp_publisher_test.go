@@ -0,0 +1,102 @@
+package snmp
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// linkDownInform is an SNMP v2c inform sent with the public community, with just the snmpTrapOID.0 varbind
+var linkDownInform = []byte{
+	0x30, 0x31,
+	0x02, 0x01, 0x01,
+	0x04, 0x06, 'p', 'u', 'b', 'l', 'i', 'c',
+	0xa6, 0x24,
+	0x02, 0x01, 0x01,
+	0x02, 0x01, 0x00,
+	0x02, 0x01, 0x00,
+	0x30, 0x19,
+	0x30, 0x17,
+	0x06, 0x0a, 0x2b, 0x06, 0x01, 0x06, 0x03, 0x01, 0x01, 0x04, 0x01, 0x00,
+	0x06, 0x09, 0x2b, 0x06, 0x01, 0x06, 0x03, 0x01, 0x01, 0x05, 0x03,
+}
+
+// start starts the channel on a free port, returning the address it is listening on
+func start(t *testing.T, ch *SnmpChannel, pubChannelArgs []map[string]string) string {
+	go ch.StartPubChannel(map[string]string{PORT_CONFIG: "0"}, pubChannelArgs)
+	for i := 0; i < 100; i++ {
+		if info := ch.PubChannelInfo(nil); info != nil {
+			_, port, _ := net.SplitHostPort(info[0].Value)
+			return "127.0.0.1:" + port
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("SNMP channel didn't start")
+	return ""
+}
+
+// send sends a packet to the channel and returns the response, or nil if there wasn't one
+func send(t *testing.T, address string, packet []byte) []byte {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.Write(packet)
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	response := make([]byte, 512)
+	n, err := conn.Read(response)
+	if err != nil {
+		return nil
+	}
+	return response[:n]
+}
+
+func TestAcknowledgesInform(t *testing.T) {
+
+	ch := &SnmpChannel{}
+	address := start(t, ch, nil)
+	defer ch.StopPubChannel()
+
+	response := send(t, address, linkDownInform)
+	if assert.Len(t, response, len(linkDownInform)) {
+		assert.Equal(t, byte(0xa2), response[13])
+		assert.Equal(t, linkDownInform[14:], response[14:])
+	}
+}
+
+func TestIgnoresUnknownCommunity(t *testing.T) {
+
+	ch := &SnmpChannel{}
+	address := start(t, ch, []map[string]string{{COMMUNITY_ARG: "s3cret", "Namespace": "network"}})
+	defer ch.StopPubChannel()
+
+	assert.Nil(t, send(t, address, linkDownInform))
+}
+
+func TestStopsPubChannel(t *testing.T) {
+
+	ch := &SnmpChannel{}
+	start(t, ch, nil)
+	assert.Nil(t, ch.StopPubChannel())
+	assert.Nil(t, ch.PubChannelInfo(nil))
+	assert.Nil(t, ch.StopPubChannel())
+}
+
+func TestValidatesPubChannelArgs(t *testing.T) {
+
+	ch := &SnmpChannel{}
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{COMMUNITY_ARG: ""}))
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{COMMUNITY_ARG: "", channels.NAMESPACE_ARG: config.DEFAULT_NAMESPACE}))
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{COMMUNITY_ARG: "s3cret", channels.NAMESPACE_ARG: "team-a"}))
+	// the traps would be created in the default namespace
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{COMMUNITY_ARG: "", channels.NAMESPACE_ARG: "team-a"}))
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{COMMUNITY_ARG: strings.Repeat("c", 256)}))
+
+	assert.Equal(t, "community s3cret", ch.PubChannelClaim(map[string]string{COMMUNITY_ARG: "s3cret"}))
+	assert.Equal(t, "", ch.PubChannelClaim(map[string]string{COMMUNITY_ARG: ""}))
+}
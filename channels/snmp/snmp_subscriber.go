@@ -0,0 +1,198 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/events/event"
+	snmpparser "github.com/diggs/connectrix/parsers/snmp"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// started is when Connectrix started, traps are sent with the time since as the uptime
+var started = time.Now()
+
+func (*SnmpChannel) SubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        HOST_ARG,
+			Description: "The host to send traps to, e.g. the NMS.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        PORT_ARG,
+			Description: "The UDP port to send traps to.",
+			Default:     DEFAULT_PORT,
+		},
+		&channels.Arg{
+			Name:        COMMUNITY_ARG,
+			Description: "The community to send traps with.",
+			Default:     DEFAULT_COMMUNITY,
+		},
+		&channels.Arg{
+			Name:        TRAP_OID_ARG,
+			Description: "The OID identifying the trap, sent as snmpTrapOID.0.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        ENTERPRISE_ARG,
+			Description: "The enterprise OID of the trap, sent as snmpTrapEnterprise.0 if given.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        VARBINDS_ARG,
+			Description: "The varbinds to send, one per line as <oid> <type> <value>, where the type is i, u, c, t, a, o, s, x or n as used by snmptrap.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        MESSAGE_ARG,
+			Description: "An OID to send the event content as a string varbind with, leave blank to not send the content.",
+			Default:     "",
+		},
+		&channels.Arg{
+			Name:        INFORM_ARG,
+			Description: "Set to true to send an inform, which is resent until the host acknowledges it, instead of a trap.",
+			Default:     "false",
+		},
+		&channels.Arg{
+			Name:        TIMEOUT_ARG,
+			Description: "How long to wait for an inform to be acknowledged before resending it.",
+			Default:     DEFAULT_TIMEOUT,
+		},
+		&channels.Arg{
+			Name:        RETRIES_ARG,
+			Description: "How many times to resend an inform that isn't acknowledged.",
+			Default:     DEFAULT_RETRIES,
+		},
+	}
+}
+
+func (*SnmpChannel) ValidateSubChannelArgs(args map[string]string) error {
+
+	port, err := strconv.Atoi(args[PORT_ARG])
+	if err != nil || port <= 0 || port > 65535 {
+		return errors.New(fmt.Sprintf("Invalid port: %s", args[PORT_ARG]))
+	}
+	if _, err := strconv.ParseBool(args[INFORM_ARG]); err != nil {
+		return errors.New(fmt.Sprintf("%s must be true or false", INFORM_ARG))
+	}
+	if timeout, err := time.ParseDuration(args[TIMEOUT_ARG]); err != nil || timeout <= 0 {
+		return errors.New(fmt.Sprintf("Invalid timeout: %s", args[TIMEOUT_ARG]))
+	}
+	if retries, err := strconv.Atoi(args[RETRIES_ARG]); err != nil || retries < 0 {
+		return errors.New(fmt.Sprintf("Invalid retries: %s", args[RETRIES_ARG]))
+	}
+
+	// OIDs and varbinds can be templated, in which case they can only be checked once they have been templated
+	for _, name := range []string{TRAP_OID_ARG, ENTERPRISE_ARG, MESSAGE_ARG} {
+		if args[name] != "" && !strings.Contains(args[name], "{{") {
+			if err := snmpparser.ValidateOID(args[name]); err != nil {
+				return errors.New(fmt.Sprintf("%s: %v", name, err))
+			}
+		}
+	}
+	if !strings.Contains(args[VARBINDS_ARG], "{{") {
+		if _, err := snmpparser.ParseVarbinds(args[VARBINDS_ARG]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (*SnmpChannel) SubChannelInfo(map[string]string) []*channels.Info {
+	return nil
+}
+
+func (*SnmpChannel) StartSubChannel(config map[string]string) error {
+	return nil
+}
+
+func (*SnmpChannel) Drain(args map[string]string, event *event.Event, content string) error {
+
+	varbinds, err := snmpparser.ParseVarbinds(args[VARBINDS_ARG])
+	if err != nil {
+		return err
+	}
+	if oid := strings.TrimSpace(args[MESSAGE_ARG]); oid != "" {
+		message, err := snmpparser.EncodeVarbind(oid, "s", content)
+		if err != nil {
+			return err
+		}
+		varbinds = append(varbinds, message)
+	}
+
+	community := args[COMMUNITY_ARG]
+	if community == "" {
+		community = DEFAULT_COMMUNITY
+	}
+	inform, _ := strconv.ParseBool(args[INFORM_ARG])
+	requestID := rand.Int31()
+	uptime := uint32(time.Since(started) / (10 * time.Millisecond))
+
+	packet, err := snmpparser.EncodeTrap(community, inform, requestID, uptime, strings.TrimSpace(args[TRAP_OID_ARG]), strings.TrimSpace(args[ENTERPRISE_ARG]), varbinds)
+	if err != nil {
+		return err
+	}
+	return sendTrap(args, packet, inform, requestID)
+}
+
+// sendTrap sends the packet, and for informs waits for the acknowledgement, resending the inform each time the timeout
+// passes without one
+func sendTrap(args map[string]string, packet []byte, inform bool, requestID int32) error {
+
+	port := args[PORT_ARG]
+	if port == "" {
+		port = DEFAULT_PORT
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(args[HOST_ARG], port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if !inform {
+		_, err = conn.Write(packet)
+		return err
+	}
+
+	timeout, err := time.ParseDuration(args[TIMEOUT_ARG])
+	if err != nil || timeout <= 0 {
+		timeout, _ = time.ParseDuration(DEFAULT_TIMEOUT)
+	}
+	retries, err := strconv.Atoi(args[RETRIES_ARG])
+	if err != nil || retries < 0 {
+		retries, _ = strconv.Atoi(DEFAULT_RETRIES)
+	}
+
+	response := make([]byte, MAX_PACKET_SIZE)
+	for attempt := 0; attempt <= retries; attempt++ {
+		_, err = conn.Write(packet)
+		if err != nil {
+			return err
+		}
+		deadline := time.Now().Add(timeout)
+		conn.SetReadDeadline(deadline)
+		for time.Now().Before(deadline) {
+			n, err := conn.Read(response)
+			if err != nil {
+				break
+			}
+			// ignore anything that isn't the acknowledgement of this inform
+			id, errorStatus, err := snmpparser.DecodeResponse(response[:n])
+			if err != nil || id != int64(requestID) {
+				continue
+			}
+			if errorStatus != 0 {
+				return errors.New(fmt.Sprintf("%s rejected the inform with error status %d", args[HOST_ARG], errorStatus))
+			}
+			return nil
+		}
+	}
+
+	return errors.New(fmt.Sprintf("%s didn't acknowledge the inform", args[HOST_ARG]))
+}
@@ -0,0 +1,102 @@
+package snmp
+
+import (
+	"github.com/diggs/connectrix/events/event"
+	snmpparser "github.com/diggs/connectrix/parsers/snmp"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+func drainArgs(address string) map[string]string {
+	host, port, _ := net.SplitHostPort(address)
+	return map[string]string{
+		HOST_ARG:       host,
+		PORT_ARG:       port,
+		TRAP_OID_ARG:   "1.3.6.1.4.1.8072.9999.0.1",
+		ENTERPRISE_ARG: "1.3.6.1.4.1.8072.9999",
+		VARBINDS_ARG:   "1.3.6.1.4.1.8072.9999.1 s connectrix\n1.3.6.1.4.1.8072.9999.2 i 42",
+		MESSAGE_ARG:    "1.3.6.1.4.1.8072.9999.3",
+		TIMEOUT_ARG:    "100ms",
+		RETRIES_ARG:    "1",
+	}
+}
+
+func TestSendsTrap(t *testing.T) {
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	ch := &SnmpChannel{}
+	err = ch.Drain(drainArgs(listener.LocalAddr().String()), &event.Event{}, "Build failed")
+	assert.Nil(t, err)
+
+	packet := make([]byte, MAX_PACKET_SIZE)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFrom(packet)
+	if !assert.Nil(t, err) {
+		return
+	}
+	trap, err := snmpparser.Decode(packet[:n])
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "public", trap.Community)
+	assert.False(t, trap.Inform)
+	assert.Equal(t, "1.3.6.1.4.1.8072.9999.0.1", trap.TrapOID)
+	assert.Equal(t, []snmpparser.Varbind{
+		{OID: snmpparser.SNMP_TRAP_ENTERPRISE_OID, Value: "1.3.6.1.4.1.8072.9999"},
+		{OID: "1.3.6.1.4.1.8072.9999.1", Value: "connectrix"},
+		{OID: "1.3.6.1.4.1.8072.9999.2", Value: int64(42)},
+		{OID: "1.3.6.1.4.1.8072.9999.3", Value: "Build failed"},
+	}, trap.Varbinds[2:])
+}
+
+func TestSendsInform(t *testing.T) {
+
+	// the publish channel acknowledges informs
+	receiver := &SnmpChannel{}
+	address := start(t, receiver, nil)
+	defer receiver.StopPubChannel()
+
+	args := drainArgs(address)
+	args[INFORM_ARG] = "true"
+	assert.Nil(t, (&SnmpChannel{}).Drain(args, &event.Event{}, "Build failed"))
+}
+
+func TestInformNotAcknowledged(t *testing.T) {
+
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	args := drainArgs(listener.LocalAddr().String())
+	args[INFORM_ARG] = "true"
+	err = (&SnmpChannel{}).Drain(args, &event.Event{}, "Build failed")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "didn't acknowledge the inform")
+	}
+}
+
+func TestValidatesSubChannelArgs(t *testing.T) {
+
+	ch := &SnmpChannel{}
+	valid := func() map[string]string {
+		return map[string]string{HOST_ARG: "nms.example.com", PORT_ARG: "162", COMMUNITY_ARG: "public", TRAP_OID_ARG: "1.3.6.1.4.1.8072.9999.0.1",
+			VARBINDS_ARG: "1.3.6.1.4.1.8072.9999.1 s {{.repository.name}}", INFORM_ARG: "false", TIMEOUT_ARG: "5s", RETRIES_ARG: "2"}
+	}
+	assert.Nil(t, ch.ValidateSubChannelArgs(valid()))
+
+	for arg, val := range map[string]string{PORT_ARG: "snmp", TRAP_OID_ARG: "linkDown", ENTERPRISE_ARG: "1", VARBINDS_ARG: "1.3.6.1.4.1.8072.9999.1 i many",
+		INFORM_ARG: "maybe", TIMEOUT_ARG: "soon", RETRIES_ARG: "-1"} {
+		args := valid()
+		args[arg] = val
+		assert.NotNil(t, ch.ValidateSubChannelArgs(args), arg)
+	}
+}
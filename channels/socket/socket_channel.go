@@ -0,0 +1,53 @@
+package socket
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	PROTOCOL_ARG string = "Protocol"
+	HOST_ARG     string = "Host"
+	PORT_ARG     string = "Port"
+	NEWLINE_ARG  string = "Newline"
+
+	PROTOCOL_TCP string = "tcp"
+	PROTOCOL_UDP string = "udp"
+
+	PROTOCOL_HINT  string = "socket:protocol"
+	PORT_HINT      string = "socket:port"
+	PEER_HINT      string = "socket:peer"
+	PEER_PORT_HINT string = "socket:peer_port"
+
+	// MAX_LINE_SIZE is the longest line or biggest datagram that can be received
+	MAX_LINE_SIZE int = 65535
+	// IDLE_TIMEOUT is how long a TCP connection may go without sending a line before it is closed
+	IDLE_TIMEOUT time.Duration = 5 * time.Minute
+	// SEND_TIMEOUT is how long sending an event may take, from connecting to writing the content
+	SEND_TIMEOUT time.Duration = 30 * time.Second
+)
+
+// SocketChannel receives each line sent over TCP, or each UDP datagram, as an event, and writes events to TCP or
+// UDP endpoints
+type SocketChannel struct {
+	// lock guards listeners and addresses
+	lock sync.Mutex
+	// listeners are the TCP listeners and UDP connections the publish channel is receiving on, nil when stopped
+	listeners []io.Closer
+	// addresses is the address each listener is receiving on, keyed by protocol/port as configured
+	addresses map[string]string
+}
+
+func init() {
+	channels.Register(&SocketChannel{})
+}
+
+func (*SocketChannel) Name() string {
+	return "socket"
+}
+
+func (*SocketChannel) Description() string {
+	return "The socket channel receives lines sent over TCP, or UDP datagrams, as events, and writes events to TCP or UDP endpoints."
+}
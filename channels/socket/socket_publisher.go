@@ -0,0 +1,251 @@
+package socket
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/glog"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (*SocketChannel) PubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        PROTOCOL_ARG,
+			Description: "The protocol to listen with: tcp, where each line is an event, or udp, where each datagram is an event.",
+			Default:     PROTOCOL_TCP,
+		},
+		&channels.Arg{
+			Name:        PORT_ARG,
+			Description: "The port to listen on. Events received on it are created in the namespace of the source.",
+			Required:    true,
+		},
+	}
+}
+
+func (*SocketChannel) ValidatePubChannelArgs(args map[string]string) error {
+	return validateArgs(args, false)
+}
+
+// validateArgs validates the protocol and port args, which the publish and subscribe channels share. Route args are
+// templated before delivery, so if templated is true then values containing a template aren't checked.
+func validateArgs(args map[string]string, templated bool) error {
+	isTemplate := func(name string) bool {
+		return templated && strings.Contains(args[name], "{{")
+	}
+	if protocol := args[PROTOCOL_ARG]; protocol != PROTOCOL_TCP && protocol != PROTOCOL_UDP && !isTemplate(PROTOCOL_ARG) {
+		return errors.New(fmt.Sprintf("Unknown protocol '%s', expected %s or %s", protocol, PROTOCOL_TCP, PROTOCOL_UDP))
+	}
+	if isTemplate(PORT_ARG) {
+		return nil
+	}
+	if port, err := strconv.ParseUint(args[PORT_ARG], 10, 16); err != nil || port == 0 {
+		return errors.New(fmt.Sprintf("Invalid port '%s'", args[PORT_ARG]))
+	}
+	return nil
+}
+
+// listenerKey identifies the listener for the args, e.g. tcp/5000
+func listenerKey(args map[string]string) string {
+	protocol := args[PROTOCOL_ARG]
+	if protocol == "" {
+		protocol = PROTOCOL_TCP
+	}
+	return fmt.Sprintf("%s/%s", protocol, args[PORT_ARG])
+}
+
+// PubChannelClaim returns the source's protocol and port, as events received on it are created in the source's
+// namespace
+func (*SocketChannel) PubChannelClaim(args map[string]string) string {
+	return fmt.Sprintf("socket %s", listenerKey(args))
+}
+
+// PubChannelInfo returns the address the source's events should be sent to
+func (ch *SocketChannel) PubChannelInfo(args map[string]string) []*channels.Info {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	address, exists := ch.addresses[listenerKey(args)]
+	if !exists {
+		return nil
+	}
+	return []*channels.Info{
+		&channels.Info{Name: "Address", Description: "The address to send events to", Value: address},
+	}
+}
+
+// StartPubChannel listens on the protocol and port of each source. Sources in different namespaces can't share a
+// port, validation rejects such configs and a shared port isn't listened on, as its events would belong to neither.
+func (ch *SocketChannel) StartPubChannel(config map[string]string, pubChannelArgs []map[string]string) error {
+
+	// each protocol and port is listened on once, for the sources of one namespace
+	keys := []string{}
+	listenArgs := make(map[string]map[string]string)
+	shared := make(map[string]bool)
+	for _, args := range pubChannelArgs {
+		argsWithDefaults, err := channels.WithDefaults(ch.PubChannelArgs(), args)
+		if err != nil {
+			glog.Warningf("Unable to start socket listener: %v", err)
+			continue
+		}
+		key := listenerKey(argsWithDefaults)
+		if existing, exists := listenArgs[key]; exists {
+			if existing[channels.NAMESPACE_ARG] != argsWithDefaults[channels.NAMESPACE_ARG] {
+				shared[key] = true
+			}
+			continue
+		}
+		keys = append(keys, key)
+		listenArgs[key] = argsWithDefaults
+	}
+
+	listeners := []io.Closer{}
+	addresses := make(map[string]string)
+	for _, key := range keys {
+		if shared[key] {
+			glog.Warningf("Not listening on socket %s, it is used by sources in more than one namespace", key)
+			continue
+		}
+		args := listenArgs[key]
+		listener, address, err := listen(args[PROTOCOL_ARG], args[PORT_ARG], ch.deliver(args[channels.NAMESPACE_ARG]))
+		if err != nil {
+			glog.Warningf("Unable to listen on socket %s: %v", key, err)
+			continue
+		}
+		glog.Infof("Starting socket channel on %s...", key)
+		listeners = append(listeners, listener)
+		addresses[key] = address
+	}
+
+	ch.lock.Lock()
+	ch.listeners = listeners
+	ch.addresses = addresses
+	ch.lock.Unlock()
+	return nil
+}
+
+func (ch *SocketChannel) StopPubChannel() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	var err error
+	for _, listener := range ch.listeners {
+		if closeErr := listener.Close(); closeErr != nil {
+			err = closeErr
+		}
+	}
+	ch.listeners = nil
+	ch.addresses = nil
+	return err
+}
+
+// listen starts receiving events on the port, returning the listener and the address it is listening on
+func listen(protocol string, port string, deliver func(*[]byte, []event.Hint, net.Addr)) (io.Closer, string, error) {
+	if protocol == PROTOCOL_UDP {
+		conn, err := net.ListenPacket("udp", fmt.Sprintf(":%s", port))
+		if err != nil {
+			return nil, "", err
+		}
+		go serveUDP(conn, deliver)
+		return conn, conn.LocalAddr().String(), nil
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return nil, "", err
+	}
+	go serveTCP(listener, deliver)
+	return listener, listener.Addr().String(), nil
+}
+
+// serveTCP reads lines from each connection until the listener is closed
+func serveTCP(listener net.Listener, deliver func(*[]byte, []event.Hint, net.Addr)) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			glog.Debugf("No longer accepting socket connections on %s: %v", listener.Addr(), err)
+			return
+		}
+		go readLines(conn, deliver)
+	}
+}
+
+// readLines delivers each line sent over the connection as an event, in order, until the connection is closed or
+// goes idle. Blank lines are ignored, and the connection is closed if a line is longer than MAX_LINE_SIZE.
+func readLines(conn net.Conn, deliver func(*[]byte, []event.Hint, net.Addr)) {
+
+	defer conn.Close()
+	peer := conn.RemoteAddr()
+	hints := makeHints(PROTOCOL_TCP, conn.LocalAddr(), peer)
+
+	reader := bufio.NewReaderSize(conn, MAX_LINE_SIZE)
+	for {
+		conn.SetReadDeadline(time.Now().Add(IDLE_TIMEOUT))
+		line, err := reader.ReadSlice('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err != io.EOF {
+				glog.Debugf("Closing socket connection from %s: %v", peer, err)
+			}
+			return
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(bytes.TrimSpace(line)) > 0 {
+			data := append([]byte{}, line...)
+			deliver(&data, hints, peer)
+		}
+	}
+}
+
+// serveUDP delivers each datagram as an event until the connection is closed. A trailing newline is removed, as
+// sent by nc, and empty datagrams are ignored.
+func serveUDP(conn net.PacketConn, deliver func(*[]byte, []event.Hint, net.Addr)) {
+	buffer := make([]byte, MAX_LINE_SIZE)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			glog.Debugf("No longer receiving socket datagrams on %s: %v", conn.LocalAddr(), err)
+			return
+		}
+		line := bytes.TrimRight(buffer[:n], "\r\n")
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		data := append([]byte{}, line...)
+		go deliver(&data, makeHints(PROTOCOL_UDP, conn.LocalAddr(), addr), addr)
+	}
+}
+
+// makeHints returns the socket:protocol, socket:port (the port the event was received on), socket:peer and
+// socket:peer_port hints
+func makeHints(protocol string, local net.Addr, peer net.Addr) []event.Hint {
+	_, port, _ := net.SplitHostPort(local.String())
+	peerHost, peerPort, err := net.SplitHostPort(peer.String())
+	if err != nil {
+		peerHost = peer.String()
+	}
+	return []event.Hint{
+		{Key: PROTOCOL_HINT, Value: protocol, Text: "Protocol:" + protocol},
+		{Key: PORT_HINT, Value: port, Text: "Port:" + port},
+		{Key: PEER_HINT, Value: peerHost, Text: "Peer:" + peerHost},
+		{Key: PEER_PORT_HINT, Value: peerPort},
+	}
+}
+
+// deliver returns a function creating events in the namespace
+func (ch *SocketChannel) deliver(namespace string) func(*[]byte, []event.Hint, net.Addr) {
+	if namespace == "" {
+		namespace = config.DEFAULT_NAMESPACE
+	}
+	return func(data *[]byte, hints []event.Hint, peer net.Addr) {
+		_, err := events.ParseAndCreateEventFromChannel(ch.Name(), namespace, data, hints, nil)
+		if err != nil {
+			glog.Warningf("Unable to create event from socket data from %s: %v", peer, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package socket
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/events/event"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (*SocketChannel) SubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        PROTOCOL_ARG,
+			Description: "The protocol to send events with: tcp, or udp to send each event as a datagram.",
+			Default:     PROTOCOL_TCP,
+		},
+		&channels.Arg{
+			Name:        HOST_ARG,
+			Description: "The host to send events to.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        PORT_ARG,
+			Description: "The port to send events to.",
+			Required:    true,
+		},
+		&channels.Arg{
+			Name:        NEWLINE_ARG,
+			Description: "Set to false to not end each event with a newline.",
+			Default:     "true",
+		},
+	}
+}
+
+func (*SocketChannel) ValidateSubChannelArgs(args map[string]string) error {
+	err := validateArgs(args, true)
+	if err != nil {
+		return err
+	}
+	if _, err := strconv.ParseBool(args[NEWLINE_ARG]); err != nil && !strings.Contains(args[NEWLINE_ARG], "{{") {
+		return errors.New(fmt.Sprintf("%s must be true or false", NEWLINE_ARG))
+	}
+	return nil
+}
+
+func (*SocketChannel) SubChannelInfo(map[string]string) []*channels.Info {
+	return nil
+}
+
+func (*SocketChannel) StartSubChannel(config map[string]string) error {
+	return nil
+}
+
+// Drain writes the content to the endpoint over a new connection, which is closed once the content is written
+func (*SocketChannel) Drain(args map[string]string, event *event.Event, content string) error {
+
+	protocol := args[PROTOCOL_ARG]
+	if protocol == "" {
+		protocol = PROTOCOL_TCP
+	}
+	newline, err := strconv.ParseBool(args[NEWLINE_ARG])
+	if err != nil {
+		newline = true
+	}
+
+	conn, err := net.DialTimeout(protocol, net.JoinHostPort(args[HOST_ARG], args[PORT_ARG]), SEND_TIMEOUT)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetWriteDeadline(time.Now().Add(SEND_TIMEOUT))
+
+	data := []byte(content)
+	if newline {
+		data = append(data, '\n')
+	}
+	_, err = conn.Write(data)
+	return err
+}
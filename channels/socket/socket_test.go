@@ -0,0 +1,206 @@
+package socket
+
+import (
+	"bufio"
+	"bytes"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"testing"
+	"time"
+)
+
+type received struct {
+	data  string
+	hints []event.Hint
+}
+
+// capture returns a deliver function that sends what it's given to the returned channel
+func capture() (func(*[]byte, []event.Hint, net.Addr), chan received) {
+	c := make(chan received, 10)
+	return func(data *[]byte, hints []event.Hint, peer net.Addr) {
+		c <- received{data: string(*data), hints: hints}
+	}, c
+}
+
+func next(t *testing.T, c chan received) received {
+	select {
+	case r := <-c:
+		return r
+	case <-time.After(time.Second):
+		t.Fatal("Nothing was received")
+		return received{}
+	}
+}
+
+func TestReceivesLines(t *testing.T) {
+
+	deliver, c := capture()
+	listener, address, err := listen(PROTOCOL_TCP, "0", deliver)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	_, port, _ := net.SplitHostPort(address)
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	if !assert.Nil(t, err) {
+		return
+	}
+	conn.Write([]byte("{\"status\":\"failed\"}\r\n\n  \n{\"status\":\"fixed\"}"))
+	conn.Close()
+
+	first := next(t, c)
+	assert.Equal(t, "{\"status\":\"failed\"}", first.data)
+	assert.Equal(t, event.Hint{Key: PROTOCOL_HINT, Value: "tcp", Text: "Protocol:tcp"}, first.hints[0])
+	assert.Equal(t, event.Hint{Key: PORT_HINT, Value: port, Text: "Port:" + port}, first.hints[1])
+	assert.Equal(t, event.Hint{Key: PEER_HINT, Value: "127.0.0.1", Text: "Peer:127.0.0.1"}, first.hints[2])
+	assert.NotEqual(t, "", first.hints[3].Value)
+	assert.Equal(t, "{\"status\":\"fixed\"}", next(t, c).data)
+}
+
+func TestReceivesDatagrams(t *testing.T) {
+
+	deliver, c := capture()
+	listener, address, err := listen(PROTOCOL_UDP, "0", deliver)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	_, port, _ := net.SplitHostPort(address)
+	conn, err := net.Dial("udp", "127.0.0.1:"+port)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte("\n"))
+	conn.Write([]byte("disk full\non db1\n"))
+
+	r := next(t, c)
+	assert.Equal(t, "disk full\non db1", r.data)
+	assert.Equal(t, "udp", r.hints[0].Value)
+	assert.Equal(t, "127.0.0.1", r.hints[2].Value)
+}
+
+func TestStartsAndStopsPubChannel(t *testing.T) {
+
+	ch := &SocketChannel{}
+	err := ch.StartPubChannel(nil, []map[string]string{
+		{PORT_ARG: "0", "Namespace": "ops"},
+		{PROTOCOL_ARG: PROTOCOL_UDP, PORT_ARG: "0", "Namespace": "ops"},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, ch.PubChannelInfo(map[string]string{PORT_ARG: "0"}), 1)
+	assert.Len(t, ch.PubChannelInfo(map[string]string{PROTOCOL_ARG: PROTOCOL_UDP, PORT_ARG: "0"}), 1)
+	assert.Nil(t, ch.PubChannelInfo(map[string]string{PORT_ARG: "5000"}))
+
+	address := ch.PubChannelInfo(map[string]string{PORT_ARG: "0"})[0].Value
+	assert.Nil(t, ch.StopPubChannel())
+	assert.Nil(t, ch.PubChannelInfo(map[string]string{PORT_ARG: "0"}))
+	_, err = net.Dial("tcp", address)
+	assert.NotNil(t, err)
+}
+
+func TestDoesntListenOnPortSharedBetweenNamespaces(t *testing.T) {
+
+	ch := &SocketChannel{}
+	err := ch.StartPubChannel(nil, []map[string]string{
+		{PORT_ARG: "0", "Namespace": "ops"},
+		{PORT_ARG: "0", "Namespace": "dev"},
+		{PROTOCOL_ARG: PROTOCOL_UDP, PORT_ARG: "0", "Namespace": "ops"},
+		{PROTOCOL_ARG: PROTOCOL_UDP, PORT_ARG: "0", "Namespace": "ops"},
+	})
+	assert.Nil(t, err)
+	assert.Nil(t, ch.PubChannelInfo(map[string]string{PORT_ARG: "0"}))
+	assert.Len(t, ch.PubChannelInfo(map[string]string{PROTOCOL_ARG: PROTOCOL_UDP, PORT_ARG: "0"}), 1)
+	assert.Nil(t, ch.StopPubChannel())
+
+	// validation rejects the config, the protocol defaults to tcp
+	assert.Equal(t, "socket tcp/5000", ch.PubChannelClaim(map[string]string{PORT_ARG: "5000"}))
+	assert.Equal(t, "socket udp/5000", ch.PubChannelClaim(map[string]string{PROTOCOL_ARG: PROTOCOL_UDP, PORT_ARG: "5000"}))
+}
+
+func TestDrainsOverTCP(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	ch := &SocketChannel{}
+	err = ch.Drain(map[string]string{HOST_ARG: host, PORT_ARG: port}, &event.Event{}, "Build 42 failed")
+	assert.Nil(t, err)
+
+	conn, err := listener.Accept()
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, "Build 42 failed\n", line)
+}
+
+func TestDrainsOverUDP(t *testing.T) {
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	host, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	ch := &SocketChannel{}
+	err = ch.Drain(map[string]string{PROTOCOL_ARG: PROTOCOL_UDP, HOST_ARG: host, PORT_ARG: port, NEWLINE_ARG: "false"}, &event.Event{}, "Build 42 failed")
+	assert.Nil(t, err)
+
+	buffer := make([]byte, 512)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buffer)
+	assert.Nil(t, err)
+	assert.Equal(t, "Build 42 failed", string(buffer[:n]))
+}
+
+func TestValidatesArgs(t *testing.T) {
+
+	ch := &SocketChannel{}
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{PROTOCOL_ARG: PROTOCOL_UDP, PORT_ARG: "5000"}))
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{PROTOCOL_ARG: "sctp", PORT_ARG: "5000"}))
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{PROTOCOL_ARG: PROTOCOL_TCP, PORT_ARG: "0"}))
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{PROTOCOL_ARG: PROTOCOL_TCP, PORT_ARG: "70000"}))
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{PROTOCOL_ARG: PROTOCOL_TCP, PORT_ARG: "{{.port}}"}))
+
+	assert.Nil(t, ch.ValidateSubChannelArgs(map[string]string{PROTOCOL_ARG: PROTOCOL_TCP, HOST_ARG: "localhost", PORT_ARG: "5000", NEWLINE_ARG: "true"}))
+	assert.NotNil(t, ch.ValidateSubChannelArgs(map[string]string{PROTOCOL_ARG: PROTOCOL_TCP, HOST_ARG: "localhost", PORT_ARG: "5000", NEWLINE_ARG: "yes please"}))
+	// route args are templated before they're used
+	assert.Nil(t, ch.ValidateSubChannelArgs(map[string]string{PROTOCOL_ARG: PROTOCOL_TCP, HOST_ARG: "{{.host}}", PORT_ARG: "{{.port}}", NEWLINE_ARG: "true"}))
+}
+
+func TestClosesConnectionOnLongLine(t *testing.T) {
+
+	deliver, c := capture()
+	listener, address, err := listen(PROTOCOL_TCP, "0", deliver)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	_, port, _ := net.SplitHostPort(address)
+	conn, err := net.Dial("tcp", "127.0.0.1:"+port)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte("short\n"))
+	conn.Write(append(bytes.Repeat([]byte("x"), MAX_LINE_SIZE+1), '\n'))
+
+	assert.Equal(t, "short", next(t, c).data)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+	assert.Len(t, c, 0)
+}
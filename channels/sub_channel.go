@@ -6,17 +6,19 @@ import (
 
 // Info represents a piece of data needed to be passed to an external system to work with a channel
 type Info struct {
-	Name        string
-	Description string
-	Value       string
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Value       string `json:"value"`
 }
 
-// Arg represents a piece of data needed to configure to a channel
+// Arg represents a piece of data needed to configure to a channel. Secret args, such as passwords, are redacted
+// when the config is returned by the management API.
 type Arg struct {
-	Name        string
-	Description string
-	Default     string
-	Required    bool
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+	Required    bool   `json:"required"`
+	Secret      bool   `json:"secret,omitempty"`
 }
 
 // SubChannel can be implemented to provide a channel that sends events to an external system
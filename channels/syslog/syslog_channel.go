@@ -0,0 +1,46 @@
+package syslog
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	SENDER_ARG string = "Sender"
+
+	// config keys of the syslog listeners
+	PORT_CONFIG string = "port"
+
+	DEFAULT_PORT string = "514"
+
+	// MAX_MESSAGE_SIZE is the biggest message that can be received
+	MAX_MESSAGE_SIZE int = 65535
+	// IDLE_TIMEOUT is how long a TCP connection may go without sending a message before it is closed
+	IDLE_TIMEOUT time.Duration = 10 * time.Minute
+)
+
+// SyslogChannel is a publish channel that receives syslog messages over UDP and TCP
+type SyslogChannel struct {
+	// lock guards conn, listener and namespaces
+	lock sync.Mutex
+	// conn is the connection UDP messages are received on, nil when stopped
+	conn net.PacketConn
+	// listener is the listener TCP connections are accepted on, nil when stopped
+	listener net.Listener
+	// namespaces is the namespace of the source each sender was configured for, keyed by IP address
+	namespaces map[string]string
+}
+
+func init() {
+	channels.Register(&SyslogChannel{})
+}
+
+func (*SyslogChannel) Name() string {
+	return "syslog"
+}
+
+func (*SyslogChannel) Description() string {
+	return "The syslog channel receives RFC 5424 and RFC 3164 syslog messages over UDP and TCP as events."
+}
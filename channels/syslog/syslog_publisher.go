@@ -0,0 +1,225 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events"
+	syslogparser "github.com/diggs/connectrix/parsers/syslog"
+	"github.com/diggs/glog"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func (*SyslogChannel) PubChannelArgs() []*channels.Arg {
+	return []*channels.Arg{
+		&channels.Arg{
+			Name:        SENDER_ARG,
+			Description: "The IP address the source's messages are sent from, which decides the namespace the events are created in. The hostname in a message is chosen by the sender, so it isn't used.",
+			Default:     "",
+		},
+	}
+}
+
+func (*SyslogChannel) ValidatePubChannelArgs(args map[string]string) error {
+	if sender := args[SENDER_ARG]; sender != "" && net.ParseIP(sender) == nil {
+		return errors.New(fmt.Sprintf("Invalid %s '%s', expected an IP address", SENDER_ARG, sender))
+	}
+	return nil
+}
+
+// PubChannelClaim returns the source's sender, as messages sent from it are created in the source's namespace
+func (*SyslogChannel) PubChannelClaim(args map[string]string) string {
+	if ip := net.ParseIP(args[SENDER_ARG]); ip != nil {
+		return fmt.Sprintf("sender %s", ip.String())
+	}
+	return ""
+}
+
+// PubChannelInfo returns the addresses syslog messages should be sent to
+func (ch *SyslogChannel) PubChannelInfo(args map[string]string) []*channels.Info {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.conn == nil || ch.listener == nil {
+		return nil
+	}
+	return []*channels.Info{
+		&channels.Info{Name: "UDP Address", Description: "The UDP address to send syslog messages to", Value: ch.conn.LocalAddr().String()},
+		&channels.Info{Name: "TCP Address", Description: "The TCP address to send syslog messages to", Value: ch.listener.Addr().String()},
+	}
+}
+
+// StartPubChannel listens for syslog messages on the same port over UDP and TCP. The listeners are only started if
+// the port is configured or a source uses the channel.
+func (ch *SyslogChannel) StartPubChannel(config map[string]string, pubChannelArgs []map[string]string) error {
+
+	port := config[PORT_CONFIG]
+	if port == "" {
+		if len(pubChannelArgs) == 0 {
+			return nil
+		}
+		port = DEFAULT_PORT
+	}
+
+	// messages are created in the namespace of the source whose address they were sent from, validation makes sure
+	// a sender is only used by one namespace
+	namespaces := make(map[string]string)
+	for _, args := range pubChannelArgs {
+		if ip := net.ParseIP(args[SENDER_ARG]); ip != nil {
+			namespaces[ip.String()] = args[channels.NAMESPACE_ARG]
+		}
+	}
+
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%s", port))
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	ch.lock.Lock()
+	ch.conn = conn
+	ch.listener = listener
+	ch.namespaces = namespaces
+	ch.lock.Unlock()
+
+	glog.Infof("Starting syslog channel on %s...", port)
+	go ch.serveUDP(conn)
+	for {
+		tcpConn, err := listener.Accept()
+		if err != nil {
+			// ignore the error if the listener was closed by StopPubChannel
+			ch.lock.Lock()
+			defer ch.lock.Unlock()
+			if ch.listener != listener {
+				return nil
+			}
+			return err
+		}
+		go ch.serveTCP(tcpConn)
+	}
+}
+
+func (ch *SyslogChannel) StopPubChannel() error {
+	ch.lock.Lock()
+	defer ch.lock.Unlock()
+	if ch.conn == nil {
+		return nil
+	}
+	err := ch.conn.Close()
+	if listenerErr := ch.listener.Close(); listenerErr != nil {
+		err = listenerErr
+	}
+	ch.conn = nil
+	ch.listener = nil
+	return err
+}
+
+// serveUDP receives a message in each datagram until the connection is closed
+func (ch *SyslogChannel) serveUDP(conn net.PacketConn) {
+	buffer := make([]byte, MAX_MESSAGE_SIZE)
+	for {
+		n, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			glog.Debugf("No longer receiving syslog datagrams on %s: %v", conn.LocalAddr(), err)
+			return
+		}
+		data := append([]byte{}, buffer[:n]...)
+		go ch.receive(data, addr)
+	}
+}
+
+// serveTCP receives messages from the connection, in order, until it is closed or goes idle
+func (ch *SyslogChannel) serveTCP(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReaderSize(conn, MAX_MESSAGE_SIZE)
+	for {
+		conn.SetReadDeadline(time.Now().Add(IDLE_TIMEOUT))
+		data, err := readMessage(reader)
+		if err != nil {
+			if err != io.EOF {
+				glog.Debugf("Closing syslog connection from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(bytes.TrimSpace(data)) > 0 {
+			ch.receive(data, conn.RemoteAddr())
+		}
+	}
+}
+
+// readMessage reads a message framed as described by RFC 6587, either by octet counting (the length of the message
+// and a space before the message) or by ending the message with a newline. Octet counting is used if the message
+// starts with a digit, as a message must start with a <.
+func readMessage(reader *bufio.Reader) ([]byte, error) {
+
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if first[0] >= '0' && first[0] <= '9' {
+		length, err := reader.ReadSlice(' ')
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(length)))
+		if err != nil || n > MAX_MESSAGE_SIZE {
+			return nil, errors.New(fmt.Sprintf("Invalid syslog message length '%s'", strings.TrimSpace(string(length))))
+		}
+		data := make([]byte, n)
+		_, err = io.ReadFull(reader, data)
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	line, err := reader.ReadSlice('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, line...), nil
+}
+
+// receive creates an event from a syslog message, in the namespace of the source configured for the address the
+// message was sent from
+func (ch *SyslogChannel) receive(data []byte, addr net.Addr) {
+
+	// the trailing newline or NUL some senders end messages with can't be stored as text
+	data = bytes.TrimRight(data, "\r\n\x00")
+	message, err := syslogparser.Parse(data)
+	if err != nil {
+		glog.Debugf("Ignoring syslog message from %s: %v", addr, err)
+		return
+	}
+
+	sender := addr.String()
+	if host, _, err := net.SplitHostPort(sender); err == nil {
+		sender = host
+	}
+	hints := message.Hints(sender)
+
+	ch.lock.Lock()
+	namespace := ch.namespaces[sender]
+	ch.lock.Unlock()
+	if namespace == "" {
+		namespace = config.DEFAULT_NAMESPACE
+	}
+
+	_, err = events.ParseAndCreateEventFromChannel(ch.Name(), namespace, &data, hints, nil)
+	if err != nil {
+		glog.Warningf("Unable to create event from syslog message from %s: %v", sender, err)
+	}
+}
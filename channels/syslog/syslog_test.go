@@ -0,0 +1,66 @@
+package syslog
+
+import (
+	"bufio"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadsFramedMessages(t *testing.T) {
+
+	reader := bufio.NewReader(strings.NewReader("11 <13>1 - - -\n<13>a message\n27 <13>1 - - - - - - two\nlines<14>last message"))
+	for _, expected := range []string{
+		"<13>1 - - -",
+		"\n",
+		"<13>a message\n",
+		"<13>1 - - - - - - two\nlines",
+		"<14>last message",
+	} {
+		data, err := readMessage(reader)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(data))
+	}
+	_, err := readMessage(reader)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestRejectsBadFrames(t *testing.T) {
+
+	_, err := readMessage(bufio.NewReader(strings.NewReader("99999999 <13>message")))
+	assert.NotNil(t, err)
+	_, err = readMessage(bufio.NewReader(strings.NewReader("20 <13>short")))
+	assert.NotNil(t, err)
+}
+
+func TestStartsAndStopsPubChannel(t *testing.T) {
+
+	ch := &SyslogChannel{}
+	assert.Nil(t, ch.StartPubChannel(map[string]string{}, nil))
+	assert.Nil(t, ch.PubChannelInfo(nil))
+
+	go ch.StartPubChannel(map[string]string{PORT_CONFIG: "0"}, []map[string]string{{SENDER_ARG: "10.0.0.5", "Namespace": "ops"}})
+	for i := 0; i < 100 && ch.PubChannelInfo(nil) == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Len(t, ch.PubChannelInfo(nil), 2)
+	assert.Equal(t, map[string]string{"10.0.0.5": "ops"}, ch.namespaces)
+
+	assert.Nil(t, ch.StopPubChannel())
+	assert.Nil(t, ch.PubChannelInfo(nil))
+	assert.Nil(t, ch.StopPubChannel())
+}
+
+func TestValidatesPubChannelArgs(t *testing.T) {
+	ch := &SyslogChannel{}
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{SENDER_ARG: "10.0.0.5"}))
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{SENDER_ARG: "fe80::1"}))
+	assert.Nil(t, ch.ValidatePubChannelArgs(map[string]string{}))
+	assert.NotNil(t, ch.ValidatePubChannelArgs(map[string]string{SENDER_ARG: "db1.example.com"}))
+
+	// the same address written differently is the same sender
+	assert.Equal(t, ch.PubChannelClaim(map[string]string{SENDER_ARG: "fe80::1"}), ch.PubChannelClaim(map[string]string{SENDER_ARG: "fe80:0::1"}))
+	assert.Equal(t, "", ch.PubChannelClaim(map[string]string{}))
+}
@@ -1,124 +1,508 @@
 package config
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"sync"
 )
 
 type ConnectrixConfig struct {
-	DatabaseConnection string `json:"database_connection"`
-	LogLevel           string `json:"log_level"`
-	Channels           map[string]Channel
-	Sources            []*EventSource
-	Routes             []*Route
+	DatabaseConnection string                       `json:"database_connection"`
+	LogLevel           string                       `json:"log_level"`
+	ManagementPort     string                       `json:"management_port,omitempty"`
+	ManagementToken    string                       `json:"management_token,omitempty"`
+	Channels           map[string]Channel           `json:"channels"`
+	Credentials        map[string]string            `json:"credentials,omitempty"`
+	Sources            []*EventSource               `json:"sources"`
+	Routes             []*Route                     `json:"routes"`
+	Namespaces         []*Namespace                 `json:"namespaces,omitempty"`
+	Parsers            map[string]map[string]string `json:"parsers,omitempty"`
+}
+
+// Namespace is a tenant with its own sources, routes, named args and credentials. Events received for a
+// namespace are only identified against its sources and only sent to its routes. The top level sources, routes,
+// named args and credentials of the config make up the default namespace.
+type Namespace struct {
+	Name        string             `json:"name"`
+	Channels    map[string]Channel `json:"channels,omitempty"`
+	Credentials map[string]string  `json:"credentials,omitempty"`
+	Sources     []*EventSource     `json:"sources"`
+	Routes      []*Route           `json:"routes"`
 }
 
 type EventSource struct {
-	Name           string
-	Hint           string
-	Parser         string
-	Events         []*EventType
-	NamedArgs      string            `json:"named_args"`
-	PubChannelName string            `json:"pub_channel_name"`
-	PubChannelArgs map[string]string `json:"pub_channel_args"`
+	Name           string                       `json:"name"`
+	Hint           string                       `json:"hint,omitempty"`
+	Match          *Match                       `json:"match,omitempty"`
+	Verify         *Verification                `json:"verify,omitempty"`
+	Parser         string                       `json:"parser,omitempty"`
+	ContentTypes   map[string]string            `json:"content_types,omitempty"`
+	ParserOptions  map[string]map[string]string `json:"parser_options,omitempty"`
+	Events         []*EventType                 `json:"events"`
+	NamedArgs      string                       `json:"named_args,omitempty"`
+	PubChannelName string                       `json:"pub_channel_name,omitempty"`
+	PubChannelArgs map[string]string            `json:"pub_channel_args,omitempty"`
 }
 
 type EventType struct {
-	Type     string
-	Hint     string
-	Fields   []string
-	Template string
+	Type     string   `json:"type"`
+	Hint     string   `json:"hint,omitempty"`
+	Match    *Match   `json:"match,omitempty"`
+	Rule     string   `json:"rule,omitempty"`
+	Fields   []string `json:"fields,omitempty"`
+	Template string   `json:"template,omitempty"`
+}
+
+// Verification is how events from a source are checked to have come from the source, e.g. by checking a signature.
+// Which schemes are available depends on the channel the events are received on.
+type Verification struct {
+	Scheme    string `json:"scheme"`
+	Secret    string `json:"secret"`
+	Header    string `json:"header,omitempty"`
+	Prefix    string `json:"prefix,omitempty"`
+	Algorithm string `json:"algorithm,omitempty"`
+	Encoding  string `json:"encoding,omitempty"`
+	Tolerance string `json:"tolerance,omitempty"`
+}
+
+// Match identifies an event source or type from the hints the event was received with. Every matcher in All must
+// match, and if Any has matchers at least one of them must match too.
+type Match struct {
+	All []*Matcher `json:"all,omitempty"`
+	Any []*Matcher `json:"any,omitempty"`
+}
+
+// Matcher matches the value of the hint with the given key, e.g. header:User-Agent, using Op (exact, prefix, glob
+// or regex, defaulting to exact)
+type Matcher struct {
+	Key   string `json:"key"`
+	Op    string `json:"op,omitempty"`
+	Value string `json:"value"`
 }
 
 type Route struct {
-	NamedArgs      string            `json:"named_args"`
-	SubChannelName string            `json:"sub_channel_name"`
-	SubChannelArgs map[string]string `json:"sub_channel_args"`
-	Namespace      string
-	EventSource    string `json:"event_source"`
-	EventType      string `json:"event_type"`
-	Template       string
-	Rule           string
+	Name           string            `json:"name"`
+	NamedArgs      string            `json:"named_args,omitempty"`
+	SubChannelName string            `json:"sub_channel_name,omitempty"`
+	SubChannelArgs map[string]string `json:"sub_channel_args,omitempty"`
+	Namespace      string            `json:"namespace"`
+	EventSource    string            `json:"event_source"`
+	EventType      string            `json:"event_type"`
+	Template       string            `json:"template,omitempty"`
+	Rule           string            `json:"rule,omitempty"`
+	MaxAttempts    int               `json:"max_attempts,omitempty"`
+	RetryBackoff   string            `json:"retry_backoff,omitempty"`
 }
 
 type Channel struct {
-	Config    map[string]string
-	NamedArgs map[string]map[string]string `json:"named_args"`
+	Config    map[string]string            `json:"config,omitempty"`
+	NamedArgs map[string]map[string]string `json:"named_args,omitempty"`
 }
 
+// DEFAULT_NAMESPACE is the name of the namespace made up of the top level sources and routes
+const DEFAULT_NAMESPACE string = "0"
+
 // configPath contains the path to the config file relative to the current process
 var configPath string = fmt.Sprintf("%sconfig.json", os.Getenv("CONNECTRIX_CONFIG_FILE"))
 
-// the in-memory config, populated via loadConfig
-var config ConnectrixConfig
+// Path returns the path to the config file used by Get
+func Path() string {
+	return configPath
+}
+
+// the in-memory config, populated via loadConfig and replaced via Update.
+// The config a pointer refers to is never modified once it has been published, changes are made to a copy.
+var config *ConnectrixConfig
+
+// guards config
+var lock sync.RWMutex
+
+// used to make sure only one update is applied at a time
+var updateLock sync.Mutex
 
 // used to make sure we only load config once
 var once sync.Once
 
-// loadConfig loads config from disk
-func loadConfig() {
+// validators are run against a new config before it replaces the current one
+var validators []func(*ConnectrixConfig) error
 
-	absPath, err := filepath.Abs(configPath)
+// listeners are notified after the current config has been replaced
+var listeners []func(*ConnectrixConfig)
+
+// Load reads and parses the config file at path, substituting named args. Unlike Get the config is not
+// validated or made current, which makes it useful for checking a config file.
+func Load(path string) (*ConnectrixConfig, error) {
+
+	absPath, err := filepath.Abs(path)
 	if err != nil {
-		log.Fatal(fmt.Sprintf("Unable to determine absolute config file path:\n %v", err))
+		return nil, errors.New(fmt.Sprintf("Unable to determine absolute config file path:\n %v", err))
 	}
 
 	bytes, err := ioutil.ReadFile(absPath)
 	if err != nil {
-		log.Fatal(fmt.Sprintf("Unable to load config file:\n %v", err))
+		return nil, errors.New(fmt.Sprintf("Unable to load config file:\n %v", err))
 	}
 
-	err = json.Unmarshal(bytes, &config)
+	var loaded ConnectrixConfig
+	err = json.Unmarshal(bytes, &loaded)
 	if err != nil {
-		log.Fatal(fmt.Sprintf("Unable to parse config file:\n %v", err))
+		return nil, describeParseError(bytes, err)
+	}
+
+	substituteNamedArgs(&loaded)
+	nameRoutes(&loaded)
+	return &loaded, nil
+}
+
+// describeParseError adds the line and column the error occurred at, if known
+func describeParseError(data []byte, err error) error {
+
+	var offset int64
+	switch jsonErr := err.(type) {
+	case *json.SyntaxError:
+		offset = jsonErr.Offset
+	case *json.UnmarshalTypeError:
+		offset = jsonErr.Offset
+	default:
+		return errors.New(fmt.Sprintf("Unable to parse config file:\n %v", err))
+	}
+
+	// offset is the number of bytes read when the error occurred, so the offending byte is the one before it
+	line, column := 1, 1
+	for i := int64(0); i < offset-1 && i < int64(len(data)); i++ {
+		if data[i] == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
 	}
 
-	substituteNamedArgs()
+	return errors.New(fmt.Sprintf("Unable to parse config file at line %d, column %d:\n %v", line, column, err))
+}
+
+// loadConfig loads config from disk
+func loadConfig() {
+	loaded, err := Load(configPath)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+	config = loaded
+}
+
+// Reload re-reads the config file and, if it parses and passes validation, replaces the current config with it.
+// The current config is kept if the file can't be loaded.
+func Reload() error {
+
+	// make sure the initial load has happened so it can't overwrite the reloaded config
+	Get()
+
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	reloaded, err := Load(configPath)
+	if err != nil {
+		return err
+	}
+
+	return replace(reloaded)
 }
 
 // substituteNamedArgs sets the PubChannelName/PubChannelArgs properties of EventSources and the
 // SubChannelName/SubChannelArgs of Routes to the values specified via the NamedRoutes section of the config.
-// This allows arguments to be written once but used in multiple places.
-func substituteNamedArgs() {
+// This allows arguments to be written once but used in multiple places. Sources and routes only see the named
+// args of their own namespace.
+func substituteNamedArgs(config *ConnectrixConfig) {
 
 	type arg struct {
 		ChannelName string
 		NamedArg    map[string]string
 	}
 
-	namedArgs := make(map[string]*arg)
-	for channelName, channel := range config.Channels {
-		for namedArg, val := range channel.NamedArgs {
-			namedArgs[namedArg] = &arg{ChannelName: channelName, NamedArg: val}
+	for _, namespace := range config.AllNamespaces() {
+		namedArgs := make(map[string]*arg)
+		for channelName, channel := range namespace.Channels {
+			for namedArg, val := range channel.NamedArgs {
+				namedArgs[namedArg] = &arg{ChannelName: channelName, NamedArg: val}
+			}
+		}
+
+		for _, eventSource := range namespace.Sources {
+			if eventSource.NamedArgs != "" {
+				if arg, ok := namedArgs[eventSource.NamedArgs]; ok {
+					eventSource.PubChannelName = arg.ChannelName
+					eventSource.PubChannelArgs = arg.NamedArg
+				}
+			}
+		}
+
+		for _, route := range namespace.Routes {
+			if route.NamedArgs != "" {
+				if arg, ok := namedArgs[route.NamedArgs]; ok {
+					route.SubChannelName = arg.ChannelName
+					route.SubChannelArgs = arg.NamedArg
+				}
+			}
 		}
 	}
+}
+
+// checkNamedArgs makes sure every named args reference made by a source or route exists in its namespace
+func checkNamedArgs(config *ConnectrixConfig) error {
 
-	for _, eventSource := range config.Sources {
-		if eventSource.NamedArgs != "" {
-			if arg, ok := namedArgs[eventSource.NamedArgs]; ok {
-				eventSource.PubChannelName = arg.ChannelName
-				eventSource.PubChannelArgs = arg.NamedArg
+	for _, namespace := range config.AllNamespaces() {
+		exists := func(name string) bool {
+			for _, channel := range namespace.Channels {
+				if _, ok := channel.NamedArgs[name]; ok {
+					return true
+				}
 			}
+			return false
 		}
+
+		for _, eventSource := range namespace.Sources {
+			if eventSource.NamedArgs != "" && !exists(eventSource.NamedArgs) {
+				return errors.New(fmt.Sprintf("Source '%s' refers to unknown named args '%s'", eventSource.Name, eventSource.NamedArgs))
+			}
+		}
+		for _, route := range namespace.Routes {
+			if route.NamedArgs != "" && !exists(route.NamedArgs) {
+				return errors.New(fmt.Sprintf("Route '%s' refers to unknown named args '%s'", route.Name, route.NamedArgs))
+			}
+		}
+	}
+
+	return nil
+}
+
+// defaultRouteName names a route after its event source and type and a hash of what the route does, rather than its
+// position, so that reordering or removing other routes doesn't give the route's name (and its dead letters) to a
+// different route. Routes that do the same thing are numbered after the first.
+func defaultRouteName(route *Route, prefix string, used map[string]int) string {
+
+	// named args have already been substituted in to the channel name and args, so only the reference is hashed
+	content := struct {
+		NamedArgs      string
+		SubChannelName string
+		SubChannelArgs map[string]string
+		EventSource    string
+		EventType      string
+		Template       string
+		Rule           string
+	}{route.NamedArgs, route.SubChannelName, route.SubChannelArgs, route.EventSource, route.EventType, route.Template, route.Rule}
+	if route.NamedArgs != "" {
+		content.SubChannelName = ""
+		content.SubChannelArgs = nil
 	}
+	bytes, _ := json.Marshal(content)
+	hash := sha1.Sum(bytes)
 
+	name := fmt.Sprintf("%s%s:%s:%s", prefix, route.EventSource, route.EventType, hex.EncodeToString(hash[:4]))
+	used[name]++
+	if used[name] > 1 {
+		name = fmt.Sprintf("%s-%d", name, used[name])
+	}
+	return name
+}
+
+// nameRoutes gives each route without a name a default one, so the route can be referred to later on
+// (e.g. when re-driving a dead letter). Routes are also given the name of the namespace they are defined in,
+// replacing any other namespace they name, as they can only ever match events in their own namespace.
+func nameRoutes(config *ConnectrixConfig) {
+	used := make(map[string]int)
 	for _, route := range config.Routes {
-		if route.NamedArgs != "" {
-			if arg, ok := namedArgs[route.NamedArgs]; ok {
-				route.SubChannelName = arg.ChannelName
-				route.SubChannelArgs = arg.NamedArg
+		if route.Name == "" {
+			route.Name = defaultRouteName(route, "", used)
+		}
+		route.Namespace = DEFAULT_NAMESPACE
+	}
+	for _, namespace := range config.Namespaces {
+		for _, route := range namespace.Routes {
+			if route.Name == "" {
+				route.Name = defaultRouteName(route, namespace.Name+"/", used)
 			}
+			route.Namespace = namespace.Name
 		}
 	}
 }
 
-// Get returns the Connectrix config
+// Get returns the Connectrix config. The returned config must be treated as read only, use Update to change it.
 func Get() *ConnectrixConfig {
 	once.Do(loadConfig)
-	return &config
+	lock.RLock()
+	defer lock.RUnlock()
+	return config
+}
+
+// Copy returns a deep copy of the config that can be safely modified.
+func (c *ConnectrixConfig) Copy() (*ConnectrixConfig, error) {
+	bytes, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var copy_ ConnectrixConfig
+	err = json.Unmarshal(bytes, &copy_)
+	if err != nil {
+		return nil, err
+	}
+	return &copy_, nil
+}
+
+// AddValidator registers a function that must accept a new config before it can replace the current one.
+func AddValidator(validator func(*ConnectrixConfig) error) {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+	validators = append(validators, validator)
+}
+
+// OnChange registers a function that is called with the new config each time the config is replaced.
+func OnChange(listener func(*ConnectrixConfig)) {
+	updateLock.Lock()
+	defer updateLock.Unlock()
+	listeners = append(listeners, listener)
+}
+
+// Update applies changes to a copy of the current config. If change returns an error or the changed config
+// fails validation the current config is left as is, otherwise the changed config replaces it.
+func Update(change func(*ConnectrixConfig) error) error {
+
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	updated, err := Get().Copy()
+	if err != nil {
+		return err
+	}
+
+	err = change(updated)
+	if err != nil {
+		return err
+	}
+
+	return replace(updated)
+}
+
+// replace validates the new config and publishes it. Callers must hold updateLock.
+func replace(updated *ConnectrixConfig) error {
+
+	err := checkNamedArgs(updated)
+	if err != nil {
+		return err
+	}
+	substituteNamedArgs(updated)
+	nameRoutes(updated)
+
+	for _, validator := range validators {
+		err := validator(updated)
+		if err != nil {
+			return err
+		}
+	}
+
+	lock.Lock()
+	config = updated
+	lock.Unlock()
+
+	for _, listener := range listeners {
+		listener(updated)
+	}
+
+	return nil
+}
+
+// Save writes the current config back to the config file, so changes made via Update survive a restart.
+func Save() error {
+
+	updateLock.Lock()
+	defer updateLock.Unlock()
+
+	current, err := Get().Copy()
+	if err != nil {
+		return err
+	}
+
+	// don't persist the values that were substituted from named args
+	for _, namespace := range current.AllNamespaces() {
+		for _, eventSource := range namespace.Sources {
+			if eventSource.NamedArgs != "" {
+				eventSource.PubChannelName = ""
+				eventSource.PubChannelArgs = nil
+			}
+		}
+		for _, route := range namespace.Routes {
+			if route.NamedArgs != "" {
+				route.SubChannelName = ""
+				route.SubChannelArgs = nil
+			}
+		}
+	}
+
+	bytes, err := json.MarshalIndent(current, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(configPath)
+	if err != nil {
+		return err
+	}
+	if existing, err := ioutil.ReadFile(absPath); err == nil {
+		bytes = keepUnknownFields(bytes, existing)
+	}
+
+	// write to a temp file first so a failed write can't leave a half written config behind
+	tmpPath := absPath + ".tmp"
+	err = ioutil.WriteFile(tmpPath, bytes, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, absPath)
+}
+
+// keepUnknownFields adds the top level fields of the existing config file that connectrix doesn't know about (e.g.
+// comments or settings used by other tools) to the saved config, so saving doesn't drop them
+func keepUnknownFields(saved []byte, existing []byte) []byte {
+
+	var existingFields map[string]json.RawMessage
+	if json.Unmarshal(existing, &existingFields) != nil {
+		return saved
+	}
+
+	// field names are matched case insensitively, as they are when the config is loaded
+	known := make(map[string]bool)
+	configType := reflect.TypeOf(ConnectrixConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("json"), ",")[0]
+		known[strings.ToLower(name)] = true
+	}
+
+	var savedFields map[string]json.RawMessage
+	if json.Unmarshal(saved, &savedFields) != nil {
+		return saved
+	}
+	kept := false
+	for name, value := range existingFields {
+		if !known[strings.ToLower(name)] {
+			savedFields[name] = value
+			kept = true
+		}
+	}
+	if !kept {
+		return saved
+	}
+
+	merged, err := json.MarshalIndent(savedFields, "", "\t")
+	if err != nil {
+		return saved
+	}
+	return merged
 }
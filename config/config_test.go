@@ -0,0 +1,67 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRouteNamesDontDependOnOrder(t *testing.T) {
+
+	ping := func() *Route {
+		return &Route{EventSource: "IRC", EventType: "ping", SubChannelName: "irc", Template: "pong"}
+	}
+	echo := func() *Route {
+		return &Route{EventSource: "IRC", EventType: "echo", SubChannelName: "irc", Template: "{{.Msg}}"}
+	}
+
+	config := &ConnectrixConfig{Routes: []*Route{ping(), echo()}}
+	nameRoutes(config)
+	reordered := &ConnectrixConfig{Routes: []*Route{echo(), ping()}}
+	nameRoutes(reordered)
+
+	assert.Regexp(t, "^IRC:ping:[0-9a-f]{8}$", config.Routes[0].Name)
+	assert.Equal(t, config.Routes[0].Name, reordered.Routes[1].Name)
+	assert.Equal(t, config.Routes[1].Name, reordered.Routes[0].Name)
+
+	// routes that do the same thing are numbered
+	duplicated := &ConnectrixConfig{Routes: []*Route{ping(), ping()}}
+	nameRoutes(duplicated)
+	assert.Equal(t, config.Routes[0].Name, duplicated.Routes[0].Name)
+	assert.Equal(t, config.Routes[0].Name+"-2", duplicated.Routes[1].Name)
+
+	// routes of a namespace are prefixed with its name
+	namespaced := &ConnectrixConfig{Namespaces: []*Namespace{&Namespace{Name: "team-a", Routes: []*Route{ping()}}}}
+	nameRoutes(namespaced)
+	assert.Equal(t, "team-a/"+config.Routes[0].Name, namespaced.Namespaces[0].Routes[0].Name)
+}
+
+func TestRoutesBelongToTheirNamespace(t *testing.T) {
+
+	config := &ConnectrixConfig{
+		Routes: []*Route{&Route{Name: "top", Namespace: "team-a"}},
+		Namespaces: []*Namespace{
+			&Namespace{Name: "team-a", Routes: []*Route{&Route{Name: "a"}, &Route{Name: "b", Namespace: "team-b"}}},
+		},
+	}
+	nameRoutes(config)
+	assert.Equal(t, DEFAULT_NAMESPACE, config.Routes[0].Namespace)
+	assert.Equal(t, "team-a", config.Namespaces[0].Routes[0].Namespace)
+	// a route can't reach into another namespace by naming it
+	assert.Equal(t, "team-a", config.Namespaces[0].Routes[1].Namespace)
+}
+
+func TestSaveKeepsUnknownFields(t *testing.T) {
+
+	saved := []byte(`{"log_level":"INFO","management_port":"9097"}`)
+
+	merged := keepUnknownFields(saved, []byte(`{"_comment":"prod","Log_Level":"DEBUG","management_token":"x"}`))
+	var fields map[string]interface{}
+	assert.Nil(t, json.Unmarshal(merged, &fields))
+	// unknown fields are kept, known fields come from the saved config even when they were left out of it
+	assert.Equal(t, map[string]interface{}{"_comment": "prod", "log_level": "INFO", "management_port": "9097"}, fields)
+
+	// nothing changes if there are no unknown fields or the existing file can't be read
+	assert.Equal(t, saved, keepUnknownFields(saved, []byte(`{"log_level":"DEBUG"}`)))
+	assert.Equal(t, saved, keepUnknownFields(saved, []byte(`not json`)))
+}
@@ -0,0 +1,36 @@
+package config
+
+// Namespace returns the namespace with the given name, or nil if there isn't one. An empty name refers to the
+// default namespace, which is made up of the top level sections of the config and shares them rather than
+// copying them.
+func (c *ConnectrixConfig) Namespace(name string) *Namespace {
+	if name == "" || name == DEFAULT_NAMESPACE {
+		return &Namespace{
+			Name:        DEFAULT_NAMESPACE,
+			Channels:    c.Channels,
+			Credentials: c.Credentials,
+			Sources:     c.Sources,
+			Routes:      c.Routes,
+		}
+	}
+	for _, namespace := range c.Namespaces {
+		if namespace.Name == name {
+			return namespace
+		}
+	}
+	return nil
+}
+
+// AllNamespaces returns the default namespace followed by the configured namespaces
+func (c *ConnectrixConfig) AllNamespaces() []*Namespace {
+	return append([]*Namespace{c.Namespace(DEFAULT_NAMESPACE)}, c.Namespaces...)
+}
+
+// AllRoutes returns the routes of every namespace
+func (c *ConnectrixConfig) AllRoutes() []*Route {
+	routes := []*Route{}
+	for _, namespace := range c.AllNamespaces() {
+		routes = append(routes, namespace.Routes...)
+	}
+	return routes
+}
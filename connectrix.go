@@ -1,38 +1,112 @@
 package main
 
 import (
+	"fmt"
 	"github.com/diggs/connectrix/channels"
-	"github.com/diggs/connectrix/channels/http"
-	"github.com/diggs/connectrix/channels/irc"
+	_ "github.com/diggs/connectrix/channels/http"
+	_ "github.com/diggs/connectrix/channels/irc"
+	_ "github.com/diggs/connectrix/channels/kv"
+	_ "github.com/diggs/connectrix/channels/mailbox"
+	_ "github.com/diggs/connectrix/channels/smtp"
+	_ "github.com/diggs/connectrix/channels/snmp"
+	_ "github.com/diggs/connectrix/channels/socket"
+	_ "github.com/diggs/connectrix/channels/syslog"
 	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/database"
+	"github.com/diggs/connectrix/kv"
+	"github.com/diggs/connectrix/management"
+	"github.com/diggs/connectrix/rules"
+	"github.com/diggs/connectrix/templates"
+	"github.com/diggs/connectrix/validation"
 	"github.com/diggs/glog"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
+// KV_PURGE_INTERVAL is how often expired values are removed from the key/value store
+const KV_PURGE_INTERVAL time.Duration = time.Hour
+
 func main() {
 
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "validate":
+			os.Exit(validate(os.Args[2:]))
+		case "replay":
+			os.Exit(replay(os.Args[2:]))
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\nUsage: connectrix [validate [config file] | replay [options]]\n", os.Args[1])
+			os.Exit(2)
+		}
+	}
+
+	run()
+}
+
+// run runs the Connectrix daemon
+func run() {
+
 	log_level := os.Getenv("LOG_LEVEL")
 	if log_level == "" {
 		log_level = config.Get().LogLevel
+		config.OnChange(func(config_ *config.ConnectrixConfig) {
+			glog.SetSeverity(config_.LogLevel)
+		})
 	}
 	glog.SetSeverity(log_level)
 	defer glog.Flush()
 
+	// refuse to start with a config that has problems, e.g. a rule that doesn't compile, just as a reload would
+	problems := validation.Validate(config.Get())
+	for _, problem := range problems {
+		glog.Errorf("Config problem at %s", problem.String())
+	}
+	if len(problems) > 0 {
+		glog.Fatalf("Unable to start, config has %d problem(s)", len(problems))
+	}
+	config.AddValidator(validation.Check)
+
+	glog.Info("Connecting to database...")
+	err := database.Connect()
+	if err != nil {
+		glog.Fatalf("Unable to connect to database: %v", err)
+	}
+
+	go kv.PurgeEvery(KV_PURGE_INTERVAL)
+
+	glog.Info("Compiling templates and rules...")
+	templates.CompileConfig(config.Get())
+	rules.CompileConfig(config.Get())
+
 	glog.Info("Loading channels...")
-	err := channels.LoadChannels(
-		map[string]channels.PubChannel{
-			"http": &http.HttpChannel{},
-			"irc":  &irc.IrcChannel{},
-		},
-		map[string]channels.SubChannel{
-			"http": &http.HttpChannel{},
-			"irc":  &irc.IrcChannel{},
-		})
+	err = channels.LoadChannels()
 	if err != nil {
 		glog.Fatalf("Unable to load channels: %v", err)
 	}
 
-	// live forever
-	ch := make(chan int)
-	<-ch
+	if port := config.Get().ManagementPort; port != "" && config.Get().ManagementToken == "" {
+		glog.Warning("Not starting management API, management_port is set without a management_token")
+	} else if port != "" {
+		go func() {
+			err := management.Start(port, config.Get().ManagementToken)
+			if err != nil {
+				glog.Fatalf("Unable to start management API: %v", err)
+			}
+		}()
+	}
+
+	// reload config on SIGHUP, the current config is kept if the new one isn't valid
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		glog.Info("Reloading config...")
+		err := config.Reload()
+		if err != nil {
+			glog.Warningf("Unable to reload config, keeping current config: %v", err)
+		} else {
+			glog.Info("Config reloaded.")
+		}
+	}
 }
@@ -14,7 +14,7 @@ func GetDatabase() *sql.DB {
 	return database
 }
 
-// Connect connects to the configured postgres database and verifies the connection.
+// Connect connects to the configured postgres database, verifies the connection and creates any missing tables.
 func Connect() error {
 
 	db, err := sql.Open("postgres", config.Get().DatabaseConnection)
@@ -28,5 +28,5 @@ func Connect() error {
 	}
 
 	database = db
-	return nil
+	return createSchema()
 }
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DeadLetter records an event that could not be delivered to a route after all attempts were used up.
+type DeadLetter struct {
+	ID        int       `json:"id"`
+	EventID   int       `json:"event_id"`
+	Route     string    `json:"route"`
+	Error     string    `json:"error"`
+	Attempts  int       `json:"attempts"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const deadLetterColumns = "id, event_id, route, error, attempts, created_at, updated_at"
+
+// InsertDeadLetter stores the dead letter and returns the ID assigned to it by the database.
+func InsertDeadLetter(deadLetter *DeadLetter) (int, error) {
+
+	if database == nil {
+		return -1, errors.New("Not connected to the database.")
+	}
+
+	now := time.Now()
+	var id int
+	err := database.QueryRow(
+		"INSERT INTO dead_letters (event_id, route, error, attempts, created_at, updated_at) VALUES ($1, $2, $3, $4, $5, $5) RETURNING id",
+		deadLetter.EventID, deadLetter.Route, deadLetter.Error, deadLetter.Attempts, now).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// ListDeadLetters returns all dead letters, oldest first.
+func ListDeadLetters() ([]*DeadLetter, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	rows, err := database.Query(fmt.Sprintf("SELECT %s FROM dead_letters ORDER BY id", deadLetterColumns))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deadLetters := []*DeadLetter{}
+	for rows.Next() {
+		deadLetter, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+
+	return deadLetters, rows.Err()
+}
+
+// GetDeadLetter loads a single dead letter.
+func GetDeadLetter(id int) (*DeadLetter, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	row := database.QueryRow(fmt.Sprintf("SELECT %s FROM dead_letters WHERE id = $1", deadLetterColumns), id)
+	deadLetter, err := scanDeadLetter(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.New(fmt.Sprintf("Unknown dead letter: %d", id))
+	}
+	return deadLetter, err
+}
+
+// UpdateDeadLetter records a further failed attempt to deliver a dead letter.
+func UpdateDeadLetter(id int, attempts int, deliveryErr string) error {
+
+	if database == nil {
+		return errors.New("Not connected to the database.")
+	}
+
+	_, err := database.Exec("UPDATE dead_letters SET attempts = attempts + $2, error = $3, updated_at = $4 WHERE id = $1",
+		id, attempts, deliveryErr, time.Now())
+	return err
+}
+
+// DeleteDeadLetter removes a dead letter, typically once it has been successfully re-driven.
+func DeleteDeadLetter(id int) error {
+
+	if database == nil {
+		return errors.New("Not connected to the database.")
+	}
+
+	_, err := database.Exec("DELETE FROM dead_letters WHERE id = $1", id)
+	return err
+}
+
+func scanDeadLetter(row scanner) (*DeadLetter, error) {
+	deadLetter := &DeadLetter{}
+	err := row.Scan(&deadLetter.ID, &deadLetter.EventID, &deadLetter.Route, &deadLetter.Error,
+		&deadLetter.Attempts, &deadLetter.CreatedAt, &deadLetter.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return deadLetter, nil
+}
@@ -0,0 +1,190 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/glog"
+	"strings"
+	"time"
+)
+
+const eventColumns = "id, namespace, source, type, parser, raw_data, content, object, received_at"
+
+// InsertEvent stores the event and returns the ID assigned to it by the database.
+func InsertEvent(event_ *event.Event) (int, error) {
+
+	if database == nil {
+		return -1, errors.New("Not connected to the database.")
+	}
+
+	// the parsed object is stored as json so that it can be used to re-route the event later on
+	var object sql.NullString
+	if event_.Object != nil {
+		bytes, err := json.Marshal(jsonObject(event_.Object))
+		if err != nil {
+			glog.Warningf("Unable to serialize object of %s:%s event, it will not be stored: %v", event_.Source, event_.Type, err)
+		} else {
+			object = sql.NullString{String: string(bytes), Valid: true}
+		}
+	}
+
+	var id int
+	err := database.QueryRow(
+		"INSERT INTO events (namespace, source, type, parser, raw_data, content, object, received_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id",
+		event_.Namespace, event_.Source, event_.Type, event_.ParserName, event_.RawData, event_.Content, object, event_.ReceivedAt).Scan(&id)
+	if err != nil {
+		return -1, err
+	}
+
+	return id, nil
+}
+
+// jsonObject returns the object with every map keyed by strings, as json can't marshal the
+// map[interface{}]interface{} maps the yaml parser creates
+func jsonObject(object interface{}) interface{} {
+	switch value := object.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, val := range value {
+			converted[fmt.Sprint(key)] = jsonObject(val)
+		}
+		return converted
+	case map[string]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, val := range value {
+			converted[key] = jsonObject(val)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, val := range value {
+			converted[i] = jsonObject(val)
+		}
+		return converted
+	}
+	return object
+}
+
+// GetEvent loads a previously stored event.
+func GetEvent(id int) (*event.Event, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	row := database.QueryRow(fmt.Sprintf("SELECT %s FROM events WHERE id = $1", eventColumns), id)
+	event_, err := scanEvent(row)
+	if err == sql.ErrNoRows {
+		return nil, errors.New(fmt.Sprintf("Unknown event: %d", id))
+	}
+	return event_, err
+}
+
+// scanner is satisfied by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanEvent(row scanner) (*event.Event, error) {
+
+	event_ := &event.Event{}
+	var object sql.NullString
+	err := row.Scan(&event_.ID, &event_.Namespace, &event_.Source, &event_.Type, &event_.ParserName,
+		&event_.RawData, &event_.Content, &object, &event_.ReceivedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if object.Valid {
+		err = json.Unmarshal([]byte(object.String), &event_.Object)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return event_, nil
+}
+
+// EventFilter selects stored events. Only the fields that are set are used to filter events.
+type EventFilter struct {
+	IDs       []int     `json:"ids,omitempty"`
+	From      time.Time `json:"from,omitempty"`
+	Until     time.Time `json:"until,omitempty"`
+	Namespace string    `json:"namespace,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	Limit     int       `json:"limit,omitempty"`
+}
+
+// IsEmpty returns true if the filter would select every event
+func (f *EventFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && f.From.IsZero() && f.Until.IsZero() && f.Namespace == "" && f.Source == "" && f.Type == ""
+}
+
+// FindEvents returns the stored events selected by the filter, oldest first.
+func FindEvents(filter *EventFilter) ([]*event.Event, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	conditions := []string{}
+	params := []interface{}{}
+	addCondition := func(condition string, param interface{}) {
+		params = append(params, param)
+		conditions = append(conditions, fmt.Sprintf(condition, len(params)))
+	}
+
+	if len(filter.IDs) > 0 {
+		placeholders := make([]string, len(filter.IDs))
+		for i, id := range filter.IDs {
+			params = append(params, id)
+			placeholders[i] = fmt.Sprintf("$%d", len(params))
+		}
+		conditions = append(conditions, fmt.Sprintf("id IN (%s)", strings.Join(placeholders, ", ")))
+	}
+	if !filter.From.IsZero() {
+		addCondition("received_at >= $%d", filter.From)
+	}
+	if !filter.Until.IsZero() {
+		addCondition("received_at < $%d", filter.Until)
+	}
+	if filter.Namespace != "" {
+		addCondition("namespace = $%d", filter.Namespace)
+	}
+	if filter.Source != "" {
+		addCondition("source = $%d", filter.Source)
+	}
+	if filter.Type != "" {
+		addCondition("type = $%d", filter.Type)
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM events", eventColumns)
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := database.Query(query, params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*event.Event{}
+	for rows.Next() {
+		event_, err := scanEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event_)
+	}
+
+	return events, rows.Err()
+}
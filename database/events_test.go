@@ -0,0 +1,18 @@
+package database
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJsonObjectOfYaml(t *testing.T) {
+
+	object := map[interface{}]interface{}{
+		"build": map[interface{}]interface{}{"number": 42, true: "yes"},
+		"steps": []interface{}{map[interface{}]interface{}{"name": "test"}},
+	}
+	bytes, err := json.Marshal(jsonObject(object))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"build":{"number":42,"true":"yes"},"steps":[{"name":"test"}]}`, string(bytes))
+}
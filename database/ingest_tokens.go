@@ -0,0 +1,108 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// IngestToken identifies the namespace and source of events sent with it, so that senders don't need to be
+// identified by hints or name their namespace.
+type IngestToken struct {
+	Token     string    `json:"token"`
+	Namespace string    `json:"namespace"`
+	Source    string    `json:"source"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const ingestTokenColumns = "token, namespace, source, created_at"
+
+// InsertIngestToken stores the token. Only one token can exist for each namespace and source.
+func InsertIngestToken(token *IngestToken) error {
+
+	if database == nil {
+		return errors.New("Not connected to the database.")
+	}
+
+	_, err := database.Exec(
+		"INSERT INTO ingest_tokens (token, namespace, source, created_at) VALUES ($1, $2, $3, $4)",
+		token.Token, token.Namespace, token.Source, token.CreatedAt)
+	return err
+}
+
+// GetIngestToken loads the token, returning nil if the token doesn't exist.
+func GetIngestToken(token string) (*IngestToken, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	row := database.QueryRow(fmt.Sprintf("SELECT %s FROM ingest_tokens WHERE token = $1", ingestTokenColumns), token)
+	return scanIngestToken(row)
+}
+
+// FindIngestToken loads the token for the namespace and source, returning nil if there isn't one.
+func FindIngestToken(namespace string, source string) (*IngestToken, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	row := database.QueryRow(fmt.Sprintf("SELECT %s FROM ingest_tokens WHERE namespace = $1 AND source = $2", ingestTokenColumns), namespace, source)
+	return scanIngestToken(row)
+}
+
+// ListIngestTokens returns the tokens for the source of the namespace, oldest first.
+func ListIngestTokens(namespace string, source string) ([]*IngestToken, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	rows, err := database.Query(fmt.Sprintf("SELECT %s FROM ingest_tokens WHERE namespace = $1 AND source = $2 ORDER BY created_at", ingestTokenColumns), namespace, source)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*IngestToken{}
+	for rows.Next() {
+		token, err := scanIngestToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// DeleteIngestToken removes the token, events sent with it will no longer be accepted.
+func DeleteIngestToken(token string) error {
+
+	if database == nil {
+		return errors.New("Not connected to the database.")
+	}
+
+	result, err := database.Exec("DELETE FROM ingest_tokens WHERE token = $1", token)
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err == nil && count == 0 {
+		return errors.New(fmt.Sprintf("Unknown ingest token: %s", token))
+	}
+	return nil
+}
+
+func scanIngestToken(row scanner) (*IngestToken, error) {
+	token := &IngestToken{}
+	err := row.Scan(&token.Token, &token.Namespace, &token.Source, &token.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// KV is a value stored under a key in a namespace's key/value store. Values without an expiry are kept until they
+// are deleted.
+type KV struct {
+	Namespace string     `json:"namespace"`
+	Key       string     `json:"key"`
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+const kvColumns = "namespace, key, value, expires_at, updated_at"
+
+// live only matches values that haven't expired, $1 being the current time
+const live = "(expires_at IS NULL OR expires_at > $1)"
+
+// GetKV loads the value stored under the key, returning nil if there isn't one or it has expired.
+func GetKV(namespace string, key string) (*KV, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	row := database.QueryRow(fmt.Sprintf("SELECT %s FROM kv WHERE %s AND namespace = $2 AND key = $3", kvColumns, live),
+		time.Now(), namespace, key)
+	return scanKV(row)
+}
+
+// ListKV returns the values stored in the namespace that haven't expired, ordered by key.
+func ListKV(namespace string) ([]*KV, error) {
+
+	if database == nil {
+		return nil, errors.New("Not connected to the database.")
+	}
+
+	rows, err := database.Query(fmt.Sprintf("SELECT %s FROM kv WHERE %s AND namespace = $2 ORDER BY key", kvColumns, live),
+		time.Now(), namespace)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	values := []*KV{}
+	for rows.Next() {
+		kv, err := scanKV(rows)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, kv)
+	}
+
+	return values, rows.Err()
+}
+
+// SetKV stores the value, replacing the value and expiry of any existing value with the same key.
+func SetKV(kv *KV) error {
+
+	if database == nil {
+		return errors.New("Not connected to the database.")
+	}
+
+	_, err := database.Exec(`INSERT INTO kv (namespace, key, value, expires_at, updated_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (namespace, key) DO UPDATE SET value = $3, expires_at = $4, updated_at = $5`,
+		kv.Namespace, kv.Key, kv.Value, kv.ExpiresAt, kv.UpdatedAt)
+	return err
+}
+
+// IncrementKV adds by to the number stored under the key and returns the result, treating a missing or expired
+// value as 0. expiresAt only applies when the value is created, an existing value keeps its expiry.
+func IncrementKV(namespace string, key string, by int64, expiresAt *time.Time) (int64, error) {
+
+	if database == nil {
+		return 0, errors.New("Not connected to the database.")
+	}
+
+	var value int64
+	err := database.QueryRow(`INSERT INTO kv (namespace, key, value, expires_at, updated_at) VALUES ($1, $2, $3::BIGINT::TEXT, $4, $5)
+		ON CONFLICT (namespace, key) DO UPDATE SET
+			value = (CASE WHEN kv.expires_at <= $5 THEN 0 ELSE kv.value::BIGINT END + $3::BIGINT)::TEXT,
+			expires_at = CASE WHEN kv.expires_at <= $5 THEN $4 ELSE kv.expires_at END,
+			updated_at = $5
+		RETURNING value::BIGINT`,
+		namespace, key, by, expiresAt, time.Now()).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+
+	return value, nil
+}
+
+// DeleteKV removes the value stored under the key, if there is one.
+func DeleteKV(namespace string, key string) error {
+
+	if database == nil {
+		return errors.New("Not connected to the database.")
+	}
+
+	_, err := database.Exec("DELETE FROM kv WHERE namespace = $1 AND key = $2", namespace, key)
+	return err
+}
+
+// DeleteExpiredKV removes every value that has expired and returns how many were removed.
+func DeleteExpiredKV() (int64, error) {
+
+	if database == nil {
+		return 0, errors.New("Not connected to the database.")
+	}
+
+	result, err := database.Exec("DELETE FROM kv WHERE expires_at <= $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanKV(row scanner) (*KV, error) {
+	kv := &KV{}
+	err := row.Scan(&kv.Namespace, &kv.Key, &kv.Value, &kv.ExpiresAt, &kv.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return kv, nil
+}
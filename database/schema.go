@@ -0,0 +1,52 @@
+package database
+
+// schema contains the statements needed to create the tables used by Connectrix.
+// Each statement must be safe to run against a database that is already up to date.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS events (
+		id          SERIAL PRIMARY KEY,
+		namespace   TEXT NOT NULL,
+		source      TEXT NOT NULL,
+		type        TEXT NOT NULL,
+		parser      TEXT NOT NULL DEFAULT '',
+		raw_data    BYTEA,
+		content     TEXT NOT NULL DEFAULT '',
+		object      TEXT,
+		received_at TIMESTAMP WITH TIME ZONE NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS dead_letters (
+		id         SERIAL PRIMARY KEY,
+		event_id   INTEGER NOT NULL REFERENCES events (id),
+		route      TEXT NOT NULL,
+		error      TEXT NOT NULL,
+		attempts   INTEGER NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS ingest_tokens (
+		token      TEXT PRIMARY KEY,
+		namespace  TEXT NOT NULL,
+		source     TEXT NOT NULL,
+		created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		UNIQUE (namespace, source)
+	)`,
+	`CREATE TABLE IF NOT EXISTS kv (
+		namespace  TEXT NOT NULL,
+		key        TEXT NOT NULL,
+		value      TEXT NOT NULL,
+		expires_at TIMESTAMP WITH TIME ZONE,
+		updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+		PRIMARY KEY (namespace, key)
+	)`,
+}
+
+// createSchema creates any tables that don't yet exist.
+func createSchema() error {
+	for _, statement := range schema {
+		_, err := database.Exec(statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,10 +1,17 @@
 package event
 
+import (
+	"time"
+)
+
 type Event struct {
+	ID         int
 	Namespace  string
 	Source     string
 	Type       string
 	ParserName string
+	RawData    []byte
 	Content    string
 	Object     interface{}
+	ReceivedAt time.Time
 }
@@ -0,0 +1,25 @@
+package event
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Hint is a piece of information about how an event was received, used to identify the event's source and type.
+// Keys are prefixed with the kind of information they hold, e.g. header:User-Agent, query:source or irc:server.
+type Hint struct {
+	Key   string
+	Value string
+	// Text is the hint as it's matched by the original free text source and event type hints, empty if the
+	// hint can only be matched by key
+	Text string
+}
+
+// HasKey returns true if the hint has the key. Keys are compared case insensitively, as header names are.
+func (h Hint) HasKey(key string) bool {
+	return strings.EqualFold(h.Key, key)
+}
+
+func (h Hint) String() string {
+	return fmt.Sprintf("%s=%s", h.Key, h.Value)
+}
@@ -1,30 +1,59 @@
 package events
 
 import (
+	"bytes"
+	"encoding/json"
 	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/database"
 	"github.com/diggs/connectrix/events/event"
 	"github.com/diggs/connectrix/parsers"
 	"github.com/diggs/connectrix/routes"
 	"github.com/diggs/connectrix/templates"
+	"github.com/diggs/glog"
+	"time"
+	"unicode/utf8"
 )
 
+// CreateEvent stores the event and then routes it, returning the ID the event was stored with.
 func CreateEvent(event *event.Event) (int, error) {
+
+	if event.ReceivedAt.IsZero() {
+		event.ReceivedAt = time.Now()
+	}
+
+	id, err := database.InsertEvent(event)
+	if err != nil {
+		return -1, err
+	}
+	event.ID = id
+	glog.Debugf("Stored event %d", id)
+
 	routes.RouteEvent(event)
-	return 0, nil
+	return id, nil
 }
 
-func makeTemplatedEventContent(object interface{}, eventType *config.EventType, eventData *[]byte) (string, error) {
+// makeTemplatedEventContent templates the content of the event. Without a template the content is the raw data, or
+// the object as JSON if the raw data is binary (e.g. an SNMP packet), as the content must be storable as text.
+func makeTemplatedEventContent(object interface{}, namespace string, eventSource *config.EventSource, eventType *config.EventType, eventData *[]byte) (string, error) {
 	if eventType.Template == "" {
 		data_ := *eventData
-		return string(data_[:]), nil
+		if utf8.Valid(data_) && bytes.IndexByte(data_, 0) < 0 {
+			return string(data_[:]), nil
+		}
+		content, err := json.Marshal(object)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
 	} else {
-		return templates.Template(object, eventType.Template)
+		return templates.Template(object, namespace, templates.EventTypeName(namespace, eventSource.Name, eventType.Type), eventType.Template)
 	}
 }
 
-func templateAndCreateEvent(eventSource *config.EventSource, eventType *config.EventType, namespace string, object interface{}, data *[]byte) (int, error) {
+// templateAndCreateEvent creates the event, recording the name of the parser the object was parsed with
+func templateAndCreateEvent(eventSource *config.EventSource, eventType *config.EventType, namespace string, parserName string, object interface{}, data *[]byte) (int, error) {
 
-	content, err := makeTemplatedEventContent(object, eventType, data)
+	content, err := makeTemplatedEventContent(object, namespace, eventSource, eventType, data)
 	if err != nil {
 		return -1, err
 	}
@@ -35,28 +64,45 @@ func templateAndCreateEvent(eventSource *config.EventSource, eventType *config.E
 		Type:       eventType.Type,
 		Content:    content,
 		Object:     object,
-		ParserName: eventSource.Parser,
+		ParserName: parserName,
+		RawData:    *data,
+		ReceivedAt: time.Now(),
 	}
 
 	return CreateEvent(&event)
 }
 
-func CreateEventFromChannel(pubChannelName string, namespace string, object interface{}, data *[]byte, hints []string) (int, error) {
+func CreateEventFromChannel(pubChannelName string, namespace string, object interface{}, data *[]byte, hints []event.Hint) (int, error) {
 
-	eventSource, eventType, err := parsers.IdentifyWithHints(hints)
+	eventSource, eventType, err := parsers.IdentifyWithHints(namespace, hints, object)
 	if err != nil {
 		return -1, err
 	}
 
-	return templateAndCreateEvent(eventSource, eventType, namespace, object, data)
+	return templateAndCreateEvent(eventSource, eventType, namespace, eventSource.Parser, object, data)
 }
 
-func ParseAndCreateEventFromChannel(pubChannelName string, namespace string, data *[]byte, hints []string) (int, error) {
+// ParseAndCreateEventFromChannel identifies the event source of the namespace from the hints and, if verify is given, checks the
+// event with verify before parsing the data and creating the event. Errors returned by verify are returned as is.
+func ParseAndCreateEventFromChannel(pubChannelName string, namespace string, data *[]byte, hints []event.Hint, verify func(*config.EventSource) error) (int, error) {
+
+	eventSource, err := parsers.IdentifySource(namespace, hints)
+	if err != nil {
+		return -1, err
+	}
+
+	if verify != nil {
+		err = verify(eventSource)
+		if err != nil {
+			return -1, err
+		}
+	}
 
-	object, eventSource, eventType, err := parsers.ParseWithHints(data, hints)
+	parserName := parsers.ChooseParser(eventSource, hints, data)
+	object, eventType, err := parsers.ParseForSource(data, namespace, eventSource, parserName, hints)
 	if err != nil {
 		return -1, err
 	}
 
-	return templateAndCreateEvent(eventSource, eventType, namespace, object, data)
+	return templateAndCreateEvent(eventSource, eventType, namespace, parserName, object, data)
 }
@@ -2,6 +2,7 @@ package events
 
 import (
 	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events/event"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -16,12 +17,49 @@ func TestTemplatedEventContent(t *testing.T) {
 		Type:     "test",
 	}
 
-	content, err := makeTemplatedEventContent(object, eventType, &eventData)
+	content, err := makeTemplatedEventContent(object, config.DEFAULT_NAMESPACE, &config.EventSource{Name: "Test"}, eventType, &eventData)
 
 	assert.Nil(t, err)
 	assert.Equal(t, TestData, content)
 }
 
+func TestBinaryEventContentIsObject(t *testing.T) {
+
+	// binary data such as an SNMP packet can't be stored as text, so the object is used instead
+	object := map[string]interface{}{"community": "public"}
+	eventData := []byte{0x30, 0x00, 0xff, 0x02}
+	eventType := &config.EventType{Type: "trap"}
+
+	content, err := makeTemplatedEventContent(object, config.DEFAULT_NAMESPACE, &config.EventSource{Name: "Test"}, eventType, &eventData)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"community":"public"}`, content)
+}
+
+func TestReplayRequiresFilter(t *testing.T) {
+	_, err := Replay(&ReplayRequest{DryRun: true})
+	assert.NotNil(t, err)
+}
+
+func TestRetemplateUsesTheCurrentTemplate(t *testing.T) {
+
+	object := map[string]interface{}{
+		"pusher":      map[string]interface{}{"name": "diggs"},
+		"repository":  map[string]interface{}{"name": "connectrix"},
+		"ref":         "refs/heads/master",
+		"head_commit": map[string]interface{}{"message": "Fix it", "url": "https://example.com/1"},
+	}
+	event_ := &event.Event{Namespace: config.DEFAULT_NAMESPACE, Source: "GitHub", Type: "push", Content: "old content", Object: object}
+
+	assert.Nil(t, retemplate(event_))
+	assert.Equal(t, "diggs committed to connectrix:refs/heads/master: Fix it - https://example.com/1", event_.Content)
+
+	// events whose type has been removed keep their stored content
+	event_ = &event.Event{Namespace: config.DEFAULT_NAMESPACE, Source: "GitHub", Type: "removed", Content: "old content", Object: object}
+	assert.Nil(t, retemplate(event_))
+	assert.Equal(t, "old content", event_.Content)
+}
+
 var TestData = `{
   "ref": "refs/heads/gh-pages",
   "after": "4d2ab4e76d0d405d17d1a0f2b8a6071394e3ab40",
@@ -0,0 +1,87 @@
+package events
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/database"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/connectrix/routes"
+	"github.com/diggs/glog"
+)
+
+// DEFAULT_REPLAY_LIMIT is the maximum number of events replayed when the request doesn't set a limit
+const DEFAULT_REPLAY_LIMIT int = 1000
+
+// ReplayRequest selects stored events to replay and how to replay them
+type ReplayRequest struct {
+	database.EventFilter
+	// Route limits the replay to the route with this name
+	Route string `json:"route,omitempty"`
+	// DryRun reports what would be sent for each event without sending it
+	DryRun bool `json:"dry_run"`
+}
+
+// Replay loads the requested events and routes them again through the current routes. The content of each event is
+// templated again from its stored object, so changes to the template of its event type are applied.
+func Replay(request *ReplayRequest) ([]*routes.RouteResult, error) {
+
+	if request.EventFilter.IsEmpty() {
+		return nil, errors.New("Refusing to replay every event, select events by id, time, namespace, source or type.")
+	}
+
+	if request.Route != "" {
+		_, err := routes.FindRoute(request.Route)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	filter := request.EventFilter
+	if filter.Limit <= 0 {
+		filter.Limit = DEFAULT_REPLAY_LIMIT
+	}
+
+	events, err := database.FindEvents(&filter)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Unable to load events to replay: %v", err))
+	}
+
+	glog.Infof("Replaying %d event(s) (dry run: %v)", len(events), request.DryRun)
+	results := []*routes.RouteResult{}
+	for _, event_ := range events {
+		err = retemplate(event_)
+		if err != nil {
+			results = append(results, &routes.RouteResult{EventID: event_.ID, Route: request.Route, Error: err.Error()})
+			continue
+		}
+		results = append(results, routes.ReplayEvent(event_, request.Route, request.DryRun)...)
+	}
+
+	return results, nil
+}
+
+// retemplate replaces the stored content of the event with the content the current template of its event type
+// makes from its object. Events whose source or event type no longer exists, or that have no object, keep their
+// stored content.
+func retemplate(event_ *event.Event) error {
+
+	if event_.Object == nil {
+		return nil
+	}
+	eventSource, err := findSource(event_.Namespace, event_.Source)
+	if err != nil {
+		return nil
+	}
+
+	for _, eventType := range eventSource.Events {
+		if eventType.Type == event_.Type {
+			content, err := makeTemplatedEventContent(event_.Object, event_.Namespace, eventSource, eventType, &event_.RawData)
+			if err != nil {
+				return errors.New(fmt.Sprintf("Unable to template event %d: %v", event_.ID, err))
+			}
+			event_.Content = content
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/database"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/connectrix/parsers"
+	"time"
+)
+
+// INGEST_TOKEN_BYTES is the number of random bytes in an ingest token
+const INGEST_TOKEN_BYTES int = 24
+
+// ErrUnknownIngestToken is returned when an event is sent with a token that doesn't exist
+var ErrUnknownIngestToken = errors.New("Unknown ingest token.")
+
+func findSource(namespace string, name string) (*config.EventSource, error) {
+	ns := config.Get().Namespace(namespace)
+	if ns == nil {
+		return nil, errors.New(fmt.Sprintf("Unknown namespace: %s", namespace))
+	}
+	for _, source := range ns.Sources {
+		if source.Name == name {
+			return source, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown source: %s", name))
+}
+
+// FindIngestToken returns the ingest token for the namespace and source, or nil if one hasn't been generated yet.
+func FindIngestToken(namespace string, source string) (*database.IngestToken, error) {
+	_, err := findSource(namespace, source)
+	if err != nil {
+		return nil, err
+	}
+	return database.FindIngestToken(namespace, source)
+}
+
+// IngestTokenFor returns the ingest token for the namespace and source, generating one if it doesn't exist yet.
+func IngestTokenFor(namespace string, source string) (*database.IngestToken, error) {
+
+	_, err := findSource(namespace, source)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := database.FindIngestToken(namespace, source)
+	if err != nil || token != nil {
+		return token, err
+	}
+
+	bytes := make([]byte, INGEST_TOKEN_BYTES)
+	_, err = rand.Read(bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	token = &database.IngestToken{Token: hex.EncodeToString(bytes), Namespace: namespace, Source: source, CreatedAt: time.Now()}
+	err = database.InsertIngestToken(token)
+	if err != nil {
+		// another request may have created the token first
+		existing, findErr := database.FindIngestToken(namespace, source)
+		if findErr == nil && existing != nil {
+			return existing, nil
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ParseAndCreateEventWithToken creates an event in the namespace and from the source the ingest token was generated
+// for. The event type is identified from the hints and the parsed data as usual. ErrUnknownIngestToken is returned
+// if the token doesn't exist.
+func ParseAndCreateEventWithToken(pubChannelName string, token string, data *[]byte, hints []event.Hint, verify func(*config.EventSource) error) (int, error) {
+
+	ingestToken, err := database.GetIngestToken(token)
+	if err != nil {
+		return -1, err
+	}
+	if ingestToken == nil {
+		return -1, ErrUnknownIngestToken
+	}
+
+	eventSource, err := findSource(ingestToken.Namespace, ingestToken.Source)
+	if err != nil {
+		return -1, err
+	}
+
+	if verify != nil {
+		err = verify(eventSource)
+		if err != nil {
+			return -1, err
+		}
+	}
+
+	parserName := parsers.ChooseParser(eventSource, hints, data)
+	object, eventType, err := parsers.ParseForSource(data, ingestToken.Namespace, eventSource, parserName, hints)
+	if err != nil {
+		return -1, err
+	}
+
+	return templateAndCreateEvent(eventSource, eventType, ingestToken.Namespace, parserName, object, data)
+}
+
+// ListIngestTokens returns the ingest tokens generated for the source of the namespace.
+func ListIngestTokens(namespace string, source string) ([]*database.IngestToken, error) {
+	return database.ListIngestTokens(namespace, source)
+}
+
+// RevokeIngestToken deletes one of the source's ingest tokens, events sent with it will be rejected from then on.
+func RevokeIngestToken(namespace string, source string, token string) error {
+
+	ingestToken, err := database.GetIngestToken(token)
+	if err != nil {
+		return err
+	}
+	if ingestToken == nil || ingestToken.Namespace != namespace || ingestToken.Source != source {
+		return ErrUnknownIngestToken
+	}
+
+	return database.DeleteIngestToken(token)
+}
@@ -0,0 +1,117 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/database"
+	"github.com/diggs/glog"
+	"time"
+)
+
+// MAX_KEY_LENGTH is the longest key that can be stored
+const MAX_KEY_LENGTH int = 256
+
+func checkKey(key string) error {
+	if key == "" {
+		return errors.New("A key is required")
+	}
+	if len(key) > MAX_KEY_LENGTH {
+		return errors.New(fmt.Sprintf("Key is longer than %d characters", MAX_KEY_LENGTH))
+	}
+	return nil
+}
+
+func namespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return config.DEFAULT_NAMESPACE
+	}
+	return namespace
+}
+
+// ParseTTL parses how long a value should be kept for, e.g. "24h". An empty ttl means the value is kept until
+// it is deleted and is returned as 0.
+func ParseTTL(ttl string) (time.Duration, error) {
+	if ttl == "" {
+		return 0, nil
+	}
+	duration, err := time.ParseDuration(ttl)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("Invalid ttl: %v", err))
+	}
+	if duration <= 0 {
+		return 0, errors.New("The ttl must be positive")
+	}
+	return duration, nil
+}
+
+// expiry returns when a value stored now with the ttl expires, or nil if it never does
+func expiry(now time.Time, ttl time.Duration) *time.Time {
+	if ttl <= 0 {
+		return nil
+	}
+	expiresAt := now.Add(ttl)
+	return &expiresAt
+}
+
+// Get returns the value stored under the key in the namespace. Missing and expired values are returned as nil.
+func Get(namespace string, key string) (*database.KV, error) {
+	err := checkKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return currentStore().Get(namespaceOrDefault(namespace), key)
+}
+
+// List returns every value stored in the namespace.
+func List(namespace string) ([]*database.KV, error) {
+	return currentStore().List(namespaceOrDefault(namespace))
+}
+
+// Set stores the value under the key in the namespace, keeping it for ttl or until it is deleted if ttl is 0.
+func Set(namespace string, key string, value string, ttl time.Duration) error {
+	err := checkKey(key)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return currentStore().Set(&database.KV{
+		Namespace: namespaceOrDefault(namespace),
+		Key:       key,
+		Value:     value,
+		ExpiresAt: expiry(now, ttl),
+		UpdatedAt: now,
+	})
+}
+
+// Increment adds by to the number stored under the key in the namespace and returns the result. Missing values
+// start at 0 and are kept for ttl, or until they are deleted if ttl is 0.
+func Increment(namespace string, key string, by int64, ttl time.Duration) (int64, error) {
+	err := checkKey(key)
+	if err != nil {
+		return 0, err
+	}
+	return currentStore().Increment(namespaceOrDefault(namespace), key, by, expiry(time.Now(), ttl))
+}
+
+// Delete removes the value stored under the key in the namespace.
+func Delete(namespace string, key string) error {
+	err := checkKey(key)
+	if err != nil {
+		return err
+	}
+	return currentStore().Delete(namespaceOrDefault(namespace), key)
+}
+
+// PurgeEvery deletes expired values every interval. Expired values are never returned, purging just stops them
+// taking up space. It never returns.
+func PurgeEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		count, err := database.DeleteExpiredKV()
+		if err != nil {
+			glog.Warningf("Unable to purge expired key/values: %v", err)
+			continue
+		}
+		glog.Debugf("Purged %d expired key/value(s)", count)
+	}
+}
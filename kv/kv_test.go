@@ -0,0 +1,111 @@
+package kv
+
+import (
+	"github.com/diggs/connectrix/config"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTTL(t *testing.T) {
+
+	ttl, err := ParseTTL("")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Duration(0), ttl)
+
+	ttl, err = ParseTTL("24h")
+	assert.Nil(t, err)
+	assert.Equal(t, 24*time.Hour, ttl)
+
+	_, err = ParseTTL("tomorrow")
+	assert.NotNil(t, err)
+	_, err = ParseTTL("-1h")
+	assert.NotNil(t, err)
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Now()
+	assert.Nil(t, expiry(now, 0))
+	assert.Equal(t, now.Add(time.Hour), *expiry(now, time.Hour))
+}
+
+func TestKeysAreChecked(t *testing.T) {
+	assert.NotNil(t, Set("0", "", "value", 0))
+	assert.NotNil(t, Set("0", strings.Repeat("k", MAX_KEY_LENGTH+1), "value", 0))
+	_, err := Get("0", "")
+	assert.NotNil(t, err)
+}
+
+func TestReadsAndWrites(t *testing.T) {
+
+	defer UseStore(UseStore(NewMemoryStore()))
+
+	value, err := Get("0", "last_deploy")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+
+	assert.Nil(t, Set("0", "last_deploy", "abc123", 0))
+	value, err = Get("0", "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", value.Value)
+
+	total, err := Increment("0", "failures", 2, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	total, err = Increment("0", "failures", 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+
+	values, err := List("0")
+	assert.Nil(t, err)
+	if assert.Len(t, values, 2) {
+		assert.Equal(t, "failures", values[0].Key)
+		assert.Equal(t, "last_deploy", values[1].Key)
+	}
+
+	assert.Nil(t, Delete("0", "last_deploy"))
+	value, err = Get("0", "last_deploy")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+}
+
+func TestNamespacesAreIsolated(t *testing.T) {
+
+	defer UseStore(UseStore(NewMemoryStore()))
+
+	assert.Nil(t, Set("team-a", "last_deploy", "abc123", 0))
+	assert.Nil(t, Set("team-b", "last_deploy", "def456", 0))
+
+	value, err := Get("team-a", "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "abc123", value.Value)
+	value, err = Get("team-b", "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "def456", value.Value)
+
+	assert.Nil(t, Delete("team-a", "last_deploy"))
+	value, err = Get("team-b", "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "def456", value.Value)
+
+	// an empty namespace is the default namespace, which is separate from the others
+	assert.Nil(t, Set("", "last_deploy", "789abc", 0))
+	value, err = Get(config.DEFAULT_NAMESPACE, "last_deploy")
+	assert.Nil(t, err)
+	assert.Equal(t, "789abc", value.Value)
+	values, err := List("team-a")
+	assert.Nil(t, err)
+	assert.Len(t, values, 0)
+}
+
+func TestExpiredValuesAreMissing(t *testing.T) {
+
+	defer UseStore(UseStore(NewMemoryStore()))
+
+	assert.Nil(t, Set("0", "last_deploy", "abc123", time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	value, err := Get("0", "last_deploy")
+	assert.Nil(t, err)
+	assert.Nil(t, value)
+}
@@ -0,0 +1,161 @@
+package kv
+
+import (
+	"errors"
+	"github.com/diggs/connectrix/database"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Store is where values are kept. Values are kept in the database unless another store is used with UseStore.
+type Store interface {
+	Get(namespace string, key string) (*database.KV, error)
+	List(namespace string) ([]*database.KV, error)
+	Set(value *database.KV) error
+	Increment(namespace string, key string, by int64, expiresAt *time.Time) (int64, error)
+	Delete(namespace string, key string) error
+}
+
+type databaseStore struct{}
+
+func (databaseStore) Get(namespace string, key string) (*database.KV, error) {
+	return database.GetKV(namespace, key)
+}
+
+func (databaseStore) List(namespace string) ([]*database.KV, error) {
+	return database.ListKV(namespace)
+}
+
+func (databaseStore) Set(value *database.KV) error {
+	return database.SetKV(value)
+}
+
+func (databaseStore) Increment(namespace string, key string, by int64, expiresAt *time.Time) (int64, error) {
+	return database.IncrementKV(namespace, key, by, expiresAt)
+}
+
+func (databaseStore) Delete(namespace string, key string) error {
+	return database.DeleteKV(namespace, key)
+}
+
+var storeLock sync.RWMutex
+var store Store = databaseStore{}
+
+// UseStore replaces the store values are kept in and returns the previous one, e.g. so tests can use a MemoryStore.
+func UseStore(s Store) Store {
+	storeLock.Lock()
+	defer storeLock.Unlock()
+	previous := store
+	store = s
+	return previous
+}
+
+func currentStore() Store {
+	storeLock.RLock()
+	defer storeLock.RUnlock()
+	return store
+}
+
+// MemoryStore keeps values in memory, so they are lost when connectrix stops.
+type MemoryStore struct {
+	lock   sync.Mutex
+	values map[string]map[string]*database.KV
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{values: make(map[string]map[string]*database.KV)}
+}
+
+// live returns the value stored under the key, dropping it if it has expired
+func (s *MemoryStore) live(namespace string, key string) *database.KV {
+	value, exists := s.values[namespace][key]
+	if !exists {
+		return nil
+	}
+	if value.ExpiresAt != nil && !value.ExpiresAt.After(time.Now()) {
+		delete(s.values[namespace], key)
+		return nil
+	}
+	return value
+}
+
+func (s *MemoryStore) Get(namespace string, key string) (*database.KV, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	value := s.live(namespace, key)
+	if value == nil {
+		return nil, nil
+	}
+	copied := *value
+	return &copied, nil
+}
+
+func (s *MemoryStore) List(namespace string) ([]*database.KV, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	keys := make([]string, 0, len(s.values[namespace]))
+	for key := range s.values[namespace] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := []*database.KV{}
+	for _, key := range keys {
+		if value := s.live(namespace, key); value != nil {
+			copied := *value
+			values = append(values, &copied)
+		}
+	}
+	return values, nil
+}
+
+func (s *MemoryStore) Set(value *database.KV) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.values[value.Namespace] == nil {
+		s.values[value.Namespace] = make(map[string]*database.KV)
+	}
+	copied := *value
+	s.values[value.Namespace][value.Key] = &copied
+	return nil
+}
+
+func (s *MemoryStore) Increment(namespace string, key string, by int64, expiresAt *time.Time) (int64, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	total := by
+	value := s.live(namespace, key)
+	if value != nil {
+		current, err := strconv.ParseInt(value.Value, 10, 64)
+		if err != nil {
+			return 0, errors.New("The value isn't a number")
+		}
+		total += current
+		expiresAt = value.ExpiresAt
+	}
+
+	if s.values[namespace] == nil {
+		s.values[namespace] = make(map[string]*database.KV)
+	}
+	s.values[namespace][key] = &database.KV{
+		Namespace: namespace,
+		Key:       key,
+		Value:     strconv.FormatInt(total, 10),
+		ExpiresAt: expiresAt,
+		UpdatedAt: time.Now(),
+	}
+	return total, nil
+}
+
+func (s *MemoryStore) Delete(namespace string, key string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.values[namespace], key)
+	return nil
+}
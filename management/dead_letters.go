@@ -0,0 +1,57 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/routes"
+	"net/http"
+	"strconv"
+)
+
+// handleDeadLetters serves:
+//
+//	/dead_letters
+//	/dead_letters/{id}/redrive
+func handleDeadLetters(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	parts := pathParts(r)
+	switch {
+	case len(parts) == 1:
+		err = handleDeadLetterList(w, r)
+	case len(parts) == 3 && parts[2] == "redrive":
+		err = handleRedrive(w, r, parts[1])
+	default:
+		err = notFound("Not found: %s", r.URL.Path)
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func handleDeadLetterList(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowed(r)
+	}
+	deadLetters, err := routes.ListDeadLetters()
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, deadLetters)
+	return nil
+}
+
+func handleRedrive(w http.ResponseWriter, r *http.Request, idStr string) error {
+	if r.Method != "POST" {
+		return methodNotAllowed(r)
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return badRequest("Invalid dead letter id: %s", idStr)
+	}
+	err = routes.RedriveDeadLetter(id)
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,92 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/kv"
+	"net/http"
+)
+
+// kvRequest is the body of a request to store a value
+type kvRequest struct {
+	Value string `json:"value"`
+	TTL   string `json:"ttl,omitempty"`
+}
+
+// handleKV serves:
+//
+//	/kv/{namespace}
+//	/kv/{namespace}/{key}
+func handleKV(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	parts := pathParts(r)
+	if len(parts) > 1 && config.Get().Namespace(parts[1]) == nil {
+		writeError(w, notFound("Unknown namespace: %s", parts[1]))
+		return
+	}
+
+	switch len(parts) {
+	case 2:
+		err = handleKVList(w, r, parts[1])
+	case 3:
+		err = handleKVKey(w, r, parts[1], parts[2])
+	default:
+		err = notFound("Not found: %s", r.URL.Path)
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func handleKVList(w http.ResponseWriter, r *http.Request, namespace string) error {
+	if r.Method != "GET" {
+		return methodNotAllowed(r)
+	}
+	values, err := kv.List(namespace)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, values)
+	return nil
+}
+
+func handleKVKey(w http.ResponseWriter, r *http.Request, namespace string, key string) error {
+	switch r.Method {
+	case "GET":
+		value, err := kv.Get(namespace, key)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			return notFound("Unknown key: %s", key)
+		}
+		writeJSON(w, http.StatusOK, value)
+		return nil
+	case "PUT":
+		request := &kvRequest{}
+		err := readJSON(r, request)
+		if err != nil {
+			return err
+		}
+		ttl, err := kv.ParseTTL(request.TTL)
+		if err != nil {
+			return badRequest("%s", err.Error())
+		}
+		err = kv.Set(namespace, key, request.Value, ttl)
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	case "DELETE":
+		err := kv.Delete(namespace, key)
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
@@ -0,0 +1,163 @@
+package management
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/glog"
+	"net/http"
+	"strings"
+)
+
+const (
+	AUTHORIZATION_HEADER string = "Authorization"
+	BEARER_PREFIX        string = "Bearer "
+)
+
+// apiError is an error that should be returned to the caller with a specific status code
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &apiError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+func notFound(format string, args ...interface{}) error {
+	return &apiError{status: http.StatusNotFound, message: fmt.Sprintf(format, args...)}
+}
+
+func conflict(format string, args ...interface{}) error {
+	return &apiError{status: http.StatusConflict, message: fmt.Sprintf(format, args...)}
+}
+
+func methodNotAllowed(r *http.Request) error {
+	return &apiError{status: http.StatusMethodNotAllowed, message: fmt.Sprintf("%s is not supported on %s", r.Method, r.URL.Path)}
+}
+
+// Start starts the management API on the given port. It only returns if the listener fails, or immediately if there
+// is no token, as the API can rewrite the config and read credentials.
+func Start(port string, token string) error {
+
+	if token == "" {
+		return errors.New("The management API requires a management_token")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sources", handleSources)
+	mux.HandleFunc("/sources/", handleSources)
+	mux.HandleFunc("/routes", handleRoutes)
+	mux.HandleFunc("/routes/", handleRoutes)
+	mux.HandleFunc("/namespaces", handleNamespaces)
+	mux.HandleFunc("/namespaces/", handleNamespaces)
+	mux.HandleFunc("/channels", handleChannels)
+	mux.HandleFunc("/channels/", handleChannels)
+	mux.HandleFunc("/parsers", handleParsers)
+	mux.HandleFunc("/kv/", handleKV)
+	mux.HandleFunc("/dead_letters", handleDeadLetters)
+	mux.HandleFunc("/dead_letters/", handleDeadLetters)
+	mux.HandleFunc("/reload", handleReload)
+	mux.HandleFunc("/replay", handleReplay)
+
+	glog.Infof("Starting management API on %s...", port)
+	return http.ListenAndServe(fmt.Sprintf(":%s", port), authHandler(token, mux))
+}
+
+// authHandler rejects requests that don't carry the management token, every request is rejected if the token is empty
+func authHandler(token string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		glog.Debugf("Management API %s to %s", r.Method, r.URL)
+		supplied := strings.TrimPrefix(r.Header.Get(AUTHORIZATION_HEADER), BEARER_PREFIX)
+		if token == "" || subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) != 1 {
+			http.Error(w, "Invalid or missing management token.", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// pathParts splits the request path in to its non-empty segments e.g. /sources/GitHub/events = [sources GitHub events]
+func pathParts(r *http.Request) []string {
+	parts := []string{}
+	for _, part := range strings.Split(r.URL.Path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		return badRequest("Unable to parse request body: %s", err.Error())
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		glog.Warningf("Unable to write management API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if apiErr, ok := err.(*apiError); ok {
+		status = apiErr.status
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// update applies the change to the config and saves it to disk so the change survives a restart.
+// Errors returned by change, and validation errors, are returned to the caller.
+func update(change func(*config.ConnectrixConfig) error) error {
+
+	err := config.Update(change)
+	if err != nil {
+		return err
+	}
+
+	err = config.Save()
+	if err != nil {
+		// the change has been applied, it just won't survive a restart
+		glog.Warningf("Unable to save config changes: %v", err)
+	}
+
+	return nil
+}
+
+// requireName makes sure a value supplied in the request body agrees with the name given in the url
+func requireName(urlName string, bodyName *string, what string) error {
+	if *bodyName == "" {
+		*bodyName = urlName
+	}
+	if *bodyName != urlName {
+		return badRequest("%s name '%s' does not match url", what, *bodyName)
+	}
+	return nil
+}
+
+// handleReload reloads config.json, keeping the current config if the file isn't valid
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeError(w, methodNotAllowed(r))
+		return
+	}
+	err := config.Reload()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
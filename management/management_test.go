@@ -0,0 +1,183 @@
+package management
+
+import (
+	"encoding/json"
+	"github.com/diggs/connectrix/channels"
+	_ "github.com/diggs/connectrix/channels/http"
+	_ "github.com/diggs/connectrix/channels/smtp"
+	"github.com/diggs/connectrix/config"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAuthHandler(t *testing.T) {
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := authHandler("s3cret", ok)
+
+	r, _ := http.NewRequest("GET", "/sources", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r.Header.Set("Authorization", "Bearer wrong")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	r.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// without a token every request is rejected
+	w = httptest.NewRecorder()
+	authHandler("", ok).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotNil(t, Start("0", ""))
+}
+
+func TestPathParts(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/sources/GitHub/events/", nil)
+	assert.Equal(t, []string{"sources", "GitHub", "events"}, pathParts(r))
+}
+
+func TestGetSource(t *testing.T) {
+
+	r, _ := http.NewRequest("GET", "/sources/GitHub", nil)
+	w := httptest.NewRecorder()
+	handleSources(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var source config.EventSource
+	err := json.Unmarshal(w.Body.Bytes(), &source)
+	assert.Nil(t, err)
+	assert.Equal(t, "GitHub", source.Name)
+
+	r, _ = http.NewRequest("GET", "/sources/NotASource", nil)
+	w = httptest.NewRecorder()
+	handleSources(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestTokensForUnknownSource(t *testing.T) {
+	r, _ := http.NewRequest("POST", "/sources/NotASource/tokens", nil)
+	w := httptest.NewRecorder()
+	handleSources(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestNamespaces(t *testing.T) {
+
+	r, _ := http.NewRequest("GET", "/namespaces/missing", nil)
+	w := httptest.NewRecorder()
+	handleNamespaces(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	r, _ = http.NewRequest("POST", "/namespaces", strings.NewReader(`{"name":"0"}`))
+	w = httptest.NewRecorder()
+	handleNamespaces(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	r, _ = http.NewRequest("GET", "/sources/GitHub/tokens?namespace=missing", nil)
+	w = httptest.NewRecorder()
+	handleSources(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRedactsSecrets(t *testing.T) {
+
+	namespace := &config.Namespace{
+		Name:        "team-a",
+		Credentials: map[string]string{"jira_token": "s3cret"},
+		Channels: map[string]config.Channel{
+			"smtp": config.Channel{NamedArgs: map[string]map[string]string{"relay": {"Username": "alerts", "Password": "relay-pass"}}},
+		},
+		Sources: []*config.EventSource{
+			&config.EventSource{Name: "GitHub", Verify: &config.Verification{Scheme: "github", Secret: "hook-secret"}},
+		},
+		Routes: []*config.Route{
+			&config.Route{Name: "mail", SubChannelName: "smtp", SubChannelArgs: map[string]string{"To": "ops@example.com", "Password": "route-pass"}},
+		},
+	}
+
+	redacted := redactNamespace(namespace)
+	assert.Equal(t, map[string]string{"jira_token": REDACTED}, redacted.Credentials)
+	assert.Equal(t, map[string]string{"Username": "alerts", "Password": REDACTED}, redacted.Channels["smtp"].NamedArgs["relay"])
+	assert.Equal(t, REDACTED, redacted.Sources[0].Verify.Secret)
+	assert.Equal(t, map[string]string{"To": "ops@example.com", "Password": REDACTED}, redacted.Routes[0].SubChannelArgs)
+	assert.Nil(t, redactNamespace(&config.Namespace{Name: "team-b"}).Credentials)
+
+	// the namespace itself is untouched
+	assert.Equal(t, "s3cret", namespace.Credentials["jira_token"])
+	assert.Equal(t, "relay-pass", namespace.Channels["smtp"].NamedArgs["relay"]["Password"])
+	assert.Equal(t, "hook-secret", namespace.Sources[0].Verify.Secret)
+	assert.Equal(t, "route-pass", namespace.Routes[0].SubChannelArgs["Password"])
+
+	// sending the redacted namespace back keeps the secrets
+	restoreRedacted(redacted, namespace)
+	assert.Equal(t, namespace.Credentials, redacted.Credentials)
+	assert.Equal(t, namespace.Channels, redacted.Channels)
+	assert.Equal(t, "hook-secret", redacted.Sources[0].Verify.Secret)
+	assert.Equal(t, namespace.Routes[0].SubChannelArgs, redacted.Routes[0].SubChannelArgs)
+}
+
+func TestKVForUnknownNamespace(t *testing.T) {
+	r, _ := http.NewRequest("GET", "/kv/missing/last_deploy", nil)
+	w := httptest.NewRecorder()
+	handleKV(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestListsParsers(t *testing.T) {
+
+	r, _ := http.NewRequest("GET", "/parsers", nil)
+	w := httptest.NewRecorder()
+	handleParsers(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var names []string
+	err := json.Unmarshal(w.Body.Bytes(), &names)
+	assert.Nil(t, err)
+	assert.Equal(t, "auto", names[0])
+	assert.Contains(t, names, "json")
+	assert.Contains(t, names, "csv")
+}
+
+func TestListsChannels(t *testing.T) {
+
+	r, _ := http.NewRequest("GET", "/channels", nil)
+	w := httptest.NewRecorder()
+	handleChannels(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var summaries []*channels.Summary
+	err := json.Unmarshal(w.Body.Bytes(), &summaries)
+	assert.Nil(t, err)
+	var http_ *channels.Summary
+	for _, summary := range summaries {
+		if summary.Name == "http" {
+			http_ = summary
+		}
+	}
+	if assert.NotNil(t, http_) {
+		assert.True(t, http_.Publish)
+		assert.True(t, http_.Subscribe)
+		assert.NotEmpty(t, http_.SubArgs)
+	}
+
+	r, _ = http.NewRequest("GET", "/channels/http", nil)
+	w = httptest.NewRecorder()
+	handleChannels(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	r, _ = http.NewRequest("GET", "/channels/missing", nil)
+	w = httptest.NewRecorder()
+	handleChannels(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,178 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"net/http"
+)
+
+// checkChannel makes sure the channel is one that Connectrix knows about
+func checkChannel(channelName string) error {
+	_, pubErr := channels.GetPubChannel(channelName)
+	_, subErr := channels.GetSubChannel(channelName)
+	if pubErr != nil && subErr != nil {
+		return notFound("Unknown channel: %s", channelName)
+	}
+	return nil
+}
+
+// handleChannels serves:
+//
+//	/channels
+//	/channels/{channel}
+//	/channels/{channel}/info
+//	/channels/{channel}/named_args
+//	/channels/{channel}/named_args/{name}
+func handleChannels(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	parts := pathParts(r)
+	switch {
+	case len(parts) == 1:
+		err = handleChannelList(w, r)
+	case len(parts) == 2:
+		err = handleChannel(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "info":
+		err = handleChannelInfo(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "named_args":
+		err = handleNamedArgsList(w, r, parts[1])
+	case len(parts) == 4 && parts[2] == "named_args":
+		err = handleNamedArg(w, r, parts[1], parts[3])
+	default:
+		err = notFound("Not found: %s", r.URL.Path)
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+// handleChannelList lists the registered channels with the args their sources and routes take
+func handleChannelList(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "GET" {
+		return methodNotAllowed(r)
+	}
+	summaries := []*channels.Summary{}
+	for _, name := range channels.Registered() {
+		summary, err := channels.Summarize(name)
+		if err != nil {
+			return err
+		}
+		summaries = append(summaries, summary)
+	}
+	writeJSON(w, http.StatusOK, summaries)
+	return nil
+}
+
+func handleChannel(w http.ResponseWriter, r *http.Request, channelName string) error {
+	if r.Method != "GET" {
+		return methodNotAllowed(r)
+	}
+	summary, err := channels.Summarize(channelName)
+	if err != nil {
+		return notFound("Unknown channel: %s", channelName)
+	}
+	writeJSON(w, http.StatusOK, summary)
+	return nil
+}
+
+// handleChannelInfo returns the info needed to send events to the channel, the query params are passed to the
+// channel as args e.g. /channels/http/info?Source=GitHub&Namespace=0
+func handleChannelInfo(w http.ResponseWriter, r *http.Request, channelName string) error {
+	if r.Method != "GET" {
+		return methodNotAllowed(r)
+	}
+	channel, err := channels.GetPubChannel(channelName)
+	if err != nil {
+		return notFound("Unknown publish channel: %s", channelName)
+	}
+	args := make(map[string]string)
+	for key, val := range r.URL.Query() {
+		args[key] = val[0]
+	}
+	info := channel.PubChannelInfo(args)
+	if info == nil {
+		info = []*channels.Info{}
+	}
+	writeJSON(w, http.StatusOK, info)
+	return nil
+}
+
+func handleNamedArgsList(w http.ResponseWriter, r *http.Request, channelName string) error {
+	if r.Method != "GET" {
+		return methodNotAllowed(r)
+	}
+	err := checkChannel(channelName)
+	if err != nil {
+		return err
+	}
+	namedArgs := config.Get().Channels[channelName].NamedArgs
+	if namedArgs == nil {
+		namedArgs = map[string]map[string]string{}
+	}
+	writeJSON(w, http.StatusOK, namedArgs)
+	return nil
+}
+
+func handleNamedArg(w http.ResponseWriter, r *http.Request, channelName string, name string) error {
+
+	err := checkChannel(channelName)
+	if err != nil {
+		return err
+	}
+
+	switch r.Method {
+	case "GET":
+		args, exists := config.Get().Channels[channelName].NamedArgs[name]
+		if !exists {
+			return notFound("Unknown named args '%s' for channel '%s'", name, channelName)
+		}
+		writeJSON(w, http.StatusOK, args)
+		return nil
+	case "PUT":
+		args := map[string]string{}
+		err := readJSON(r, &args)
+		if err != nil {
+			return err
+		}
+		// named args are validated along with the sources and routes that use them
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			for otherChannel, channel := range config_.Channels {
+				if _, exists := channel.NamedArgs[name]; exists && otherChannel != channelName {
+					return conflict("Named args '%s' already exist for channel '%s'", name, otherChannel)
+				}
+			}
+			if config_.Channels == nil {
+				config_.Channels = map[string]config.Channel{}
+			}
+			channel := config_.Channels[channelName]
+			if channel.NamedArgs == nil {
+				channel.NamedArgs = map[string]map[string]string{}
+			}
+			channel.NamedArgs[name] = args
+			config_.Channels[channelName] = channel
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, args)
+		return nil
+	case "DELETE":
+		err := update(func(config_ *config.ConnectrixConfig) error {
+			channel := config_.Channels[channelName]
+			if _, exists := channel.NamedArgs[name]; !exists {
+				return notFound("Unknown named args '%s' for channel '%s'", name, channelName)
+			}
+			delete(channel.NamedArgs, name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
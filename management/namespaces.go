@@ -0,0 +1,259 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"net/http"
+)
+
+// REDACTED replaces the value of each secret in responses: credentials, verification secrets and secret channel
+// args such as passwords. A PUT can send it back to keep the current value.
+const REDACTED string = "<redacted>"
+
+// redactArgs returns a copy of the args with the values of the channel's secret args replaced
+func redactArgs(channelName string, args map[string]string) map[string]string {
+	if args == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(args))
+	for name, val := range args {
+		if val != "" && channels.IsSecretArg(channelName, name) {
+			val = REDACTED
+		}
+		redacted[name] = val
+	}
+	return redacted
+}
+
+// redactNamespace returns a copy of the namespace with its secrets replaced, so that the namespace can be seen
+// without exposing them
+func redactNamespace(namespace *config.Namespace) *config.Namespace {
+
+	redacted := *namespace
+	if namespace.Credentials != nil {
+		redacted.Credentials = make(map[string]string, len(namespace.Credentials))
+		for name := range namespace.Credentials {
+			redacted.Credentials[name] = REDACTED
+		}
+	}
+
+	if namespace.Channels != nil {
+		redacted.Channels = make(map[string]config.Channel, len(namespace.Channels))
+		for channelName, channel := range namespace.Channels {
+			namedArgs := make(map[string]map[string]string, len(channel.NamedArgs))
+			for name, args := range channel.NamedArgs {
+				namedArgs[name] = redactArgs(channelName, args)
+			}
+			channel.NamedArgs = namedArgs
+			redacted.Channels[channelName] = channel
+		}
+	}
+
+	redacted.Sources = make([]*config.EventSource, len(namespace.Sources))
+	for i, source := range namespace.Sources {
+		copied := *source
+		if source.Verify != nil {
+			verify := *source.Verify
+			verify.Secret = REDACTED
+			copied.Verify = &verify
+		}
+		copied.PubChannelArgs = redactArgs(source.PubChannelName, source.PubChannelArgs)
+		redacted.Sources[i] = &copied
+	}
+
+	redacted.Routes = make([]*config.Route, len(namespace.Routes))
+	for i, route := range namespace.Routes {
+		copied := *route
+		copied.SubChannelArgs = redactArgs(route.SubChannelName, route.SubChannelArgs)
+		redacted.Routes[i] = &copied
+	}
+
+	return &redacted
+}
+
+// restoreArgs gives the args sent back as they were redacted their current value
+func restoreArgs(args map[string]string, existing map[string]string) {
+	for name, val := range args {
+		if val == REDACTED {
+			args[name] = existing[name]
+		}
+	}
+}
+
+// restoreRedacted gives the secrets of a namespace sent back as they were redacted the current value of the secret,
+// found by the name of the credential, named args, source or route
+func restoreRedacted(namespace *config.Namespace, existing *config.Namespace) {
+
+	restoreArgs(namespace.Credentials, existing.Credentials)
+
+	for channelName, channel := range namespace.Channels {
+		for name, args := range channel.NamedArgs {
+			restoreArgs(args, existing.Channels[channelName].NamedArgs[name])
+		}
+	}
+
+	existingSources := make(map[string]*config.EventSource)
+	for _, source := range existing.Sources {
+		existingSources[source.Name] = source
+	}
+	for _, source := range namespace.Sources {
+		current, exists := existingSources[source.Name]
+		if !exists {
+			current = &config.EventSource{}
+		}
+		if source.Verify != nil && source.Verify.Secret == REDACTED {
+			source.Verify.Secret = ""
+			if current.Verify != nil {
+				source.Verify.Secret = current.Verify.Secret
+			}
+		}
+		restoreArgs(source.PubChannelArgs, current.PubChannelArgs)
+	}
+
+	existingRoutes := make(map[string]*config.Route)
+	for _, route := range existing.Routes {
+		existingRoutes[route.Name] = route
+	}
+	for _, route := range namespace.Routes {
+		current, exists := existingRoutes[route.Name]
+		if !exists {
+			current = &config.Route{}
+		}
+		restoreArgs(route.SubChannelArgs, current.SubChannelArgs)
+	}
+}
+
+func findNamespace(config_ *config.ConnectrixConfig, name string) (int, *config.Namespace) {
+	for i, namespace := range config_.Namespaces {
+		if namespace.Name == name {
+			return i, namespace
+		}
+	}
+	return -1, nil
+}
+
+// checkNamespaceName rejects the default namespace, which is managed via /sources and /routes instead
+func checkNamespaceName(name string) error {
+	if name == "" {
+		return badRequest("A namespace name is required.")
+	}
+	if name == config.DEFAULT_NAMESPACE {
+		return badRequest("Namespace '%s' is the default namespace, use /sources and /routes to manage it", name)
+	}
+	return nil
+}
+
+// handleNamespaces serves:
+//
+//	/namespaces
+//	/namespaces/{namespace}
+func handleNamespaces(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	parts := pathParts(r)
+	switch len(parts) {
+	case 1:
+		err = handleNamespaceList(w, r)
+	case 2:
+		err = handleNamespace(w, r, parts[1])
+	default:
+		err = notFound("Not found: %s", r.URL.Path)
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func handleNamespaceList(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		namespaces := []*config.Namespace{}
+		for _, namespace := range config.Get().Namespaces {
+			namespaces = append(namespaces, redactNamespace(namespace))
+		}
+		writeJSON(w, http.StatusOK, namespaces)
+		return nil
+	case "POST":
+		namespace := &config.Namespace{}
+		err := readJSON(r, namespace)
+		if err != nil {
+			return err
+		}
+		err = checkNamespaceName(namespace.Name)
+		if err != nil {
+			return err
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			if _, existing := findNamespace(config_, namespace.Name); existing != nil {
+				return conflict("Namespace '%s' already exists", namespace.Name)
+			}
+			config_.Namespaces = append(config_.Namespaces, namespace)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusCreated, redactNamespace(namespace))
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
+
+func handleNamespace(w http.ResponseWriter, r *http.Request, name string) error {
+	switch r.Method {
+	case "GET":
+		_, namespace := findNamespace(config.Get(), name)
+		if namespace == nil {
+			return notFound("Unknown namespace: %s", name)
+		}
+		writeJSON(w, http.StatusOK, redactNamespace(namespace))
+		return nil
+	case "PUT":
+		namespace := &config.Namespace{}
+		err := readJSON(r, namespace)
+		if err != nil {
+			return err
+		}
+		err = requireName(name, &namespace.Name, "Namespace")
+		if err != nil {
+			return err
+		}
+		err = checkNamespaceName(namespace.Name)
+		if err != nil {
+			return err
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			i, existing := findNamespace(config_, name)
+			if existing == nil {
+				return notFound("Unknown namespace: %s", name)
+			}
+			// secrets sent back as they were redacted keep their current value
+			restoreRedacted(namespace, existing)
+			config_.Namespaces[i] = namespace
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, redactNamespace(namespace))
+		return nil
+	case "DELETE":
+		err := update(func(config_ *config.ConnectrixConfig) error {
+			i, existing := findNamespace(config_, name)
+			if existing == nil {
+				return notFound("Unknown namespace: %s", name)
+			}
+			config_.Namespaces = append(config_.Namespaces[:i], config_.Namespaces[i+1:]...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
@@ -0,0 +1,15 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/parsers"
+	"net/http"
+)
+
+// handleParsers lists the names a source can use as its parser, which are the registered parsers and auto
+func handleParsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeError(w, methodNotAllowed(r))
+		return
+	}
+	writeJSON(w, http.StatusOK, append([]string{parsers.AUTO_PARSER}, parsers.Registered()...))
+}
@@ -0,0 +1,32 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/events"
+	"net/http"
+)
+
+// handleReplay serves:
+//
+//	/replay
+func handleReplay(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != "POST" {
+		writeError(w, methodNotAllowed(r))
+		return
+	}
+
+	request := &events.ReplayRequest{}
+	err := readJSON(r, request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	results, err := events.Replay(request)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, results)
+}
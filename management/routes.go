@@ -0,0 +1,139 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/config"
+	"net/http"
+)
+
+func findRoute(config_ *config.ConnectrixConfig, name string) (int, *config.Route) {
+	for i, route := range config_.Routes {
+		if route.Name == name {
+			return i, route
+		}
+	}
+	return -1, nil
+}
+
+// checkRouteSource makes sure the event source and type a route refers to exist
+func checkRouteSource(config_ *config.ConnectrixConfig, route *config.Route) error {
+	_, source := findSource(config_, route.EventSource)
+	if source == nil {
+		return badRequest("Route refers to unknown source: %s", route.EventSource)
+	}
+	if _, eventType := findEventType(source, route.EventType); eventType == nil {
+		return badRequest("Route refers to unknown event type '%s' for source '%s'", route.EventType, route.EventSource)
+	}
+	return nil
+}
+
+// handleRoutes serves:
+//
+//	/routes
+//	/routes/{route}
+func handleRoutes(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	parts := pathParts(r)
+	switch len(parts) {
+	case 1:
+		err = handleRouteList(w, r)
+	case 2:
+		err = handleRoute(w, r, parts[1])
+	default:
+		err = notFound("Not found: %s", r.URL.Path)
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func handleRouteList(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, config.Get().Routes)
+		return nil
+	case "POST":
+		route := &config.Route{}
+		err := readJSON(r, route)
+		if err != nil {
+			return err
+		}
+		if route.Name == "" {
+			return badRequest("A route name is required.")
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			if _, existing := findRoute(config_, route.Name); existing != nil {
+				return conflict("Route '%s' already exists", route.Name)
+			}
+			err := checkRouteSource(config_, route)
+			if err != nil {
+				return err
+			}
+			config_.Routes = append(config_.Routes, route)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusCreated, route)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
+
+func handleRoute(w http.ResponseWriter, r *http.Request, name string) error {
+	switch r.Method {
+	case "GET":
+		_, route := findRoute(config.Get(), name)
+		if route == nil {
+			return notFound("Unknown route: %s", name)
+		}
+		writeJSON(w, http.StatusOK, route)
+		return nil
+	case "PUT":
+		route := &config.Route{}
+		err := readJSON(r, route)
+		if err != nil {
+			return err
+		}
+		err = requireName(name, &route.Name, "Route")
+		if err != nil {
+			return err
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			i, existing := findRoute(config_, name)
+			if existing == nil {
+				return notFound("Unknown route: %s", name)
+			}
+			err := checkRouteSource(config_, route)
+			if err != nil {
+				return err
+			}
+			config_.Routes[i] = route
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, route)
+		return nil
+	case "DELETE":
+		err := update(func(config_ *config.ConnectrixConfig) error {
+			i, existing := findRoute(config_, name)
+			if existing == nil {
+				return notFound("Unknown route: %s", name)
+			}
+			config_.Routes = append(config_.Routes[:i], config_.Routes[i+1:]...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
@@ -0,0 +1,252 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/config"
+	"net/http"
+)
+
+func findSource(config_ *config.ConnectrixConfig, name string) (int, *config.EventSource) {
+	for i, source := range config_.Sources {
+		if source.Name == name {
+			return i, source
+		}
+	}
+	return -1, nil
+}
+
+func findEventType(source *config.EventSource, eventType string) (int, *config.EventType) {
+	for i, type_ := range source.Events {
+		if type_.Type == eventType {
+			return i, type_
+		}
+	}
+	return -1, nil
+}
+
+// handleSources serves:
+//
+//	/sources
+//	/sources/{source}
+//	/sources/{source}/events
+//	/sources/{source}/events/{type}
+//	/sources/{source}/tokens
+//	/sources/{source}/tokens/{token}
+func handleSources(w http.ResponseWriter, r *http.Request) {
+
+	var err error
+	parts := pathParts(r)
+	switch {
+	case len(parts) == 1:
+		err = handleSourceList(w, r)
+	case len(parts) == 2:
+		err = handleSource(w, r, parts[1])
+	case len(parts) == 3 && parts[2] == "events":
+		err = handleEventTypeList(w, r, parts[1])
+	case len(parts) == 4 && parts[2] == "events":
+		err = handleEventType(w, r, parts[1], parts[3])
+	case len(parts) == 3 && parts[2] == "tokens":
+		err = handleTokenList(w, r, parts[1])
+	case len(parts) == 4 && parts[2] == "tokens":
+		err = handleToken(w, r, parts[1], parts[3])
+	default:
+		err = notFound("Not found: %s", r.URL.Path)
+	}
+
+	if err != nil {
+		writeError(w, err)
+	}
+}
+
+func handleSourceList(w http.ResponseWriter, r *http.Request) error {
+	switch r.Method {
+	case "GET":
+		writeJSON(w, http.StatusOK, config.Get().Sources)
+		return nil
+	case "POST":
+		source := &config.EventSource{}
+		err := readJSON(r, source)
+		if err != nil {
+			return err
+		}
+		if source.Name == "" {
+			return badRequest("A source name is required.")
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			if _, existing := findSource(config_, source.Name); existing != nil {
+				return conflict("Source '%s' already exists", source.Name)
+			}
+			config_.Sources = append(config_.Sources, source)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusCreated, source)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
+
+func handleSource(w http.ResponseWriter, r *http.Request, name string) error {
+	switch r.Method {
+	case "GET":
+		_, source := findSource(config.Get(), name)
+		if source == nil {
+			return notFound("Unknown source: %s", name)
+		}
+		writeJSON(w, http.StatusOK, source)
+		return nil
+	case "PUT":
+		source := &config.EventSource{}
+		err := readJSON(r, source)
+		if err != nil {
+			return err
+		}
+		err = requireName(name, &source.Name, "Source")
+		if err != nil {
+			return err
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			i, existing := findSource(config_, name)
+			if existing == nil {
+				return notFound("Unknown source: %s", name)
+			}
+			config_.Sources[i] = source
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, source)
+		return nil
+	case "DELETE":
+		err := update(func(config_ *config.ConnectrixConfig) error {
+			i, existing := findSource(config_, name)
+			if existing == nil {
+				return notFound("Unknown source: %s", name)
+			}
+			for _, route := range config_.Routes {
+				if route.EventSource == name {
+					return conflict("Source '%s' is used by route '%s'", name, route.Name)
+				}
+			}
+			config_.Sources = append(config_.Sources[:i], config_.Sources[i+1:]...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
+
+func handleEventTypeList(w http.ResponseWriter, r *http.Request, sourceName string) error {
+	switch r.Method {
+	case "GET":
+		_, source := findSource(config.Get(), sourceName)
+		if source == nil {
+			return notFound("Unknown source: %s", sourceName)
+		}
+		writeJSON(w, http.StatusOK, source.Events)
+		return nil
+	case "POST":
+		eventType := &config.EventType{}
+		err := readJSON(r, eventType)
+		if err != nil {
+			return err
+		}
+		if eventType.Type == "" {
+			return badRequest("An event type is required.")
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			_, source := findSource(config_, sourceName)
+			if source == nil {
+				return notFound("Unknown source: %s", sourceName)
+			}
+			if _, existing := findEventType(source, eventType.Type); existing != nil {
+				return conflict("Event type '%s' already exists for source '%s'", eventType.Type, sourceName)
+			}
+			source.Events = append(source.Events, eventType)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusCreated, eventType)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
+
+func handleEventType(w http.ResponseWriter, r *http.Request, sourceName string, typeName string) error {
+	switch r.Method {
+	case "GET":
+		_, source := findSource(config.Get(), sourceName)
+		if source == nil {
+			return notFound("Unknown source: %s", sourceName)
+		}
+		_, eventType := findEventType(source, typeName)
+		if eventType == nil {
+			return notFound("Unknown event type '%s' for source '%s'", typeName, sourceName)
+		}
+		writeJSON(w, http.StatusOK, eventType)
+		return nil
+	case "PUT":
+		eventType := &config.EventType{}
+		err := readJSON(r, eventType)
+		if err != nil {
+			return err
+		}
+		err = requireName(typeName, &eventType.Type, "Event type")
+		if err != nil {
+			return err
+		}
+		err = update(func(config_ *config.ConnectrixConfig) error {
+			_, source := findSource(config_, sourceName)
+			if source == nil {
+				return notFound("Unknown source: %s", sourceName)
+			}
+			i, existing := findEventType(source, typeName)
+			if existing == nil {
+				return notFound("Unknown event type '%s' for source '%s'", typeName, sourceName)
+			}
+			source.Events[i] = eventType
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, eventType)
+		return nil
+	case "DELETE":
+		err := update(func(config_ *config.ConnectrixConfig) error {
+			_, source := findSource(config_, sourceName)
+			if source == nil {
+				return notFound("Unknown source: %s", sourceName)
+			}
+			i, existing := findEventType(source, typeName)
+			if existing == nil {
+				return notFound("Unknown event type '%s' for source '%s'", typeName, sourceName)
+			}
+			for _, route := range config_.Routes {
+				if route.EventSource == sourceName && route.EventType == typeName {
+					return conflict("Event type '%s' is used by route '%s'", typeName, route.Name)
+				}
+			}
+			source.Events = append(source.Events[:i], source.Events[i+1:]...)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
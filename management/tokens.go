@@ -0,0 +1,91 @@
+package management
+
+import (
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events"
+	"net/http"
+)
+
+// tokenRequest is the body of a request to generate an ingest token
+type tokenRequest struct {
+	Namespace string `json:"namespace"`
+}
+
+// tokenNamespace returns the namespace given by the namespace query param, defaulting to the default namespace
+func tokenNamespace(r *http.Request) string {
+	namespace := r.URL.Query().Get("namespace")
+	if namespace == "" {
+		return config.DEFAULT_NAMESPACE
+	}
+	return namespace
+}
+
+// checkTokenSource makes sure the source exists in the namespace tokens are being managed for
+func checkTokenSource(namespace string, sourceName string) error {
+	ns := config.Get().Namespace(namespace)
+	if ns == nil {
+		return notFound("Unknown namespace: %s", namespace)
+	}
+	for _, source := range ns.Sources {
+		if source.Name == sourceName {
+			return nil
+		}
+	}
+	return notFound("Unknown source: %s", sourceName)
+}
+
+func handleTokenList(w http.ResponseWriter, r *http.Request, sourceName string) error {
+
+	switch r.Method {
+	case "GET":
+		namespace := tokenNamespace(r)
+		err := checkTokenSource(namespace, sourceName)
+		if err != nil {
+			return err
+		}
+		tokens, err := events.ListIngestTokens(namespace, sourceName)
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, tokens)
+		return nil
+	case "POST":
+		request := &tokenRequest{}
+		if r.ContentLength != 0 {
+			err := readJSON(r, request)
+			if err != nil {
+				return err
+			}
+		}
+		if request.Namespace == "" {
+			request.Namespace = tokenNamespace(r)
+		}
+		err := checkTokenSource(request.Namespace, sourceName)
+		if err != nil {
+			return err
+		}
+		token, err := events.IngestTokenFor(request.Namespace, sourceName)
+		if err != nil {
+			return err
+		}
+		writeJSON(w, http.StatusOK, token)
+		return nil
+	default:
+		return methodNotAllowed(r)
+	}
+}
+
+func handleToken(w http.ResponseWriter, r *http.Request, sourceName string, token string) error {
+	if r.Method != "DELETE" {
+		return methodNotAllowed(r)
+	}
+	err := events.RevokeIngestToken(tokenNamespace(r), sourceName, token)
+	if err == events.ErrUnknownIngestToken {
+		return notFound("Unknown ingest token for source %s", sourceName)
+	}
+	if err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
@@ -0,0 +1,141 @@
+package parsers
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events/event"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+)
+
+const (
+	// AUTO_PARSER chooses the parser from the Content-Type of the event, or by sniffing the data
+	AUTO_PARSER string = "auto"
+
+	CONTENT_TYPE_HINT string = "header:Content-Type"
+)
+
+// contentTypeParsers are the parsers the auto parser uses for each Content-Type
+var contentTypeParsers = map[string]string{
+	"application/json":                  "json",
+	"text/json":                         "json",
+	"application/xml":                   "xml",
+	"text/xml":                          "xml",
+	"application/yaml":                  "yaml",
+	"application/x-yaml":                "yaml",
+	"text/yaml":                         "yaml",
+	"text/x-yaml":                       "yaml",
+	"application/x-www-form-urlencoded": "form",
+	"text/csv":                          "csv",
+	"text/plain":                        "raw",
+}
+
+// formPattern matches a form body of one or more name=value pairs
+var formPattern = regexp.MustCompile(`^[^=&\s]+=[^&\s]*(&[^=&\s]+=[^&\s]*)*$`)
+
+// contentType returns the media type of the Content-Type hint in lower case, without params such as the charset
+func contentType(hints []event.Hint) string {
+	for _, hint := range hints {
+		if hint.HasKey(CONTENT_TYPE_HINT) {
+			mediaType, _, err := mime.ParseMediaType(hint.Value)
+			if err != nil {
+				return ""
+			}
+			return mediaType
+		}
+	}
+	return ""
+}
+
+// ChooseParser returns the name of the parser to parse the event data with. A parser the source maps the event's
+// Content-Type to is used first, then the source's parser. The auto parser uses the parser for the Content-Type, or
+// sniffs the data if the Content-Type is missing or unknown.
+func ChooseParser(eventSource *config.EventSource, hints []event.Hint, data *[]byte) string {
+
+	mediaType := contentType(hints)
+	if mediaType != "" {
+		for sourceType, parserName := range eventSource.ContentTypes {
+			if strings.EqualFold(sourceType, mediaType) {
+				return parserName
+			}
+		}
+	}
+
+	if eventSource.Parser != AUTO_PARSER {
+		return eventSource.Parser
+	}
+	if parserName, exists := contentTypeParsers[mediaType]; exists {
+		return parserName
+	}
+	switch {
+	case strings.HasSuffix(mediaType, "+json"):
+		return "json"
+	case strings.HasSuffix(mediaType, "+xml"):
+		return "xml"
+	}
+	return sniff(*data)
+}
+
+// sniff guesses the parser from the data: json for a JSON object or array, xml for an XML document, form for
+// name=value pairs joined by &, and raw for anything else
+func sniff(data []byte) string {
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case len(trimmed) == 0:
+		return "raw"
+	case (trimmed[0] == '{' || trimmed[0] == '[') && isJson(trimmed):
+		return "json"
+	case trimmed[0] == '<' && isXml(trimmed):
+		return "xml"
+	case formPattern.Match(trimmed):
+		return "form"
+	}
+	return "raw"
+}
+
+// isJson returns true if the data is valid JSON
+func isJson(data []byte) bool {
+	var raw json.RawMessage
+	return json.Unmarshal(data, &raw) == nil
+}
+
+// isXml returns true if the data is well formed XML with at least one element
+func isXml(data []byte) bool {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	elements := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return elements > 0
+		}
+		if err != nil {
+			return false
+		}
+		if _, ok := token.(xml.StartElement); ok {
+			elements++
+		}
+	}
+}
+
+// ValidateContentTypes returns an error if a source maps a Content-Type to an unknown parser, or to the auto parser
+func ValidateContentTypes(contentTypes map[string]string) error {
+	for mediaType, parserName := range contentTypes {
+		if !strings.Contains(mediaType, "/") {
+			return errors.New(fmt.Sprintf("Invalid Content-Type '%s', expected a media type such as application/json", mediaType))
+		}
+		if parserName == AUTO_PARSER {
+			return errors.New(fmt.Sprintf("Content-Type '%s' can't use the auto parser", mediaType))
+		}
+		if _, err := makeParser(parserName, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
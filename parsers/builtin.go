@@ -0,0 +1,83 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/parsers/csv"
+	"github.com/diggs/connectrix/parsers/email"
+	"github.com/diggs/connectrix/parsers/form"
+	"github.com/diggs/connectrix/parsers/json"
+	"github.com/diggs/connectrix/parsers/raw"
+	"github.com/diggs/connectrix/parsers/snmp"
+	"github.com/diggs/connectrix/parsers/syslog"
+	"github.com/diggs/connectrix/parsers/xml"
+	"github.com/diggs/connectrix/parsers/yaml"
+	"strings"
+)
+
+// the built in parsers register themselves like any other parser
+func init() {
+	Register("raw", raw.RawParser{})
+	Register("json", json.JsonParser{})
+	Register("xml", xmlParser{})
+	Register("yaml", yaml.YamlParser{})
+	Register("form", form.FormParser{})
+	Register("csv", csvParser{})
+	Register("email", email.EmailParser{})
+	Register("snmp", snmpParser{})
+	Register("syslog", syslog.SyslogParser{})
+}
+
+// snmpParser names OIDs with the names given in the config of the snmp channel as well as any given as options. The
+// channel config is read as each trap is parsed, so names added by reloading the config are used straight away.
+type snmpParser struct {
+	options map[string]string
+}
+
+func (p snmpParser) ParseContent(data *[]byte) (interface{}, error) {
+	names := make(map[string]string)
+	for key, val := range config.Get().Channels["snmp"].Config {
+		names[key] = val
+	}
+	for key, val := range p.options {
+		names[key] = val
+	}
+	return snmp.SnmpParser{Names: snmp.Names(names)}.ParseContent(data)
+}
+
+func (p snmpParser) WithOptions(options map[string]string) (Parser, error) {
+	for key := range options {
+		if !strings.HasPrefix(key, snmp.NAME_CONFIG_PREFIX) {
+			return nil, errors.New(fmt.Sprintf("Unknown snmp parser option '%s', OIDs are named with options like name:1.3.6.1.4.1.2021.251.1", key))
+		}
+	}
+	return snmpParser{options: options}, nil
+}
+
+// xmlParser and csvParser make the parsers' options available, their packages can't refer to Parser as this package
+// imports them
+
+type xmlParser struct {
+	xml.XmlParser
+}
+
+func (p xmlParser) WithOptions(options map[string]string) (Parser, error) {
+	parser, err := p.XmlParser.WithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return xmlParser{parser}, nil
+}
+
+type csvParser struct {
+	csv.CsvParser
+}
+
+func (p csvParser) WithOptions(options map[string]string) (Parser, error) {
+	parser, err := p.CsvParser.WithOptions(options)
+	if err != nil {
+		return nil, err
+	}
+	return csvParser{parser}, nil
+}
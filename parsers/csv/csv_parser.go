@@ -0,0 +1,89 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	DELIMITER_OPTION string = "delimiter"
+	COMMENT_OPTION   string = "comment"
+)
+
+// CsvParser parses CSV with a header row into the list of columns and a list of rows, each row a map of column to
+// value. Rows with fewer values than there are columns have empty values for the missing columns.
+type CsvParser struct {
+	// Delimiter separates the values, a comma if not set
+	Delimiter rune
+	// Comment starts lines that are ignored, lines aren't treated as comments if not set
+	Comment rune
+}
+
+// WithOptions returns a copy of the parser using the delimiter and comment options, each a single character. The
+// delimiter can also be given as tab.
+func (p CsvParser) WithOptions(options map[string]string) (CsvParser, error) {
+	for key, val := range options {
+		if key != DELIMITER_OPTION && key != COMMENT_OPTION {
+			return p, errors.New(fmt.Sprintf("Unknown csv parser option '%s', expected %s or %s", key, DELIMITER_OPTION, COMMENT_OPTION))
+		}
+		if key == DELIMITER_OPTION && val == "tab" {
+			val = "\t"
+		}
+		runes := []rune(val)
+		if len(runes) != 1 || strings.ContainsRune("\"\r\n", runes[0]) {
+			return p, errors.New(fmt.Sprintf("Invalid csv parser %s '%s', expected a single character", key, val))
+		}
+		if key == DELIMITER_OPTION {
+			p.Delimiter = runes[0]
+		} else {
+			p.Comment = runes[0]
+		}
+	}
+	if p.Delimiter != 0 && p.Delimiter == p.Comment {
+		return p, errors.New("The csv parser's delimiter and comment can't be the same")
+	}
+	return p, nil
+}
+
+func (p CsvParser) ParseContent(data *[]byte) (interface{}, error) {
+
+	reader := csv.NewReader(bytes.NewReader(*data))
+	if p.Delimiter != 0 {
+		reader.Comma = p.Delimiter
+	}
+	reader.Comment = p.Comment
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("CSV has no header row")
+	}
+
+	header := records[0]
+	columns := make([]interface{}, len(header))
+	for i := range header {
+		columns[i] = header[i]
+	}
+	rows := make([]interface{}, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			row[column] = ""
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return map[string]interface{}{
+		"columns": columns,
+		"rows":    rows,
+	}, nil
+}
@@ -0,0 +1,55 @@
+package csv
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsesCsv(t *testing.T) {
+
+	data := []byte("host, status, message\ndb1, failed, \"disk full, 98%\"\nweb2,ok\n")
+	object, err := CsvParser{}.ParseContent(&data)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"columns": []interface{}{"host", "status", "message"},
+		"rows": []interface{}{
+			map[string]interface{}{"host": "db1", "status": "failed", "message": "disk full, 98%"},
+			map[string]interface{}{"host": "web2", "status": "ok", "message": ""},
+		},
+	}, object)
+}
+
+func TestRejectsBadCsv(t *testing.T) {
+
+	for _, data := range [][]byte{
+		[]byte(""),
+		[]byte("host,status\n\"db1,failed\n"),
+	} {
+		_, err := CsvParser{}.ParseContent(&data)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestParsesCsvWithOptions(t *testing.T) {
+
+	parser, err := CsvParser{}.WithOptions(map[string]string{DELIMITER_OPTION: ";", COMMENT_OPTION: "#"})
+	assert.Nil(t, err)
+	data := []byte("# exported nightly\nhost;status\ndb1;failed, again\n")
+	object, err := parser.ParseContent(&data)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{map[string]interface{}{"host": "db1", "status": "failed, again"}}, object.(map[string]interface{})["rows"])
+
+	parser, err = CsvParser{}.WithOptions(map[string]string{DELIMITER_OPTION: "tab"})
+	assert.Nil(t, err)
+	assert.Equal(t, '\t', parser.Delimiter)
+
+	for _, options := range []map[string]string{
+		{DELIMITER_OPTION: "::"},
+		{DELIMITER_OPTION: "\n"},
+		{DELIMITER_OPTION: ";", COMMENT_OPTION: ";"},
+		{"quote": "'"},
+	} {
+		_, err = CsvParser{}.WithOptions(options)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,239 @@
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/events/event"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+const (
+	TO_HINT      string = "email:to"
+	FROM_HINT    string = "email:from"
+	SUBJECT_HINT string = "email:subject"
+
+	// MAX_DEPTH is how deeply multipart bodies are followed
+	MAX_DEPTH int = 10
+)
+
+// EmailParser parses an email (RFC 5322) into its headers, addresses, subject, text and HTML bodies and the
+// metadata of its attachments. The content of attachments isn't kept.
+type EmailParser struct {
+}
+
+var headerDecoder = new(mime.WordDecoder)
+
+func (EmailParser) ParseContent(data *[]byte) (interface{}, error) {
+
+	message, err := mail.ReadMessage(bytes.NewReader(*data))
+	if err != nil {
+		return nil, err
+	}
+
+	headers := make(map[string]interface{}, len(message.Header))
+	for name, values := range message.Header {
+		// headers such as Received are repeated, their values are kept in order as a list
+		if len(values) == 1 {
+			headers[name] = decodeHeader(values[0])
+			continue
+		}
+		list := make([]interface{}, len(values))
+		for i, value := range values {
+			list[i] = decodeHeader(value)
+		}
+		headers[name] = list
+	}
+
+	object := map[string]interface{}{
+		"headers":     headers,
+		"subject":     decodeHeader(message.Header.Get("Subject")),
+		"message_id":  strings.Trim(message.Header.Get("Message-Id"), "<>"),
+		"from":        "",
+		"from_name":   "",
+		"to":          addresses(message.Header, "To"),
+		"cc":          addresses(message.Header, "Cc"),
+		"date":        "",
+		"text":        "",
+		"html":        "",
+		"attachments": []interface{}{},
+	}
+
+	if from := addresses(message.Header, "From"); len(from) > 0 {
+		object["from"] = from[0]
+		if address, err := mail.ParseAddress(message.Header.Get("From")); err == nil {
+			object["from_name"] = address.Name
+		}
+	}
+	if date, err := message.Header.Date(); err == nil {
+		object["date"] = date.UTC().Format(time.RFC3339)
+	}
+
+	err = readPart(mimeHeader(message.Header), message.Body, object, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	return object, nil
+}
+
+// Hints returns an email:to hint for each recipient, including the recipients of the envelope the email was
+// received with which may not appear in its headers, and email:from and email:subject hints.
+func Hints(data *[]byte, recipients []string) ([]event.Hint, error) {
+
+	message, err := mail.ReadMessage(bytes.NewReader(*data))
+	if err != nil {
+		return nil, err
+	}
+
+	hints := []event.Hint{}
+	seen := make(map[string]bool)
+	to := append(append([]string{}, recipients...), addressList(message.Header, "To")...)
+	for _, address := range append(to, addressList(message.Header, "Cc")...) {
+		address = strings.ToLower(address)
+		if !seen[address] {
+			seen[address] = true
+			hints = append(hints, event.Hint{Key: TO_HINT, Value: address, Text: "To:" + address})
+		}
+	}
+	for _, address := range addressList(message.Header, "From") {
+		address = strings.ToLower(address)
+		hints = append(hints, event.Hint{Key: FROM_HINT, Value: address, Text: "From:" + address})
+	}
+	subject := decodeHeader(message.Header.Get("Subject"))
+	hints = append(hints, event.Hint{Key: SUBJECT_HINT, Value: subject, Text: "Subject:" + subject})
+
+	return hints, nil
+}
+
+func decodeHeader(value string) string {
+	decoded, err := headerDecoder.DecodeHeader(value)
+	if err != nil {
+		return value
+	}
+	return decoded
+}
+
+// addressList returns the bare addresses in the header, e.g. alerts@example.com
+func addressList(header mail.Header, name string) []string {
+	list := []string{}
+	parsed, err := header.AddressList(name)
+	if err != nil {
+		return list
+	}
+	for _, address := range parsed {
+		list = append(list, address.Address)
+	}
+	return list
+}
+
+// addresses returns the bare addresses in the header as they are exposed on the parsed object
+func addresses(header mail.Header, name string) []interface{} {
+	list := []interface{}{}
+	for _, address := range addressList(header, name) {
+		list = append(list, address)
+	}
+	return list
+}
+
+// mimeHeader is the part of a header readPart needs, satisfied by both mail.Header and textproto.MIMEHeader
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// readPart reads the text and HTML bodies and the attachments of a message or part of a message into the object
+func readPart(header mimeHeader, body io.Reader, object map[string]interface{}, depth int) error {
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// treat parts with an unreadable content type as binary rather than failing the whole email
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= MAX_DEPTH {
+			return errors.New(fmt.Sprintf("Email is nested more than %d levels deep", MAX_DEPTH))
+		}
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := parts.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			err = readPart(part.Header, part, object, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	content, err := ioutil.ReadAll(decodeBody(header.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return err
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	if disposition != "attachment" && filename == "" {
+		switch {
+		case mediaType == "text/plain" && object["text"] == "":
+			object["text"] = toUtf8(content, params["charset"])
+			return nil
+		case mediaType == "text/html" && object["html"] == "":
+			object["html"] = toUtf8(content, params["charset"])
+			return nil
+		}
+	}
+
+	object["attachments"] = append(object["attachments"].([]interface{}), map[string]interface{}{
+		"filename":     decodeHeader(filename),
+		"content_type": mediaType,
+		"size":         len(content),
+	})
+	return nil
+}
+
+// decodeBody undoes the transfer encoding of a body. multipart.Reader already decodes quoted-printable parts and
+// removes their Content-Transfer-Encoding header.
+func decodeBody(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// the decoder skips the line breaks base64 bodies are wrapped with
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// toUtf8 converts latin-1 text, the most common charset other than utf-8, to utf-8. Other charsets are left as is.
+func toUtf8(content []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1":
+		runes := make([]rune, len(content))
+		for i, b := range content {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return string(content)
+}
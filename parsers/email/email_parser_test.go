@@ -0,0 +1,96 @@
+package email
+
+import (
+	"github.com/diggs/connectrix/events/event"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+var backupFailed = strings.Replace(`Received: from nas.example.com by mx1.example.com
+Received: from localhost by nas.example.com
+From: "Backup Appliance" <backup@nas.example.com>
+To: alerts@connectrix.example.com, Ops <ops@example.com>
+Cc: boss@example.com
+Subject: =?utf-8?q?Backup_failed_=E2=80=94_nightly?=
+Date: Sat, 25 Jul 2015 12:00:00 +0100
+Message-ID: <1234@nas.example.com>
+X-Job: nightly
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="mixed"
+
+--mixed
+Content-Type: multipart/alternative; boundary="alt"
+
+--alt
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+The nightly backup failed =E2=80=94 disk full.
+--alt
+Content-Type: text/html; charset=iso-8859-1
+
+<p>The nightly backup failed: caf`+"\xe9"+`</p>
+--alt--
+
+--mixed
+Content-Type: text/plain; name="backup.log"
+Content-Disposition: attachment; filename="backup.log"
+Content-Transfer-Encoding: base64
+
+ZGlzayBmdWxs
+--mixed--
+`, "\n", "\r\n", -1)
+
+func TestParsesEmail(t *testing.T) {
+
+	data := []byte(backupFailed)
+	parsed, err := EmailParser{}.ParseContent(&data)
+	assert.Nil(t, err)
+	object := parsed.(map[string]interface{})
+
+	assert.Equal(t, "backup@nas.example.com", object["from"])
+	assert.Equal(t, "Backup Appliance", object["from_name"])
+	assert.Equal(t, []interface{}{"alerts@connectrix.example.com", "ops@example.com"}, object["to"])
+	assert.Equal(t, []interface{}{"boss@example.com"}, object["cc"])
+	assert.Equal(t, "Backup failed — nightly", object["subject"])
+	assert.Equal(t, "2015-07-25T11:00:00Z", object["date"])
+	assert.Equal(t, "1234@nas.example.com", object["message_id"])
+	assert.Equal(t, "nightly", object["headers"].(map[string]interface{})["X-Job"])
+	assert.Equal(t, []interface{}{"from nas.example.com by mx1.example.com", "from localhost by nas.example.com"}, object["headers"].(map[string]interface{})["Received"])
+	assert.Equal(t, "The nightly backup failed — disk full.", object["text"])
+	assert.Equal(t, "<p>The nightly backup failed: café</p>", object["html"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"filename": "backup.log", "content_type": "text/plain", "size": 9},
+	}, object["attachments"])
+}
+
+func TestParsesPlainEmail(t *testing.T) {
+
+	data := []byte("From: cron@nas.example.com\r\nSubject: Cron <root@nas> run-parts\r\n\r\nAll done\r\n")
+	parsed, err := EmailParser{}.ParseContent(&data)
+	assert.Nil(t, err)
+	object := parsed.(map[string]interface{})
+	assert.Equal(t, "All done\r\n", object["text"])
+	assert.Equal(t, []interface{}{}, object["to"])
+	assert.Equal(t, []interface{}{}, object["attachments"])
+
+	data = []byte("not an email")
+	_, err = EmailParser{}.ParseContent(&data)
+	assert.NotNil(t, err)
+}
+
+func TestHints(t *testing.T) {
+
+	data := []byte(backupFailed)
+	hints, err := Hints(&data, []string{"Alerts@Connectrix.example.com", "hidden@connectrix.example.com"})
+	assert.Nil(t, err)
+	assert.Equal(t, []event.Hint{
+		{Key: TO_HINT, Value: "alerts@connectrix.example.com", Text: "To:alerts@connectrix.example.com"},
+		{Key: TO_HINT, Value: "hidden@connectrix.example.com", Text: "To:hidden@connectrix.example.com"},
+		{Key: TO_HINT, Value: "ops@example.com", Text: "To:ops@example.com"},
+		{Key: TO_HINT, Value: "boss@example.com", Text: "To:boss@example.com"},
+		{Key: FROM_HINT, Value: "backup@nas.example.com", Text: "From:backup@nas.example.com"},
+		{Key: SUBJECT_HINT, Value: "Backup failed — nightly", Text: "Subject:Backup failed — nightly"},
+	}, hints)
+}
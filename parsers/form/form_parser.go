@@ -0,0 +1,34 @@
+package form
+
+import (
+	"net/url"
+	"strings"
+)
+
+// FormParser parses an application/x-www-form-urlencoded body, as sent by Slack slash commands and some CI tools.
+// Fields given once are strings and fields given more than once are lists of strings.
+type FormParser struct {
+}
+
+func (FormParser) ParseContent(data *[]byte) (interface{}, error) {
+
+	values, err := url.ParseQuery(strings.TrimSpace(string(*data)))
+	if err != nil {
+		return nil, err
+	}
+
+	object := make(map[string]interface{}, len(values))
+	for name, vals := range values {
+		if len(vals) == 1 {
+			object[name] = vals[0]
+			continue
+		}
+		list := make([]interface{}, len(vals))
+		for i := range vals {
+			list[i] = vals[i]
+		}
+		object[name] = list
+	}
+
+	return object, nil
+}
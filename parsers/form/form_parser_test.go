@@ -0,0 +1,23 @@
+package form
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsesForm(t *testing.T) {
+
+	data := []byte("token=abc&command=%2Fdeploy&text=web+to+production&channel=ops&channel=alerts\n")
+	object, err := FormParser{}.ParseContent(&data)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"token":   "abc",
+		"command": "/deploy",
+		"text":    "web to production",
+		"channel": []interface{}{"ops", "alerts"},
+	}, object)
+
+	data = []byte("text=%zz")
+	_, err = FormParser{}.ParseContent(&data)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,93 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events/event"
+	"regexp"
+	"strings"
+)
+
+const (
+	OP_EXACT  string = "exact"
+	OP_PREFIX string = "prefix"
+	OP_GLOB   string = "glob"
+	OP_REGEX  string = "regex"
+)
+
+// globToRegex converts a glob, where * matches any run of characters and ? matches a single character, into an
+// anchored regular expression
+func globToRegex(glob string) string {
+	pattern := regexp.QuoteMeta(glob)
+	pattern = strings.Replace(pattern, `\*`, ".*", -1)
+	pattern = strings.Replace(pattern, `\?`, ".", -1)
+	return "^" + pattern + "$"
+}
+
+// ValidateMatcher returns an error if the matcher's key is missing, its op is unknown or its pattern doesn't compile.
+func ValidateMatcher(matcher *config.Matcher) error {
+
+	if matcher.Key == "" {
+		return errors.New("A hint key is required, e.g. header:User-Agent")
+	}
+
+	switch matcher.Op {
+	case "", OP_EXACT, OP_PREFIX, OP_GLOB:
+		return nil
+	case OP_REGEX:
+		_, err := regexp.Compile(matcher.Value)
+		return err
+	default:
+		return errors.New(fmt.Sprintf("Unknown match op '%s', expected %s, %s, %s or %s", matcher.Op, OP_EXACT, OP_PREFIX, OP_GLOB, OP_REGEX))
+	}
+}
+
+func matchesValue(matcher *config.Matcher, value string) bool {
+	switch matcher.Op {
+	case "", OP_EXACT:
+		return value == matcher.Value
+	case OP_PREFIX:
+		return strings.HasPrefix(value, matcher.Value)
+	case OP_GLOB:
+		match, _ := regexp.MatchString(globToRegex(matcher.Value), value)
+		return match
+	case OP_REGEX:
+		match, _ := regexp.MatchString(matcher.Value, value)
+		return match
+	}
+	return false
+}
+
+// isMatchingHint returns true if any hint with the matcher's key has a matching value
+func isMatchingHint(matcher *config.Matcher, hints []event.Hint) bool {
+	for _, hint := range hints {
+		if hint.HasKey(matcher.Key) && matchesValue(matcher, hint.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+func isMatch(match *config.Match, hints []event.Hint) bool {
+
+	if len(match.All) == 0 && len(match.Any) == 0 {
+		return false
+	}
+
+	for _, matcher := range match.All {
+		if !isMatchingHint(matcher, hints) {
+			return false
+		}
+	}
+
+	if len(match.Any) == 0 {
+		return true
+	}
+	for _, matcher := range match.Any {
+		if isMatchingHint(matcher, hints) {
+			return true
+		}
+	}
+	return false
+}
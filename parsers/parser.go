@@ -3,3 +3,10 @@ package parsers
 type Parser interface {
 	ParseContent(*[]byte) (interface{}, error)
 }
+
+// Configurable is implemented by parsers that take options from config, e.g. the delimiter of the csv parser
+type Configurable interface {
+	Parser
+	// WithOptions returns a copy of the parser using the options, or an error if an option is unknown or invalid
+	WithOptions(map[string]string) (Parser, error)
+}
@@ -4,40 +4,39 @@ import (
 	"errors"
 	"fmt"
 	"github.com/diggs/connectrix/config"
-	"github.com/diggs/connectrix/parsers/json"
-	"github.com/diggs/connectrix/parsers/xml"
-	"github.com/diggs/connectrix/parsers/yaml"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/connectrix/rules"
 	"github.com/diggs/glog"
-	"regexp"
+	"strings"
 )
 
-func isPositiveHint(hint string, hints []string) bool {
+// isPositiveHint matches the free text hints used before structured matching was added. The hint matches if it
+// appears anywhere in the text of one of the hints.
+func isPositiveHint(hint string, hints []event.Hint) bool {
 	for i := range hints {
-		match, _ := regexp.Match(fmt.Sprintf(".*%s.*", regexp.QuoteMeta(hint)), []byte(hints[i]))
-		if match {
+		if hints[i].Text != "" && strings.Contains(hints[i].Text, hint) {
 			return true
 		}
 	}
 	return false
 }
 
-func makeParser(parserName string) (Parser, error) {
-	switch parserName {
-	case "json":
-		return json.JsonParser{}, nil
-	case "xml":
-		return xml.XmlParser{}, nil
-	case "yaml":
-		return yaml.YamlParser{}, nil
-	default:
-		return nil, errors.New(fmt.Sprintf("Unknown parser: '%s'", parserName))
-	}
-}
-
-func findEventSource(hints []string) (*config.EventSource, error) {
+// findEventSource identifies the event source from the hints, only considering the sources of the namespace
+func findEventSource(namespace string, hints []event.Hint) (*config.EventSource, error) {
 
-	sources := config.Get().Sources
+	ns := config.Get().Namespace(namespace)
+	if ns == nil {
+		return nil, errors.New(fmt.Sprintf("Unknown namespace: '%s'", namespace))
+	}
+	sources := ns.Sources
 	for i := range sources {
+		// structured matches are used instead of the free text hints when given
+		if sources[i].Match != nil {
+			if isMatch(sources[i].Match, hints) {
+				return sources[i], nil
+			}
+			continue
+		}
 		// if the source has a hint try match on that
 		if sources[i].Hint != "" {
 			if isPositiveHint(sources[i].Hint, hints) {
@@ -53,28 +52,57 @@ func findEventSource(hints []string) (*config.EventSource, error) {
 	return nil, errors.New(fmt.Sprintf("Unable to identify event source using hints '%v'", hints))
 }
 
-func findEventType(eventSource *config.EventSource, hints []string) (*config.EventType, error) {
+// isHintedEventType checks the hints against the event type's match, or its free text hint and type name
+func isHintedEventType(eventType *config.EventType, hints []event.Hint) bool {
+	// structured matches are used instead of the free text hints when given
+	if eventType.Match != nil {
+		return isMatch(eventType.Match, hints)
+	}
+	// if the event has a hint try match on that
+	if eventType.Hint != "" && isPositiveHint(eventType.Hint, hints) {
+		return true
+	}
+	// fall back to matching on the event type itself
+	return isPositiveHint(eventType.Type, hints)
+}
+
+// findEventType identifies the event type from the hints and, for event types with a rule, the parsed event object.
+// An event type with a rule but no match or hint is identified by its rule alone.
+func findEventType(namespace string, eventSource *config.EventSource, hints []event.Hint, object interface{}) (*config.EventType, error) {
 
 	eventTypes := eventSource.Events
 	for i := range eventTypes {
-		// if the event has a hint try match on that
-		if eventTypes[i].Hint != "" {
-			if isPositiveHint(eventTypes[i].Hint, hints) {
-				return eventTypes[i], nil
+		eventType := eventTypes[i]
+		if eventType.Rule == "" || eventType.Match != nil || eventType.Hint != "" {
+			if !isHintedEventType(eventType, hints) {
+				continue
 			}
 		}
-		// fall back to matching on the event type itself
-		if isPositiveHint(eventTypes[i].Type, hints) {
-			return eventTypes[i], nil
+		if eventType.Rule != "" {
+			passed, err := rules.Eval(rules.EventTypeName(namespace, eventSource.Name, eventType.Type), eventType.Rule, namespace, object)
+			if err != nil {
+				glog.Warningf("Unable to evaluate rule for %s:%s event type: %v", eventSource.Name, eventType.Type, err)
+				continue
+			}
+			if !passed {
+				continue
+			}
 		}
+		return eventType, nil
 	}
 
 	return nil, errors.New(fmt.Sprintf("Unable to identify event type using hints '%v'", hints))
 }
 
+// Parse parses the data with the named parser, using the options for the parser from the parsers section of the
+// config
 func Parse(data *[]byte, parserName string) (interface{}, error) {
+	return parse(data, parserName, Options(parserName, nil))
+}
+
+func parse(data *[]byte, parserName string, options map[string]string) (interface{}, error) {
 
-	parser, err := makeParser(parserName)
+	parser, err := makeParser(parserName, options)
 	if err != nil {
 		return nil, err
 	}
@@ -88,14 +116,16 @@ func Parse(data *[]byte, parserName string) (interface{}, error) {
 	return object, nil
 }
 
-func IdentifyWithHints(hints []string) (*config.EventSource, *config.EventType, error) {
-	eventSource, err := findEventSource(hints)
+// IdentifyWithHints identifies the event source of the namespace from the hints and then the event type from the
+// hints and the event object.
+func IdentifyWithHints(namespace string, hints []event.Hint, object interface{}) (*config.EventSource, *config.EventType, error) {
+	eventSource, err := findEventSource(namespace, hints)
 	if err != nil {
 		return nil, nil, err
 	}
 	glog.Debugf("Identified event source as: %s", eventSource.Name)
 
-	eventType, err := findEventType(eventSource, hints)
+	eventType, err := findEventType(namespace, eventSource, hints, object)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -104,20 +134,52 @@ func IdentifyWithHints(hints []string) (*config.EventSource, *config.EventType,
 	return eventSource, eventType, nil
 }
 
-func ParseWithHints(data *[]byte, hints []string) (interface{}, *config.EventSource, *config.EventType, error) {
+// IdentifySource identifies the event source of the namespace from the hints.
+func IdentifySource(namespace string, hints []event.Hint) (*config.EventSource, error) {
 
-	glog.Debugf("Attempting to parse event using hints: %v", hints)
+	glog.Debugf("Attempting to identify event source in namespace %s using hints: %v", namespace, hints)
 
-	eventSource, eventType, err := IdentifyWithHints(hints)
+	eventSource, err := findEventSource(namespace, hints)
+	if err != nil {
+		return nil, err
+	}
+	glog.Debugf("Identified event source as: %s", eventSource.Name)
+
+	return eventSource, nil
+}
+
+// ParseForSource parses the data with the named parser, usually chosen for the namespace's event source by
+// ChooseParser, using the source's options for the parser, and then identifies the event type from the hints and the parsed object.
+func ParseForSource(data *[]byte, namespace string, eventSource *config.EventSource, parserName string, hints []event.Hint) (interface{}, *config.EventType, error) {
+
+	object, err := parse(data, parserName, Options(parserName, eventSource))
+	if err != nil {
+		return nil, nil, err
+	}
+	glog.Debugf("Successfully parsed event: %v", object)
+
+	eventType, err := findEventType(namespace, eventSource, hints, object)
+	if err != nil {
+		return nil, nil, err
+	}
+	glog.Debugf("Identified event type as: %s", eventType.Type)
+
+	return object, eventType, nil
+}
+
+// ParseWithHints identifies the event source of the namespace from the hints, parses the data with the parser chosen
+// for the source and then identifies the event type from the hints and the parsed object.
+func ParseWithHints(data *[]byte, namespace string, hints []event.Hint) (interface{}, *config.EventSource, *config.EventType, error) {
+
+	eventSource, err := IdentifySource(namespace, hints)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	object, err := Parse(data, eventSource.Parser)
+	object, eventType, err := ParseForSource(data, namespace, eventSource, ChooseParser(eventSource, hints, data), hints)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	glog.Debugf("Successfully parsed event: %v", object)
 
 	return object, eventSource, eventType, nil
 }
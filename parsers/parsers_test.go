@@ -2,32 +2,125 @@ package parsers
 
 import (
 	"encoding/json"
-	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events/event"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 )
 
 func TestIdentifiesEventSource(t *testing.T) {
 
-	trueSourceHints := []string{"GitHub-Hookshot", "GitHub", "User-Agent:GitHub-Hookshot", "User-Agent:GitHub-Hookshot/", "User-Agent:GitHub-Hookshot/5684589df"}
-	falseSourceHints := []string{"User-Agent", "Hookshot", "Git"}
+	trueSourceHints := []event.Hint{
+		{Key: "header:User-Agent", Value: "GitHub-Hookshot/5684589df"},
+		{Key: "header:user-agent", Value: "GitHub-Hookshot/"},
+	}
+	falseSourceHints := []event.Hint{
+		{Key: "header:User-Agent", Value: "Git"},
+		{Key: "header:User-Agent", Value: "GitHub-Hookshot"},
+		{Key: "header:User-Agent", Value: "Mozilla GitHub-Hookshot/5684589df"},
+		{Key: "header:X-User-Agent", Value: "GitHub-Hookshot/5684589df"},
+		{Key: "query:source", Value: "GitHub"},
+	}
 
 	for i := range falseSourceHints {
-		_, err := findEventSource([]string{falseSourceHints[i]})
+		_, err := findEventSource(config.DEFAULT_NAMESPACE, []event.Hint{falseSourceHints[i]})
 		assert.NotNil(t, err)
 	}
 
 	for i := range trueSourceHints {
-		eventSource, err := findEventSource([]string{trueSourceHints[i]})
+		eventSource, err := findEventSource(config.DEFAULT_NAMESPACE, []event.Hint{trueSourceHints[i]})
 		assert.Nil(t, err)
 		assert.Equal(t, "GitHub", eventSource.Name, "Expected event source to be identified as GitHub")
 	}
 }
 
+func TestMatchOps(t *testing.T) {
+
+	hints := []event.Hint{{Key: "header:User-Agent", Value: "GitHub-Hookshot/5684589df"}, {Key: "query:event", Value: "build"}}
+
+	tests := map[*config.Matcher]bool{
+		&config.Matcher{Key: "query:event", Value: "build"}:                                           true,
+		&config.Matcher{Key: "query:event", Op: OP_EXACT, Value: "buil"}:                              false,
+		&config.Matcher{Key: "header:User-Agent", Op: OP_PREFIX, Value: "GitHub-"}:                    true,
+		&config.Matcher{Key: "header:User-Agent", Op: OP_PREFIX, Value: "Hookshot"}:                   false,
+		&config.Matcher{Key: "header:User-Agent", Op: OP_GLOB, Value: "GitHub-*/*"}:                   true,
+		&config.Matcher{Key: "header:User-Agent", Op: OP_GLOB, Value: "Git"}:                          false,
+		&config.Matcher{Key: "header:User-Agent", Op: OP_REGEX, Value: "^GitHub-Hookshot/[0-9a-f]+$"}: true,
+		&config.Matcher{Key: "header:User-Agent", Op: OP_REGEX, Value: "^Hookshot"}:                   false,
+		&config.Matcher{Key: "query:source", Value: "build"}:                                          false,
+	}
+
+	for matcher, expected := range tests {
+		assert.Equal(t, expected, isMatchingHint(matcher, hints), "%+v", matcher)
+	}
+
+	circle := &config.Matcher{Key: "query:event", Value: "build"}
+	github := &config.Matcher{Key: "header:User-Agent", Op: OP_PREFIX, Value: "GitHub-"}
+	missing := &config.Matcher{Key: "query:source", Value: "circleci"}
+
+	assert.True(t, isMatch(&config.Match{All: []*config.Matcher{circle, github}}, hints))
+	assert.False(t, isMatch(&config.Match{All: []*config.Matcher{circle, missing}}, hints))
+	assert.True(t, isMatch(&config.Match{Any: []*config.Matcher{missing, github}}, hints))
+	assert.False(t, isMatch(&config.Match{All: []*config.Matcher{circle}, Any: []*config.Matcher{missing}}, hints))
+	assert.False(t, isMatch(&config.Match{}, hints))
+}
+
+func TestValidateMatcher(t *testing.T) {
+	assert.Nil(t, ValidateMatcher(&config.Matcher{Key: "query:source", Value: "circleci"}))
+	assert.NotNil(t, ValidateMatcher(&config.Matcher{Value: "circleci"}))
+	assert.NotNil(t, ValidateMatcher(&config.Matcher{Key: "query:source", Op: "contains", Value: "circle"}))
+	assert.NotNil(t, ValidateMatcher(&config.Matcher{Key: "query:source", Op: OP_REGEX, Value: "("}))
+}
+
+func TestIdentifiesEventTypeFromPayload(t *testing.T) {
+
+	gitLab := &config.EventSource{
+		Name: "GitLab",
+		Events: []*config.EventType{
+			&config.EventType{Type: "push", Rule: `object_kind == "push"`},
+			&config.EventType{Type: "merge", Rule: `object_kind == "merge_request" && object_attributes.state == "merged"`},
+			&config.EventType{Type: "tag", Rule: `object_kind == "tag_push"`, Match: &config.Match{All: []*config.Matcher{&config.Matcher{Key: "header:X-Gitlab-Event", Value: "Tag Push Hook"}}}},
+		},
+	}
+	hints := []event.Hint{{Key: "header:X-Gitlab-Event", Value: "Push Hook"}}
+
+	decode := func(data string) interface{} {
+		var object interface{}
+		json.Unmarshal([]byte(data), &object)
+		return object
+	}
+
+	eventType, err := findEventType(config.DEFAULT_NAMESPACE, gitLab, hints, decode(`{"object_kind":"push"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "push", eventType.Type)
+
+	eventType, err = findEventType(config.DEFAULT_NAMESPACE, gitLab, hints, decode(`{"object_kind":"merge_request", "object_attributes":{"state":"merged"}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "merge", eventType.Type)
+
+	_, err = findEventType(config.DEFAULT_NAMESPACE, gitLab, hints, decode(`{"object_kind":"merge_request", "object_attributes":{"state":"opened"}}`))
+	assert.NotNil(t, err)
+
+	// the tag event type needs both its match and its rule to pass
+	_, err = findEventType(config.DEFAULT_NAMESPACE, gitLab, hints, decode(`{"object_kind":"tag_push"}`))
+	assert.NotNil(t, err)
+	eventType, err = findEventType(config.DEFAULT_NAMESPACE, gitLab, []event.Hint{{Key: "header:X-Gitlab-Event", Value: "Tag Push Hook"}}, decode(`{"object_kind":"tag_push"}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "tag", eventType.Type)
+}
+
+func TestLegacyHints(t *testing.T) {
+	hints := []event.Hint{{Key: "header:User-Agent", Value: "GitHub-Hookshot/458f8", Text: "User-Agent:GitHub-Hookshot/458f8"}, {Key: "irc:sender", Value: "diggs"}}
+	assert.True(t, isPositiveHint("User-Agent:GitHub-Hookshot", hints))
+	assert.False(t, isPositiveHint("X-Github-Event:push", hints))
+	assert.False(t, isPositiveHint("diggs", hints))
+}
+
 func TestParseJsonEvent(t *testing.T) {
 
 	data := []byte(gitHubPushData.data)
-	object, eventSource, eventType, err := ParseWithHints(&data, gitHubPushData.hints)
+	object, eventSource, eventType, err := ParseWithHints(&data, config.DEFAULT_NAMESPACE, gitHubPushData.hints)
 	assert.Nil(t, err)
 	assert.NotNil(t, object)
 	assert.Equal(t, "GitHub", eventSource.Name)
@@ -49,13 +142,17 @@ func TestParseJsonEvent(t *testing.T) {
 
 type TestData struct {
 	content string
-	hints   []string
+	hints   []event.Hint
 	data    string
 }
 
 var gitHubPushData = TestData{
 	content: "baxterthehacker committed to public-repo - https://github.com/baxterthehacker/public-repo/commit/4d2ab4e76d0d405d17d1a0f2b8a6071394e3ab40",
-	hints:   []string{"Content-Type:application/json", "User-Agent:GitHub-Hookshot/458f8", "X-Github-Event:push"},
+	hints: []event.Hint{
+		{Key: "header:Content-Type", Value: "application/json"},
+		{Key: "header:User-Agent", Value: "GitHub-Hookshot/458f8"},
+		{Key: "header:X-Github-Event", Value: "push"},
+	},
 	data: `{
   "ref": "refs/heads/gh-pages",
   "after": "4d2ab4e76d0d405d17d1a0f2b8a6071394e3ab40",
@@ -196,3 +293,116 @@ var gitHubPushData = TestData{
     "email": "baxterthehacker@users.noreply.github.com"
   }}`,
 }
+
+func TestUnknownNamespace(t *testing.T) {
+	_, err := findEventSource("missing", []event.Hint{{Key: "header:User-Agent", Value: "GitHub-Hookshot/5684589df"}})
+	assert.NotNil(t, err)
+}
+
+func TestDefaultsToRawParser(t *testing.T) {
+	data := []byte("backup of db1 failed")
+	object, err := Parse(&data, "")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"body": "backup of db1 failed", "length": 20}, object)
+	assert.Nil(t, ValidateParser(""))
+	assert.NotNil(t, ValidateParser("jsn"))
+}
+
+func TestChoosesParser(t *testing.T) {
+
+	auto := &config.EventSource{Parser: AUTO_PARSER}
+	contentType := func(value string) []event.Hint {
+		return []event.Hint{{Key: "header:content-type", Value: value}}
+	}
+	data := []byte("not sniffed")
+
+	assert.Equal(t, "json", ChooseParser(auto, contentType("application/json; charset=utf-8"), &data))
+	assert.Equal(t, "json", ChooseParser(auto, contentType("application/vnd.github+json"), &data))
+	assert.Equal(t, "xml", ChooseParser(auto, contentType("text/xml"), &data))
+	assert.Equal(t, "yaml", ChooseParser(auto, contentType("application/x-yaml"), &data))
+	assert.Equal(t, "form", ChooseParser(auto, contentType("application/x-www-form-urlencoded"), &data))
+	assert.Equal(t, "raw", ChooseParser(auto, contentType("text/plain"), &data))
+
+	// sources can map Content-Types to parsers, whether or not they use the auto parser
+	mapped := &config.EventSource{Parser: "json", ContentTypes: map[string]string{"Text/CSV": "csv"}}
+	assert.Equal(t, "csv", ChooseParser(mapped, contentType("text/csv; header=present"), &data))
+	assert.Equal(t, "json", ChooseParser(mapped, contentType("text/plain"), &data))
+	assert.Equal(t, "json", ChooseParser(mapped, nil, &data))
+}
+
+func TestSniffsParser(t *testing.T) {
+
+	tests := map[string]string{
+		" {\"action\":\"opened\"}\n":         "json",
+		"[1, 2, 3]":                          "json",
+		"{not json":                          "raw",
+		"<?xml version=\"1.0\"?><build/>":    "xml",
+		"<build><status>failed</status>":     "raw",
+		"<13>Oct 11 22:14:15 backup: failed": "raw",
+		"token=abc&text=deploy+web&empty=":   "form",
+		"build failed = bad":                 "raw",
+		"":                                   "raw",
+	}
+
+	auto := &config.EventSource{Parser: AUTO_PARSER}
+	for data, expected := range tests {
+		data_ := []byte(data)
+		assert.Equal(t, expected, ChooseParser(auto, []event.Hint{{Key: CONTENT_TYPE_HINT, Value: "application/octet-stream"}}, &data_), data)
+		assert.Equal(t, expected, ChooseParser(auto, nil, &data_), data)
+	}
+}
+
+func TestValidatesContentTypes(t *testing.T) {
+	assert.Nil(t, ValidateParser(AUTO_PARSER))
+	assert.Nil(t, ValidateContentTypes(map[string]string{"text/csv": "csv", "application/json": "json"}))
+	assert.NotNil(t, ValidateContentTypes(map[string]string{"csv": "csv"}))
+	assert.NotNil(t, ValidateContentTypes(map[string]string{"text/csv": "auto"}))
+	assert.NotNil(t, ValidateContentTypes(map[string]string{"text/csv": "excel"}))
+}
+
+// upperParser is a custom parser, as a team with a proprietary format would register
+type upperParser struct {
+	suffix string
+}
+
+func (p upperParser) ParseContent(data *[]byte) (interface{}, error) {
+	return strings.ToUpper(string(*data)) + p.suffix, nil
+}
+
+func (p upperParser) WithOptions(options map[string]string) (Parser, error) {
+	return upperParser{suffix: options["suffix"]}, nil
+}
+
+// unregister removes a parser registered by a test, so the test can be run again
+func unregister(name string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.m, name)
+}
+
+func TestRegistersParsers(t *testing.T) {
+
+	Register("upper", upperParser{})
+	defer unregister("upper")
+	assert.Contains(t, Registered(), "upper")
+	assert.Contains(t, Registered(), "json")
+	assert.NotContains(t, Registered(), AUTO_PARSER)
+	assert.Panics(t, func() { Register("upper", upperParser{}) })
+	assert.Panics(t, func() { Register(AUTO_PARSER, upperParser{}) })
+
+	data := []byte("build failed")
+	object, err := Parse(&data, "upper")
+	assert.Nil(t, err)
+	assert.Equal(t, "BUILD FAILED", object)
+
+	source := &config.EventSource{ParserOptions: map[string]map[string]string{"upper": {"suffix": "!"}}}
+	object, err = parse(&data, "upper", Options("upper", source))
+	assert.Nil(t, err)
+	assert.Equal(t, "BUILD FAILED!", object)
+
+	assert.Nil(t, ValidateOptions("upper", map[string]string{"suffix": "!"}))
+	assert.Nil(t, ValidateOptions("csv", map[string]string{"delimiter": "tab"}))
+	assert.NotNil(t, ValidateOptions("json", map[string]string{"strict": "true"}))
+	assert.NotNil(t, ValidateOptions(AUTO_PARSER, map[string]string{"delimiter": ";"}))
+	assert.NotNil(t, ValidateOptions("excel", nil))
+}
@@ -0,0 +1,13 @@
+package raw
+
+// RawParser passes the data through as a string, for events whose content is just text such as a line sent to the
+// socket channel. It's used by sources that don't name a parser.
+type RawParser struct {
+}
+
+func (RawParser) ParseContent(data *[]byte) (interface{}, error) {
+	return map[string]interface{}{
+		"body":   string(*data),
+		"length": len(*data),
+	}, nil
+}
@@ -0,0 +1,108 @@
+package parsers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"sort"
+	"sync"
+)
+
+// DEFAULT_PARSER is used by sources that don't name a parser
+const DEFAULT_PARSER string = "raw"
+
+var registry = struct {
+	sync.RWMutex
+	m map[string]Parser
+}{m: make(map[string]Parser)}
+
+// Register makes a parser available to sources by name. It's meant to be called from init, and panics if the name
+// is already registered, so that a parser can't be replaced by accident.
+func Register(name string, parser Parser) {
+	registry.Lock()
+	defer registry.Unlock()
+	if name == "" || name == AUTO_PARSER {
+		panic(fmt.Sprintf("parsers: invalid parser name '%s'", name))
+	}
+	if parser == nil {
+		panic(fmt.Sprintf("parsers: parser %s is nil", name))
+	}
+	if _, exists := registry.m[name]; exists {
+		panic(fmt.Sprintf("parsers: parser %s is already registered", name))
+	}
+	registry.m[name] = parser
+}
+
+// Registered returns the names of the registered parsers in alphabetical order. The auto parser isn't included as
+// it chooses one of the registered parsers rather than parsing the data itself.
+func Registered() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.m))
+	for name := range registry.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// makeParser returns the named parser configured with the options, sources that don't name a parser use the
+// default parser
+func makeParser(parserName string, options map[string]string) (Parser, error) {
+
+	if parserName == "" {
+		parserName = DEFAULT_PARSER
+	}
+	registry.RLock()
+	parser, exists := registry.m[parserName]
+	registry.RUnlock()
+	if !exists {
+		return nil, errors.New(fmt.Sprintf("Unknown parser: '%s'", parserName))
+	}
+
+	if len(options) == 0 {
+		return parser, nil
+	}
+	configurable, ok := parser.(Configurable)
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("The %s parser doesn't take options", parserName))
+	}
+	return configurable.WithOptions(options)
+}
+
+// Options returns the options of the named parser, which are the options given in the parsers section of the config
+// overridden by the options the event source gives for the parser, if any
+func Options(parserName string, eventSource *config.EventSource) map[string]string {
+
+	if parserName == "" {
+		parserName = DEFAULT_PARSER
+	}
+	options := make(map[string]string)
+	for key, val := range config.Get().Parsers[parserName] {
+		options[key] = val
+	}
+	if eventSource != nil {
+		for key, val := range eventSource.ParserOptions[parserName] {
+			options[key] = val
+		}
+	}
+	return options
+}
+
+// ValidateParser returns an error if parserName isn't a registered parser or the auto parser.
+func ValidateParser(parserName string) error {
+	if parserName == AUTO_PARSER {
+		return nil
+	}
+	_, err := makeParser(parserName, nil)
+	return err
+}
+
+// ValidateOptions returns an error if the named parser doesn't take options, or doesn't accept the options given.
+func ValidateOptions(parserName string, options map[string]string) error {
+	if parserName == AUTO_PARSER {
+		return errors.New("The auto parser doesn't take options, give options for the parsers it chooses instead")
+	}
+	_, err := makeParser(parserName, options)
+	return err
+}
@@ -0,0 +1,185 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// BER tags used by SNMP v1 and v2c
+const (
+	TAG_INTEGER      byte = 0x02
+	TAG_OCTET_STRING byte = 0x04
+	TAG_NULL         byte = 0x05
+	TAG_OID          byte = 0x06
+	TAG_SEQUENCE     byte = 0x30
+	TAG_IP_ADDRESS   byte = 0x40
+	TAG_COUNTER32    byte = 0x41
+	TAG_GAUGE32      byte = 0x42
+	TAG_TIMETICKS    byte = 0x43
+	TAG_OPAQUE       byte = 0x44
+	TAG_COUNTER64    byte = 0x46
+
+	TAG_NO_SUCH_OBJECT   byte = 0x80
+	TAG_NO_SUCH_INSTANCE byte = 0x81
+	TAG_END_OF_MIB_VIEW  byte = 0x82
+
+	TAG_INFORM   byte = 0xa6
+	TAG_RESPONSE byte = 0xa2
+	TAG_TRAP_V1  byte = 0xa4
+	TAG_TRAP_V2  byte = 0xa7
+)
+
+// tlv is a BER encoded value: its tag, its content and where it and its content start in the data it was read from
+type tlv struct {
+	tag    byte
+	value  []byte
+	start  int
+	offset int
+}
+
+// reader reads BER encoded values from a packet
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) more() bool {
+	return r.pos < len(r.data)
+}
+
+// next reads the next value. SNMP only uses single byte tags, but lengths can be in the long form.
+func (r *reader) next() (*tlv, error) {
+
+	if r.pos+2 > len(r.data) {
+		return nil, errors.New("Truncated SNMP packet")
+	}
+	tag := r.data[r.pos]
+	length := int(r.data[r.pos+1])
+	pos := r.pos + 2
+	if length&0x80 != 0 {
+		bytes := length & 0x7f
+		if bytes == 0 || bytes > 4 || pos+bytes > len(r.data) {
+			return nil, errors.New("Invalid length in SNMP packet")
+		}
+		length = 0
+		for _, b := range r.data[pos : pos+bytes] {
+			length = length<<8 | int(b)
+		}
+		pos += bytes
+	}
+	if length < 0 || pos+length > len(r.data) {
+		return nil, errors.New("Truncated SNMP packet")
+	}
+
+	value := &tlv{tag: tag, value: r.data[pos : pos+length], start: r.pos, offset: pos}
+	r.pos = pos + length
+	return value, nil
+}
+
+// expect reads the next value, which must have the tag
+func (r *reader) expect(tag byte, what string) (*tlv, error) {
+	value, err := r.next()
+	if err != nil {
+		return nil, err
+	}
+	if value.tag != tag {
+		return nil, errors.New(fmt.Sprintf("Expected %s in SNMP packet, got tag 0x%02x", what, value.tag))
+	}
+	return value, nil
+}
+
+// children returns a reader of the values inside a sequence
+func (v *tlv) children() *reader {
+	return &reader{data: v.value}
+}
+
+func decodeInt(b []byte) int64 {
+	var i int64
+	for n, c := range b {
+		if n == 0 && c&0x80 != 0 {
+			i = -1
+		}
+		i = i<<8 | int64(c)
+	}
+	return i
+}
+
+func decodeUint(b []byte) uint64 {
+	var i uint64
+	for _, c := range b {
+		i = i<<8 | uint64(c)
+	}
+	return i
+}
+
+func decodeOID(b []byte) (string, error) {
+
+	if len(b) == 0 {
+		return "", errors.New("Empty OID in SNMP packet")
+	}
+	parts := []string{}
+	var sub uint64
+	for i, c := range b {
+		sub = sub<<7 | uint64(c&0x7f)
+		if c&0x80 != 0 {
+			if i == len(b)-1 {
+				return "", errors.New("Truncated OID in SNMP packet")
+			}
+			continue
+		}
+		if len(parts) == 0 {
+			// the first byte holds the first two parts
+			first := sub / 40
+			if first > 2 {
+				first = 2
+			}
+			parts = append(parts, strconv.FormatUint(first, 10), strconv.FormatUint(sub-first*40, 10))
+		} else {
+			parts = append(parts, strconv.FormatUint(sub, 10))
+		}
+		sub = 0
+	}
+	return strings.Join(parts, "."), nil
+}
+
+func decodeIP(b []byte) string {
+	if len(b) != 4 {
+		return fmt.Sprintf("% x", b)
+	}
+	return fmt.Sprintf("%d.%d.%d.%d", b[0], b[1], b[2], b[3])
+}
+
+// decodeValue converts the value of a varbind into a value templates and rules can use. Octet strings are
+// strings if they are printable, or hex otherwise.
+func decodeValue(v *tlv) (interface{}, error) {
+	switch v.tag {
+	case TAG_INTEGER:
+		return decodeInt(v.value), nil
+	case TAG_OCTET_STRING, TAG_OPAQUE:
+		if printable(v.value) {
+			return string(v.value), nil
+		}
+		return strings.Replace(fmt.Sprintf("% x", v.value), " ", ":", -1), nil
+	case TAG_OID:
+		return decodeOID(v.value)
+	case TAG_IP_ADDRESS:
+		return decodeIP(v.value), nil
+	case TAG_COUNTER32, TAG_GAUGE32, TAG_TIMETICKS, TAG_COUNTER64:
+		return decodeUint(v.value), nil
+	case TAG_NULL, TAG_NO_SUCH_OBJECT, TAG_NO_SUCH_INSTANCE, TAG_END_OF_MIB_VIEW:
+		return nil, nil
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown value type 0x%02x in SNMP packet", v.tag))
+}
+
+func printable(b []byte) bool {
+	for _, c := range b {
+		if (c < 0x20 || c == 0x7f) && c != '\n' && c != '\r' && c != '\t' {
+			return false
+		}
+	}
+	return utf8.Valid(b)
+}
@@ -0,0 +1,259 @@
+package snmp
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// SNMP_TRAP_ENTERPRISE_OID is the varbind naming the enterprise of a v2c trap
+const SNMP_TRAP_ENTERPRISE_OID string = "1.3.6.1.6.3.1.1.4.3.0"
+
+// varbindTypes are the types a varbind can be given, using the letters of net-snmp's snmptrap command
+var varbindTypes = map[string]string{
+	"i": "INTEGER",
+	"u": "Gauge32",
+	"c": "Counter32",
+	"t": "TimeTicks",
+	"a": "IpAddress",
+	"o": "OBJECT IDENTIFIER",
+	"s": "STRING",
+	"x": "hex STRING",
+	"n": "NULL",
+}
+
+func encodeTLV(tag byte, content ...[]byte) []byte {
+
+	length := 0
+	for _, c := range content {
+		length += len(c)
+	}
+
+	encoded := []byte{tag}
+	if length < 0x80 {
+		encoded = append(encoded, byte(length))
+	} else {
+		lengthBytes := []byte{}
+		for n := length; n > 0; n >>= 8 {
+			lengthBytes = append([]byte{byte(n)}, lengthBytes...)
+		}
+		encoded = append(append(encoded, 0x80|byte(len(lengthBytes))), lengthBytes...)
+	}
+	for _, c := range content {
+		encoded = append(encoded, c...)
+	}
+	return encoded
+}
+
+// encodeInt encodes a signed integer in as few bytes as possible
+func encodeInt(tag byte, i int64) []byte {
+	content := []byte{byte(i)}
+	for n := i >> 8; ; n >>= 8 {
+		// stop once the remaining bytes are just the sign of the bytes so far
+		if (n == 0 && content[0]&0x80 == 0) || (n == -1 && content[0]&0x80 != 0) {
+			break
+		}
+		content = append([]byte{byte(n)}, content...)
+	}
+	return encodeTLV(tag, content)
+}
+
+// encodeUint encodes an unsigned integer, with a leading zero if the top bit would otherwise make it negative
+func encodeUint(tag byte, u uint64) []byte {
+	content := []byte{byte(u)}
+	for n := u >> 8; n > 0; n >>= 8 {
+		content = append([]byte{byte(n)}, content...)
+	}
+	if content[0]&0x80 != 0 {
+		content = append([]byte{0}, content...)
+	}
+	return encodeTLV(tag, content)
+}
+
+func encodeOID(oid string) ([]byte, error) {
+
+	parts := strings.Split(strings.Trim(oid, ". "), ".")
+	if len(parts) < 2 {
+		return nil, errors.New(fmt.Sprintf("Invalid OID '%s'", oid))
+	}
+	subs := make([]uint64, len(parts))
+	for i, part := range parts {
+		sub, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid OID '%s'", oid))
+		}
+		subs[i] = sub
+	}
+	if subs[0] > 2 || (subs[0] < 2 && subs[1] >= 40) {
+		return nil, errors.New(fmt.Sprintf("Invalid OID '%s'", oid))
+	}
+
+	// the first two parts share the first byte
+	subs = append([]uint64{subs[0]*40 + subs[1]}, subs[2:]...)
+	content := []byte{}
+	for _, sub := range subs {
+		encoded := []byte{byte(sub & 0x7f)}
+		for n := sub >> 7; n > 0; n >>= 7 {
+			encoded = append([]byte{byte(n&0x7f) | 0x80}, encoded...)
+		}
+		content = append(content, encoded...)
+	}
+	return encodeTLV(TAG_OID, content), nil
+}
+
+// ValidateOID returns an error if the OID isn't a dotted OID, e.g. 1.3.6.1.4.1.8072
+func ValidateOID(oid string) error {
+	_, err := encodeOID(oid)
+	return err
+}
+
+// EncodeVarbind encodes a varbind from its OID, a type (i, u, c, t, a, o, s, x or n, as used by net-snmp's
+// snmptrap command) and its value as text
+func EncodeVarbind(oid string, varbindType string, value string) ([]byte, error) {
+
+	name, err := encodeOID(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded []byte
+	switch varbindType {
+	case "i":
+		i, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid INTEGER '%s' for %s", value, oid))
+		}
+		encoded = encodeInt(TAG_INTEGER, i)
+	case "u", "c", "t":
+		u, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid %s '%s' for %s", varbindTypes[varbindType], value, oid))
+		}
+		tag := map[string]byte{"u": TAG_GAUGE32, "c": TAG_COUNTER32, "t": TAG_TIMETICKS}[varbindType]
+		encoded = encodeUint(tag, u)
+	case "a":
+		ip := net.ParseIP(value).To4()
+		if ip == nil {
+			return nil, errors.New(fmt.Sprintf("Invalid IpAddress '%s' for %s", value, oid))
+		}
+		encoded = encodeTLV(TAG_IP_ADDRESS, ip)
+	case "o":
+		encoded, err = encodeOID(value)
+		if err != nil {
+			return nil, err
+		}
+	case "s":
+		encoded = encodeTLV(TAG_OCTET_STRING, []byte(value))
+	case "x":
+		bytes, err := hex.DecodeString(strings.NewReplacer(" ", "", ":", "").Replace(value))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Invalid hex STRING '%s' for %s", value, oid))
+		}
+		encoded = encodeTLV(TAG_OCTET_STRING, bytes)
+	case "n":
+		encoded = encodeTLV(TAG_NULL)
+	default:
+		return nil, errors.New(fmt.Sprintf("Unknown type '%s' for %s, expected one of i, u, c, t, a, o, s, x or n", varbindType, oid))
+	}
+
+	return encodeTLV(TAG_SEQUENCE, name, encoded), nil
+}
+
+// ParseVarbinds encodes varbinds written one per line as <oid> <type> <value>, e.g. 1.3.6.1.4.1.8072.2.3.2.1 i 42.
+// The value is the rest of the line, so strings can contain spaces. Blank lines are ignored.
+func ParseVarbinds(text string) ([][]byte, error) {
+
+	varbinds := [][]byte{}
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 3)
+		if len(fields) < 2 {
+			return nil, errors.New(fmt.Sprintf("Line %d of the varbinds should be <oid> <type> <value>", n+1))
+		}
+		value := ""
+		if len(fields) == 3 {
+			value = strings.TrimSpace(fields[2])
+		}
+		varbind, err := EncodeVarbind(fields[0], fields[1], value)
+		if err != nil {
+			return nil, err
+		}
+		varbinds = append(varbinds, varbind)
+	}
+	return varbinds, nil
+}
+
+// EncodeTrap encodes a v2c trap, or an inform if inform is true. The sysUpTime.0 and snmpTrapOID.0 varbinds are
+// added before the given varbinds, followed by snmpTrapEnterprise.0 if an enterprise is given.
+func EncodeTrap(community string, inform bool, requestID int32, uptime uint32, trapOID string, enterprise string, varbinds [][]byte) ([]byte, error) {
+
+	uptimeVarbind, err := EncodeVarbind(SYS_UPTIME_OID, "t", strconv.FormatUint(uint64(uptime), 10))
+	if err != nil {
+		return nil, err
+	}
+	trapVarbind, err := EncodeVarbind(SNMP_TRAP_OID, "o", trapOID)
+	if err != nil {
+		return nil, err
+	}
+	all := [][]byte{uptimeVarbind, trapVarbind}
+	if enterprise != "" {
+		enterpriseVarbind, err := EncodeVarbind(SNMP_TRAP_ENTERPRISE_OID, "o", enterprise)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, enterpriseVarbind)
+	}
+	all = append(all, varbinds...)
+
+	pduType := TAG_TRAP_V2
+	if inform {
+		pduType = TAG_INFORM
+	}
+	pdu := encodeTLV(pduType,
+		encodeInt(TAG_INTEGER, int64(requestID)),
+		encodeInt(TAG_INTEGER, 0),
+		encodeInt(TAG_INTEGER, 0),
+		encodeTLV(TAG_SEQUENCE, all...),
+	)
+	return encodeTLV(TAG_SEQUENCE,
+		encodeInt(TAG_INTEGER, 1),
+		encodeTLV(TAG_OCTET_STRING, []byte(community)),
+		pdu,
+	), nil
+}
+
+// DecodeResponse returns the request id and error status of a v2c response, which acknowledges an inform
+func DecodeResponse(data []byte) (int64, int64, error) {
+
+	packet, err := (&reader{data: data}).expect(TAG_SEQUENCE, "message")
+	if err != nil {
+		return 0, 0, err
+	}
+	message := packet.children()
+	if _, err := message.expect(TAG_INTEGER, "version"); err != nil {
+		return 0, 0, err
+	}
+	if _, err := message.expect(TAG_OCTET_STRING, "community"); err != nil {
+		return 0, 0, err
+	}
+	pdu, err := message.expect(TAG_RESPONSE, "response")
+	if err != nil {
+		return 0, 0, err
+	}
+	fields := pdu.children()
+	requestID, err := fields.expect(TAG_INTEGER, "request id")
+	if err != nil {
+		return 0, 0, err
+	}
+	errorStatus, err := fields.expect(TAG_INTEGER, "error status")
+	if err != nil {
+		return 0, 0, err
+	}
+	return decodeInt(requestID.value), decodeInt(errorStatus.value), nil
+}
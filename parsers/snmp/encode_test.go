@@ -0,0 +1,83 @@
+package snmp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestEncodesTrap(t *testing.T) {
+
+	varbinds, err := ParseVarbinds("1.3.6.1.4.1.8072.9999.1 s Build failed: connectrix #42\n\n1.3.6.1.4.1.8072.9999.2 i -129\n" +
+		"1.3.6.1.4.1.8072.9999.3 u 4294967295\n1.3.6.1.4.1.8072.9999.4 a 10.0.0.1\n1.3.6.1.4.1.8072.9999.5 x 00:1b:ff\n" +
+		"1.3.6.1.4.1.8072.9999.6 o .1.3.6.1.4.1.8072\n1.3.6.1.4.1.8072.9999.7 n\n" + "1.3.6.1.4.1.8072.9999.8 s " + strings.Repeat("long ", 40))
+	assert.Nil(t, err)
+
+	packet, err := EncodeTrap("public", false, 77, 123456, "1.3.6.1.4.1.8072.9999.0.1", "1.3.6.1.4.1.8072.9999", varbinds)
+	assert.Nil(t, err)
+
+	trap, err := Decode(packet)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "2c", trap.Version)
+	assert.Equal(t, "public", trap.Community)
+	assert.False(t, trap.Inform)
+	assert.Equal(t, uint64(123456), trap.Uptime)
+	assert.Equal(t, "1.3.6.1.4.1.8072.9999.0.1", trap.TrapOID)
+	assert.Equal(t, []Varbind{
+		{OID: SYS_UPTIME_OID, Value: uint64(123456)},
+		{OID: SNMP_TRAP_OID, Value: "1.3.6.1.4.1.8072.9999.0.1"},
+		{OID: SNMP_TRAP_ENTERPRISE_OID, Value: "1.3.6.1.4.1.8072.9999"},
+		{OID: "1.3.6.1.4.1.8072.9999.1", Value: "Build failed: connectrix #42"},
+		{OID: "1.3.6.1.4.1.8072.9999.2", Value: int64(-129)},
+		{OID: "1.3.6.1.4.1.8072.9999.3", Value: uint64(4294967295)},
+		{OID: "1.3.6.1.4.1.8072.9999.4", Value: "10.0.0.1"},
+		{OID: "1.3.6.1.4.1.8072.9999.5", Value: "00:1b:ff"},
+		{OID: "1.3.6.1.4.1.8072.9999.6", Value: "1.3.6.1.4.1.8072"},
+		{OID: "1.3.6.1.4.1.8072.9999.7", Value: nil},
+		{OID: "1.3.6.1.4.1.8072.9999.8", Value: strings.TrimSpace(strings.Repeat("long ", 40))},
+	}, trap.Varbinds)
+}
+
+func TestEncodesInform(t *testing.T) {
+
+	packet, err := EncodeTrap("private", true, 1, 0, "1.3.6.1.6.3.1.1.5.1", "", nil)
+	assert.Nil(t, err)
+	trap, err := Decode(packet)
+	assert.Nil(t, err)
+	assert.True(t, trap.Inform)
+	assert.Len(t, trap.Varbinds, 2)
+
+	requestID, errorStatus, err := DecodeResponse(trap.Acknowledgement(packet))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), requestID)
+	assert.Equal(t, int64(0), errorStatus)
+
+	_, _, err = DecodeResponse(packet)
+	assert.NotNil(t, err)
+}
+
+func TestEncodesIntegers(t *testing.T) {
+	assert.Equal(t, []byte{TAG_INTEGER, 1, 0}, encodeInt(TAG_INTEGER, 0))
+	assert.Equal(t, []byte{TAG_INTEGER, 2, 0, 0x80}, encodeInt(TAG_INTEGER, 128))
+	assert.Equal(t, []byte{TAG_INTEGER, 1, 0x80}, encodeInt(TAG_INTEGER, -128))
+	assert.Equal(t, []byte{TAG_INTEGER, 2, 0xff, 0x7f}, encodeInt(TAG_INTEGER, -129))
+	assert.Equal(t, []byte{TAG_GAUGE32, 5, 0, 0xff, 0xff, 0xff, 0xff}, encodeUint(TAG_GAUGE32, 4294967295))
+}
+
+func TestRejectsBadVarbinds(t *testing.T) {
+	for _, text := range []string{
+		"1.3.6.1.4.1.8072.9999.1",
+		"1.3.6.1.4.1.8072.9999.1 q foo",
+		"1.3.6.1.4.1.8072.9999.1 i forty-two",
+		"1.3.6.1.4.1.8072.9999.1 u -1",
+		"1.3.6.1.4.1.8072.9999.1 a 10.0.0",
+		"1.3.6.1.4.1.8072.9999.1 x zz",
+		"1.3.6.1.4.1.8072.9999.1 o 4.1",
+		"iso.3.6 s foo",
+	} {
+		_, err := ParseVarbinds(text)
+		assert.NotNil(t, err, text)
+	}
+}
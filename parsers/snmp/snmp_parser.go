@@ -0,0 +1,333 @@
+package snmp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/events/event"
+	"strings"
+)
+
+const (
+	AGENT_HINT     string = "snmp:agent"
+	COMMUNITY_HINT string = "snmp:community"
+	TRAP_HINT      string = "snmp:trap"
+	TRAP_NAME_HINT string = "snmp:trap_name"
+
+	// NAME_CONFIG_PREFIX starts the snmp channel config keys that give OIDs friendly names, e.g.
+	// "name:1.3.6.1.4.1.2021.251.1":"ucdShutdown"
+	NAME_CONFIG_PREFIX string = "name:"
+
+	SYS_UPTIME_OID        string = "1.3.6.1.2.1.1.3.0"
+	SNMP_TRAP_OID         string = "1.3.6.1.6.3.1.1.4.1.0"
+	SNMP_TRAP_ADDRESS_OID string = "1.3.6.1.6.3.18.1.3.0"
+	// STANDARD_TRAPS_OID is the prefix of the trap OIDs of the v1 generic traps
+	STANDARD_TRAPS_OID string = "1.3.6.1.6.3.1.1.5"
+)
+
+// standardNames are the friendly names of common OIDs, OIDs below them are named after them, e.g. ifIndex.3
+var standardNames = map[string]string{
+	"1.3.6.1.2.1.1.1":         "sysDescr",
+	"1.3.6.1.2.1.1.3":         "sysUpTime",
+	"1.3.6.1.2.1.1.5":         "sysName",
+	"1.3.6.1.2.1.1.6":         "sysLocation",
+	"1.3.6.1.2.1.2.2.1.1":     "ifIndex",
+	"1.3.6.1.2.1.2.2.1.2":     "ifDescr",
+	"1.3.6.1.2.1.2.2.1.3":     "ifType",
+	"1.3.6.1.2.1.2.2.1.7":     "ifAdminStatus",
+	"1.3.6.1.2.1.2.2.1.8":     "ifOperStatus",
+	"1.3.6.1.2.1.31.1.1.1.1":  "ifName",
+	"1.3.6.1.2.1.31.1.1.1.18": "ifAlias",
+	"1.3.6.1.6.3.1.1.4.1":     "snmpTrapOID",
+	"1.3.6.1.6.3.1.1.4.3":     "snmpTrapEnterprise",
+	"1.3.6.1.6.3.18.1.3":      "snmpTrapAddress",
+	"1.3.6.1.6.3.18.1.4":      "snmpTrapCommunity",
+	STANDARD_TRAPS_OID + ".1": "coldStart",
+	STANDARD_TRAPS_OID + ".2": "warmStart",
+	STANDARD_TRAPS_OID + ".3": "linkDown",
+	STANDARD_TRAPS_OID + ".4": "linkUp",
+	STANDARD_TRAPS_OID + ".5": "authenticationFailure",
+	STANDARD_TRAPS_OID + ".6": "egpNeighborLoss",
+}
+
+// Varbind is an OID and its value
+type Varbind struct {
+	OID   string
+	Value interface{}
+}
+
+// Trap is a decoded SNMP v1 or v2c trap or inform
+type Trap struct {
+	// Version is 1 or 2c
+	Version   string
+	Community string
+	// Inform is true for v2c informs, which must be acknowledged
+	Inform bool
+	// Agent is the address of the agent that sent the trap, if the trap includes it
+	Agent string
+	// Enterprise, GenericTrap and SpecificTrap are only set for v1 traps
+	Enterprise   string
+	GenericTrap  int64
+	SpecificTrap int64
+	Uptime       uint64
+	// TrapOID identifies the trap, v1 traps are given the OID they would have in v2c
+	TrapOID  string
+	Varbinds []Varbind
+	// pduStart is the position of the PDU in the packet
+	pduStart int
+}
+
+// Decode decodes an SNMP v1 or v2c packet containing a trap or inform
+func Decode(data []byte) (*Trap, error) {
+
+	packet, err := (&reader{data: data}).expect(TAG_SEQUENCE, "message")
+	if err != nil {
+		return nil, err
+	}
+	message := packet.children()
+
+	version, err := message.expect(TAG_INTEGER, "version")
+	if err != nil {
+		return nil, err
+	}
+	trap := &Trap{}
+	switch decodeInt(version.value) {
+	case 0:
+		trap.Version = "1"
+	case 1:
+		trap.Version = "2c"
+	default:
+		return nil, errors.New(fmt.Sprintf("Unsupported SNMP version %d, only v1 and v2c are supported", decodeInt(version.value)+1))
+	}
+
+	community, err := message.expect(TAG_OCTET_STRING, "community")
+	if err != nil {
+		return nil, err
+	}
+	trap.Community = string(community.value)
+
+	pdu, err := message.next()
+	if err != nil {
+		return nil, err
+	}
+	trap.pduStart = packet.offset + pdu.start
+	switch {
+	case pdu.tag == TAG_TRAP_V1 && trap.Version == "1":
+		err = trap.decodeV1(pdu.children())
+	case (pdu.tag == TAG_TRAP_V2 || pdu.tag == TAG_INFORM) && trap.Version == "2c":
+		trap.Inform = pdu.tag == TAG_INFORM
+		err = trap.decodeV2(pdu.children())
+	default:
+		err = errors.New(fmt.Sprintf("SNMP packet isn't a trap or inform, got PDU type 0x%02x", pdu.tag))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return trap, nil
+}
+
+func (t *Trap) decodeV1(pdu *reader) error {
+
+	enterprise, err := pdu.expect(TAG_OID, "enterprise")
+	if err != nil {
+		return err
+	}
+	t.Enterprise, err = decodeOID(enterprise.value)
+	if err != nil {
+		return err
+	}
+	agent, err := pdu.expect(TAG_IP_ADDRESS, "agent address")
+	if err != nil {
+		return err
+	}
+	if address := decodeIP(agent.value); address != "0.0.0.0" {
+		t.Agent = address
+	}
+	generic, err := pdu.expect(TAG_INTEGER, "generic trap")
+	if err != nil {
+		return err
+	}
+	t.GenericTrap = decodeInt(generic.value)
+	specific, err := pdu.expect(TAG_INTEGER, "specific trap")
+	if err != nil {
+		return err
+	}
+	t.SpecificTrap = decodeInt(specific.value)
+	timestamp, err := pdu.expect(TAG_TIMETICKS, "time stamp")
+	if err != nil {
+		return err
+	}
+	t.Uptime = decodeUint(timestamp.value)
+
+	// as RFC 3584 translates v1 traps to v2c
+	if t.GenericTrap >= 0 && t.GenericTrap < 6 {
+		t.TrapOID = fmt.Sprintf("%s.%d", STANDARD_TRAPS_OID, t.GenericTrap+1)
+	} else {
+		t.TrapOID = fmt.Sprintf("%s.0.%d", t.Enterprise, t.SpecificTrap)
+	}
+
+	return t.decodeVarbinds(pdu)
+}
+
+func (t *Trap) decodeV2(pdu *reader) error {
+
+	// skip the request id, error status and error index
+	for i := 0; i < 3; i++ {
+		if _, err := pdu.expect(TAG_INTEGER, "request id"); err != nil {
+			return err
+		}
+	}
+
+	err := t.decodeVarbinds(pdu)
+	if err != nil {
+		return err
+	}
+	for _, varbind := range t.Varbinds {
+		switch varbind.OID {
+		case SYS_UPTIME_OID:
+			t.Uptime, _ = varbind.Value.(uint64)
+		case SNMP_TRAP_OID:
+			t.TrapOID, _ = varbind.Value.(string)
+		case SNMP_TRAP_ADDRESS_OID:
+			t.Agent, _ = varbind.Value.(string)
+		}
+	}
+	if t.TrapOID == "" {
+		return errors.New("SNMP trap is missing snmpTrapOID.0")
+	}
+	return nil
+}
+
+func (t *Trap) decodeVarbinds(pdu *reader) error {
+
+	list, err := pdu.expect(TAG_SEQUENCE, "variable bindings")
+	if err != nil {
+		return err
+	}
+	varbinds := list.children()
+	for varbinds.more() {
+		varbind, err := varbinds.expect(TAG_SEQUENCE, "variable binding")
+		if err != nil {
+			return err
+		}
+		fields := varbind.children()
+		name, err := fields.expect(TAG_OID, "variable name")
+		if err != nil {
+			return err
+		}
+		oid, err := decodeOID(name.value)
+		if err != nil {
+			return err
+		}
+		encoded, err := fields.next()
+		if err != nil {
+			return err
+		}
+		value, err := decodeValue(encoded)
+		if err != nil {
+			return err
+		}
+		t.Varbinds = append(t.Varbinds, Varbind{OID: oid, Value: value})
+	}
+	return nil
+}
+
+// Acknowledgement returns the response that acknowledges an inform, which is the inform packet with its PDU
+// type changed to a response
+func (t *Trap) Acknowledgement(data []byte) []byte {
+	response := append([]byte{}, data...)
+	response[t.pduStart] = TAG_RESPONSE
+	return response
+}
+
+// Hints returns snmp:agent, snmp:community, snmp:trap (the trap OID) and, if the trap OID has a name,
+// snmp:trap_name hints. The agent is the address the trap was sent from if the trap doesn't include it.
+func (t *Trap) Hints(sender string, names map[string]string) []event.Hint {
+
+	agent := t.Agent
+	if agent == "" {
+		agent = sender
+	}
+	hints := []event.Hint{
+		{Key: AGENT_HINT, Value: agent, Text: "Agent:" + agent},
+		{Key: COMMUNITY_HINT, Value: t.Community, Text: "Community:" + t.Community},
+		{Key: TRAP_HINT, Value: t.TrapOID, Text: "Trap:" + t.TrapOID},
+	}
+	if name := Name(names, t.TrapOID); name != "" {
+		hints = append(hints, event.Hint{Key: TRAP_NAME_HINT, Value: name, Text: "Trap:" + name})
+	}
+	return hints
+}
+
+// Names returns the standard OID names along with the names given in the snmp channel config, which take
+// precedence
+func Names(channelConfig map[string]string) map[string]string {
+	names := make(map[string]string, len(standardNames))
+	for oid, name := range standardNames {
+		names[oid] = name
+	}
+	for key, name := range channelConfig {
+		if strings.HasPrefix(key, NAME_CONFIG_PREFIX) {
+			names[strings.Trim(strings.TrimPrefix(key, NAME_CONFIG_PREFIX), ". ")] = name
+		}
+	}
+	return names
+}
+
+// Name returns the friendly name of the OID, which is the name of the longest named prefix of the OID followed by
+// the rest of the OID, e.g. ifIndex.3. An empty string is returned if no part of the OID is named.
+func Name(names map[string]string, oid string) string {
+	for prefix := oid; prefix != ""; {
+		if name, exists := names[prefix]; exists {
+			return name + oid[len(prefix):]
+		}
+		dot := strings.LastIndex(prefix, ".")
+		if dot < 0 {
+			break
+		}
+		prefix = prefix[:dot]
+	}
+	return ""
+}
+
+// SnmpParser parses SNMP v1 and v2c traps and informs. The varbinds are keyed by OID, and by friendly name in
+// values for the OIDs that have one.
+type SnmpParser struct {
+	Names map[string]string
+}
+
+func (p SnmpParser) ParseContent(data *[]byte) (interface{}, error) {
+
+	trap, err := Decode(*data)
+	if err != nil {
+		return nil, err
+	}
+
+	varbinds := make(map[string]interface{}, len(trap.Varbinds))
+	values := make(map[string]interface{})
+	for _, varbind := range trap.Varbinds {
+		varbinds[varbind.OID] = varbind.Value
+		if name := Name(p.Names, varbind.OID); name != "" {
+			values[name] = varbind.Value
+		}
+	}
+
+	object := map[string]interface{}{
+		"version":   trap.Version,
+		"community": trap.Community,
+		"inform":    trap.Inform,
+		"agent":     trap.Agent,
+		"uptime":    trap.Uptime,
+		"trap_oid":  trap.TrapOID,
+		"trap_name": Name(p.Names, trap.TrapOID),
+		"varbinds":  varbinds,
+		"values":    values,
+	}
+	if trap.Version == "1" {
+		object["enterprise"] = trap.Enterprise
+		object["generic_trap"] = trap.GenericTrap
+		object["specific_trap"] = trap.SpecificTrap
+	}
+
+	return object, nil
+}
@@ -0,0 +1,191 @@
+package snmp
+
+import (
+	"bytes"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// encode BER encodes a value, using the long form of the length when needed
+func encode(tag byte, value ...[]byte) []byte {
+	content := []byte{}
+	for _, v := range value {
+		content = append(content, v...)
+	}
+	if len(content) < 0x80 {
+		return append([]byte{tag, byte(len(content))}, content...)
+	}
+	return append([]byte{tag, 0x82, byte(len(content) >> 8), byte(len(content))}, content...)
+}
+
+func integer(tag byte, i int) []byte {
+	if i < 0x80 {
+		return encode(tag, []byte{byte(i)})
+	}
+	return encode(tag, []byte{byte(i >> 8), byte(i)})
+}
+
+func oid(dotted string) []byte {
+	parts := strings.Split(dotted, ".")
+	first, _ := strconv.Atoi(parts[0])
+	second, _ := strconv.Atoi(parts[1])
+	content := []byte{byte(first*40 + second)}
+	for _, part := range parts[2:] {
+		n, _ := strconv.Atoi(part)
+		sub := []byte{byte(n & 0x7f)}
+		for n >>= 7; n > 0; n >>= 7 {
+			sub = append([]byte{byte(n&0x7f | 0x80)}, sub...)
+		}
+		content = append(content, sub...)
+	}
+	return encode(TAG_OID, content)
+}
+
+func varbind(name string, value []byte) []byte {
+	return encode(TAG_SEQUENCE, oid(name), value)
+}
+
+var linkDownV2 = encode(TAG_SEQUENCE,
+	integer(TAG_INTEGER, 1),
+	encode(TAG_OCTET_STRING, []byte("public")),
+	encode(TAG_TRAP_V2,
+		integer(TAG_INTEGER, 1234),
+		integer(TAG_INTEGER, 0),
+		integer(TAG_INTEGER, 0),
+		encode(TAG_SEQUENCE,
+			varbind(SYS_UPTIME_OID, integer(TAG_TIMETICKS, 500)),
+			varbind(SNMP_TRAP_OID, oid("1.3.6.1.6.3.1.1.5.3")),
+			varbind("1.3.6.1.2.1.2.2.1.1.3", integer(TAG_INTEGER, 3)),
+			varbind("1.3.6.1.2.1.2.2.1.2.3", encode(TAG_OCTET_STRING, []byte("eth0"))),
+			varbind("1.3.6.1.4.1.8072.9999.1", encode(TAG_OCTET_STRING, []byte{0x00, 0x1b, 0xff})),
+			varbind("1.3.6.1.4.1.8072.9999.2", encode(TAG_IP_ADDRESS, []byte{10, 0, 0, 1})),
+		),
+	),
+)
+
+var enterpriseV1 = encode(TAG_SEQUENCE,
+	integer(TAG_INTEGER, 0),
+	encode(TAG_OCTET_STRING, []byte("private")),
+	encode(TAG_TRAP_V1,
+		oid("1.3.6.1.4.1.2021.251"),
+		encode(TAG_IP_ADDRESS, []byte{192, 168, 1, 20}),
+		integer(TAG_INTEGER, 6),
+		integer(TAG_INTEGER, 1),
+		integer(TAG_TIMETICKS, 300),
+		encode(TAG_SEQUENCE,
+			varbind("1.3.6.1.2.1.1.5.0", encode(TAG_OCTET_STRING, []byte(strings.Repeat("nas", 50)))),
+			varbind("1.3.6.1.4.1.2021.251.2", encode(TAG_NULL)),
+		),
+	),
+)
+
+func TestParsesV2Trap(t *testing.T) {
+
+	object, err := SnmpParser{Names: Names(nil)}.ParseContent(&linkDownV2)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"version":   "2c",
+		"community": "public",
+		"inform":    false,
+		"agent":     "",
+		"uptime":    uint64(500),
+		"trap_oid":  "1.3.6.1.6.3.1.1.5.3",
+		"trap_name": "linkDown",
+		"varbinds": map[string]interface{}{
+			SYS_UPTIME_OID:            uint64(500),
+			SNMP_TRAP_OID:             "1.3.6.1.6.3.1.1.5.3",
+			"1.3.6.1.2.1.2.2.1.1.3":   int64(3),
+			"1.3.6.1.2.1.2.2.1.2.3":   "eth0",
+			"1.3.6.1.4.1.8072.9999.1": "00:1b:ff",
+			"1.3.6.1.4.1.8072.9999.2": "10.0.0.1",
+		},
+		"values": map[string]interface{}{
+			"sysUpTime.0":   uint64(500),
+			"snmpTrapOID.0": "1.3.6.1.6.3.1.1.5.3",
+			"ifIndex.3":     int64(3),
+			"ifDescr.3":     "eth0",
+		},
+	}, object)
+}
+
+func TestParsesV1Trap(t *testing.T) {
+
+	names := Names(map[string]string{"name:.1.3.6.1.4.1.2021.251": "ucdavis", "port": "162"})
+	object, err := SnmpParser{Names: names}.ParseContent(&enterpriseV1)
+	assert.Nil(t, err)
+	trap := object.(map[string]interface{})
+	assert.Equal(t, "1", trap["version"])
+	assert.Equal(t, "private", trap["community"])
+	assert.Equal(t, "192.168.1.20", trap["agent"])
+	assert.Equal(t, "1.3.6.1.4.1.2021.251", trap["enterprise"])
+	assert.Equal(t, int64(6), trap["generic_trap"])
+	assert.Equal(t, int64(1), trap["specific_trap"])
+	assert.Equal(t, "1.3.6.1.4.1.2021.251.0.1", trap["trap_oid"])
+	assert.Equal(t, "ucdavis.0.1", trap["trap_name"])
+	assert.Equal(t, map[string]interface{}{"sysName.0": strings.Repeat("nas", 50), "ucdavis.2": nil}, trap["values"])
+}
+
+func TestDecodesV1GenericTrap(t *testing.T) {
+	packet := append([]byte{}, enterpriseV1...)
+	// change generic trap 6 (enterpriseSpecific) to 3 (linkUp), two bytes after the agent address
+	i := strings.Index(string(packet), string([]byte{192, 168, 1, 20}))
+	packet[i+6] = 3
+	trap, err := Decode(packet)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.3.6.1.6.3.1.1.5.4", trap.TrapOID)
+}
+
+func TestHints(t *testing.T) {
+
+	trap, err := Decode(linkDownV2)
+	assert.Nil(t, err)
+	assert.Equal(t, []event.Hint{
+		{Key: AGENT_HINT, Value: "10.1.1.1", Text: "Agent:10.1.1.1"},
+		{Key: COMMUNITY_HINT, Value: "public", Text: "Community:public"},
+		{Key: TRAP_HINT, Value: "1.3.6.1.6.3.1.1.5.3", Text: "Trap:1.3.6.1.6.3.1.1.5.3"},
+		{Key: TRAP_NAME_HINT, Value: "linkDown", Text: "Trap:linkDown"},
+	}, trap.Hints("10.1.1.1", Names(nil)))
+
+	trap, err = Decode(enterpriseV1)
+	assert.Nil(t, err)
+	assert.Equal(t, "192.168.1.20", trap.Hints("10.1.1.1", Names(nil))[0].Value)
+	assert.Len(t, trap.Hints("10.1.1.1", Names(nil)), 3)
+}
+
+func TestAcknowledgesInform(t *testing.T) {
+
+	inform := append([]byte{}, linkDownV2...)
+	pdu := bytes.IndexByte(inform, TAG_TRAP_V2)
+	inform[pdu] = TAG_INFORM
+	trap, err := Decode(inform)
+	assert.Nil(t, err)
+	assert.True(t, trap.Inform)
+
+	response := trap.Acknowledgement(inform)
+	assert.Equal(t, TAG_RESPONSE, response[pdu])
+	assert.Equal(t, TAG_INFORM, inform[pdu])
+	assert.Equal(t, inform[pdu+1:], response[pdu+1:])
+}
+
+func TestRejectsBadPackets(t *testing.T) {
+
+	for _, packet := range [][]byte{
+		nil,
+		linkDownV2[:len(linkDownV2)-3],
+		encode(TAG_SEQUENCE, integer(TAG_INTEGER, 3), encode(TAG_OCTET_STRING, []byte("public"))),
+		encode(TAG_SEQUENCE, integer(TAG_INTEGER, 1), encode(TAG_OCTET_STRING, []byte("public")), encode(0xa0, integer(TAG_INTEGER, 1))),
+	} {
+		_, err := Decode(packet)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestName(t *testing.T) {
+	names := Names(nil)
+	assert.Equal(t, "ifOperStatus.12", Name(names, "1.3.6.1.2.1.2.2.1.8.12"))
+	assert.Equal(t, "", Name(names, "1.3.6.1.4.1.9"))
+	assert.Equal(t, "", Name(names, "1.3.6.1.2.1.2.2.1.80"))
+}
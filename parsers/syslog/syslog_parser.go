@@ -0,0 +1,271 @@
+package syslog
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/events/event"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	FACILITY_HINT      string = "syslog:facility"
+	SEVERITY_HINT      string = "syslog:severity"
+	SEVERITY_CODE_HINT string = "syslog:severity_code"
+	HOSTNAME_HINT      string = "syslog:hostname"
+	APP_NAME_HINT      string = "syslog:app_name"
+	MSGID_HINT         string = "syslog:msgid"
+
+	// NIL_VALUE is used by RFC 5424 for fields with no value
+	NIL_VALUE string = "-"
+	// BSD_TIMESTAMP is the layout of RFC 3164 timestamps, which have no year or time zone
+	BSD_TIMESTAMP string = "Jan _2 15:04:05"
+)
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = []string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// tagPattern matches the tag starting the message of an RFC 3164 message, e.g. sshd[1234]:
+var tagPattern = regexp.MustCompile(`^([^\s\[\]:]{1,48})(?:\[([^\]]*)\])?:\s?`)
+
+// Message is a parsed RFC 5424 or RFC 3164 syslog message
+type Message struct {
+	Facility int
+	Severity int
+	// Version is 1 for RFC 5424 messages and 0 for RFC 3164 messages
+	Version int
+	// Timestamp is in RFC 3339 format in UTC, empty if the message has none. RFC 3164 timestamps are assumed to be
+	// in the local time zone and from the last year.
+	Timestamp string
+	Hostname  string
+	AppName   string
+	ProcID    string
+	MsgID     string
+	// StructuredData is the params of each structured data element, keyed by the element's id
+	StructuredData map[string]map[string]string
+	Message        string
+}
+
+// Parse parses a syslog message, which is RFC 5424 if the version follows the priority and RFC 3164 otherwise.
+// RFC 3164 is loosely defined, so anything after the priority that can't be parsed is kept as the message.
+func Parse(data []byte) (*Message, error) {
+
+	text := strings.TrimRight(string(data), "\r\n\x00")
+	if !strings.HasPrefix(text, "<") {
+		return nil, errors.New("Syslog message doesn't start with a priority")
+	}
+	end := strings.Index(text, ">")
+	if end < 2 || end > 4 {
+		return nil, errors.New("Syslog message doesn't start with a priority")
+	}
+	priority, err := strconv.Atoi(text[1:end])
+	if err != nil || priority < 0 || priority > 191 {
+		return nil, errors.New(fmt.Sprintf("Invalid syslog priority '%s'", text[1:end]))
+	}
+
+	message := &Message{Facility: priority / 8, Severity: priority % 8, StructuredData: make(map[string]map[string]string)}
+	text = text[end+1:]
+	if strings.HasPrefix(text, "1 ") {
+		err = message.parse5424(text[2:])
+	} else {
+		message.parse3164(text)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func (m *Message) parse5424(text string) error {
+
+	m.Version = 1
+	fields := strings.SplitN(text, " ", 6)
+	if len(fields) < 6 {
+		return errors.New("Syslog message is missing header fields")
+	}
+	for i := range fields[:5] {
+		if fields[i] == NIL_VALUE {
+			fields[i] = ""
+		}
+	}
+	if fields[0] != "" {
+		timestamp, err := time.Parse(time.RFC3339Nano, fields[0])
+		if err != nil {
+			return errors.New(fmt.Sprintf("Invalid syslog timestamp '%s'", fields[0]))
+		}
+		m.Timestamp = timestamp.UTC().Format(time.RFC3339)
+	}
+	m.Hostname, m.AppName, m.ProcID, m.MsgID = fields[1], fields[2], fields[3], fields[4]
+
+	rest, err := m.parseStructuredData(fields[5])
+	if err != nil {
+		return err
+	}
+	m.Message = strings.TrimPrefix(strings.TrimPrefix(rest, " "), "\xef\xbb\xbf")
+	return nil
+}
+
+// parseStructuredData parses the structured data elements at the start of the text, returning the rest of the text
+func (m *Message) parseStructuredData(text string) (string, error) {
+
+	if strings.HasPrefix(text, NIL_VALUE) {
+		return text[1:], nil
+	}
+	if !strings.HasPrefix(text, "[") {
+		return "", errors.New("Syslog message is missing structured data")
+	}
+
+	for strings.HasPrefix(text, "[") {
+		end := strings.IndexAny(text, " ]")
+		if end < 0 {
+			return "", errors.New("Unterminated syslog structured data")
+		}
+		params := make(map[string]string)
+		m.StructuredData[text[1:end]] = params
+		text = text[end:]
+
+		for strings.HasPrefix(text, " ") {
+			equals := strings.Index(text, "=\"")
+			if equals < 0 {
+				return "", errors.New("Invalid syslog structured data param")
+			}
+			name := text[1:equals]
+			value := []byte{}
+			i := equals + 2
+			for ; i < len(text) && text[i] != '"'; i++ {
+				// only ", \ and ] are escaped, a backslash before anything else is kept
+				if text[i] == '\\' && i+1 < len(text) && strings.IndexByte("\"\\]", text[i+1]) >= 0 {
+					i++
+				}
+				value = append(value, text[i])
+			}
+			if i >= len(text) {
+				return "", errors.New("Unterminated syslog structured data param")
+			}
+			params[name] = string(value)
+			text = text[i+1:]
+		}
+		if !strings.HasPrefix(text, "]") {
+			return "", errors.New("Unterminated syslog structured data")
+		}
+		text = text[1:]
+	}
+	return text, nil
+}
+
+func (m *Message) parse3164(text string) {
+
+	hasTimestamp := false
+	if len(text) >= len(BSD_TIMESTAMP) {
+		if timestamp, err := time.ParseInLocation(BSD_TIMESTAMP, text[:len(BSD_TIMESTAMP)], time.Local); err == nil {
+			now := time.Now()
+			timestamp = timestamp.AddDate(now.Year(), 0, 0)
+			// a timestamp from the end of last year received at the start of this one
+			if timestamp.After(now.AddDate(0, 0, 1)) {
+				timestamp = timestamp.AddDate(-1, 0, 0)
+			}
+			m.Timestamp = timestamp.UTC().Format(time.RFC3339)
+			text = strings.TrimPrefix(text[len(BSD_TIMESTAMP):], " ")
+			hasTimestamp = true
+		}
+	}
+	// some senders, including rsyslog, can use RFC 3339 timestamps in RFC 3164 messages
+	if !hasTimestamp {
+		field := strings.SplitN(text, " ", 2)
+		if timestamp, err := time.Parse(time.RFC3339Nano, field[0]); err == nil && len(field) == 2 {
+			m.Timestamp = timestamp.UTC().Format(time.RFC3339)
+			text = field[1]
+			hasTimestamp = true
+		}
+	}
+
+	// the hostname follows the timestamp, unless it's missing and the tag follows instead
+	if hasTimestamp {
+		field := strings.SplitN(text, " ", 2)
+		if len(field) == 2 && !tagPattern.MatchString(field[0]+" ") {
+			m.Hostname = field[0]
+			text = field[1]
+		}
+	}
+
+	if tag := tagPattern.FindStringSubmatch(text); tag != nil {
+		m.AppName = tag[1]
+		m.ProcID = tag[2]
+		text = text[len(tag[0]):]
+	}
+	m.Message = text
+}
+
+// FacilityName returns the name of the message's facility, e.g. daemon
+func (m *Message) FacilityName() string {
+	return facilityNames[m.Facility]
+}
+
+// SeverityName returns the name of the message's severity, e.g. err
+func (m *Message) SeverityName() string {
+	return severityNames[m.Severity]
+}
+
+// Hints returns syslog:facility and syslog:severity hints with their names, e.g. daemon and err, a
+// syslog:severity_code hint with the severity as a number from 0 (emerg) to 7 (debug), and syslog:hostname,
+// syslog:app_name and syslog:msgid hints. The hostname is the address the message was sent from if the message
+// doesn't include it.
+func (m *Message) Hints(sender string) []event.Hint {
+
+	hostname := m.Hostname
+	if hostname == "" {
+		hostname = sender
+	}
+	severityCode := strconv.Itoa(m.Severity)
+	return []event.Hint{
+		{Key: FACILITY_HINT, Value: m.FacilityName(), Text: "Facility:" + m.FacilityName()},
+		{Key: SEVERITY_HINT, Value: m.SeverityName(), Text: "Severity:" + m.SeverityName()},
+		{Key: SEVERITY_CODE_HINT, Value: severityCode},
+		{Key: HOSTNAME_HINT, Value: hostname, Text: "Host:" + hostname},
+		{Key: APP_NAME_HINT, Value: m.AppName, Text: "App:" + m.AppName},
+		{Key: MSGID_HINT, Value: m.MsgID},
+	}
+}
+
+// SyslogParser parses RFC 5424 and RFC 3164 syslog messages
+type SyslogParser struct {
+}
+
+func (SyslogParser) ParseContent(data *[]byte) (interface{}, error) {
+
+	message, err := Parse(*data)
+	if err != nil {
+		return nil, err
+	}
+
+	structuredData := make(map[string]interface{}, len(message.StructuredData))
+	for id, params := range message.StructuredData {
+		element := make(map[string]interface{}, len(params))
+		for name, value := range params {
+			element[name] = value
+		}
+		structuredData[id] = element
+	}
+
+	return map[string]interface{}{
+		"facility":        message.Facility,
+		"facility_name":   message.FacilityName(),
+		"severity":        message.Severity,
+		"severity_name":   message.SeverityName(),
+		"version":         message.Version,
+		"timestamp":       message.Timestamp,
+		"hostname":        message.Hostname,
+		"app_name":        message.AppName,
+		"procid":          message.ProcID,
+		"msgid":           message.MsgID,
+		"structured_data": structuredData,
+		"message":         message.Message,
+	}, nil
+}
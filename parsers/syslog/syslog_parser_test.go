@@ -0,0 +1,112 @@
+package syslog
+
+import (
+	"github.com/diggs/connectrix/events/event"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParses5424(t *testing.T) {
+
+	data := []byte(`<165>1 2026-10-11T22:14:15.003+02:00 mymachine.example.com evntslog 1234 ID47 [exampleSDID@32473 iut="3" eventSource="Application" eventID="1011"][meta note="a \"quoted\" \] value"] ` + "\xef\xbb\xbf" + "An application event log entry...\n")
+	object, err := SyslogParser{}.ParseContent(&data)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"facility":      20,
+		"facility_name": "local4",
+		"severity":      5,
+		"severity_name": "notice",
+		"version":       1,
+		"timestamp":     "2026-10-11T20:14:15Z",
+		"hostname":      "mymachine.example.com",
+		"app_name":      "evntslog",
+		"procid":        "1234",
+		"msgid":         "ID47",
+		"structured_data": map[string]interface{}{
+			"exampleSDID@32473": map[string]interface{}{"iut": "3", "eventSource": "Application", "eventID": "1011"},
+			"meta":              map[string]interface{}{"note": `a "quoted" ] value`},
+		},
+		"message": "An application event log entry...",
+	}, object)
+}
+
+func TestParses5424WithNilValues(t *testing.T) {
+
+	message, err := Parse([]byte("<34>1 - - su - - -"))
+	assert.Nil(t, err)
+	assert.Equal(t, 4, message.Facility)
+	assert.Equal(t, 2, message.Severity)
+	assert.Equal(t, "", message.Timestamp)
+	assert.Equal(t, "", message.Hostname)
+	assert.Equal(t, "su", message.AppName)
+	assert.Equal(t, "", message.ProcID)
+	assert.Equal(t, "", message.Message)
+	assert.Empty(t, message.StructuredData)
+}
+
+func TestParses3164(t *testing.T) {
+
+	message, err := Parse([]byte("<86>Oct  9 22:33:20 db1 sshd[4721]: Accepted publickey for deploy from 10.0.0.5"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, message.Version)
+	assert.Equal(t, "authpriv", message.FacilityName())
+	assert.Equal(t, "info", message.SeverityName())
+	assert.NotEqual(t, "", message.Timestamp)
+	assert.Equal(t, "db1", message.Hostname)
+	assert.Equal(t, "sshd", message.AppName)
+	assert.Equal(t, "4721", message.ProcID)
+	assert.Equal(t, "Accepted publickey for deploy from 10.0.0.5", message.Message)
+
+	// without a hostname or pid
+	message, err = Parse([]byte("<13>Oct 11 22:14:15 backup: nightly run failed"))
+	assert.Nil(t, err)
+	assert.Equal(t, "", message.Hostname)
+	assert.Equal(t, "backup", message.AppName)
+	assert.Equal(t, "", message.ProcID)
+	assert.Equal(t, "nightly run failed", message.Message)
+
+	// with an RFC 3339 timestamp
+	message, err = Parse([]byte("<11>2026-10-11T22:14:15.003Z web2 nginx: upstream timed out"))
+	assert.Nil(t, err)
+	assert.Equal(t, "2026-10-11T22:14:15Z", message.Timestamp)
+	assert.Equal(t, "web2", message.Hostname)
+	assert.Equal(t, "nginx", message.AppName)
+
+	// without a timestamp everything after the tag is the message
+	message, err = Parse([]byte("<14>just some text"))
+	assert.Nil(t, err)
+	assert.Equal(t, "", message.Timestamp)
+	assert.Equal(t, "just some text", message.Message)
+}
+
+func TestHints(t *testing.T) {
+
+	message, err := Parse([]byte("<11>1 - - cron 99 - - backup failed"))
+	assert.Nil(t, err)
+	assert.Equal(t, []event.Hint{
+		{Key: FACILITY_HINT, Value: "user", Text: "Facility:user"},
+		{Key: SEVERITY_HINT, Value: "err", Text: "Severity:err"},
+		{Key: SEVERITY_CODE_HINT, Value: "3"},
+		{Key: HOSTNAME_HINT, Value: "10.0.0.7", Text: "Host:10.0.0.7"},
+		{Key: APP_NAME_HINT, Value: "cron", Text: "App:cron"},
+		{Key: MSGID_HINT, Value: ""},
+	}, message.Hints("10.0.0.7"))
+}
+
+func TestRejectsBadMessages(t *testing.T) {
+
+	for _, data := range []string{
+		"",
+		"no priority",
+		"<192>1 - - - - - -",
+		"<abc>message",
+		"<13>1 - host app",
+		"<13>1 yesterday host app - - -",
+		"<13>1 - host app - - [unterminated",
+		"<13>1 - host app - - [id param=\"unterminated]",
+		"<13>1 - host app - - no structured data",
+	} {
+		_, err := Parse([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+}
@@ -1,20 +1,126 @@
 package xml
 
 import (
+	"bytes"
 	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 )
 
+const (
+	ATTRIBUTE_PREFIX_OPTION string = "attribute_prefix"
+	TEXT_KEY_OPTION         string = "text_key"
+
+	DEFAULT_ATTRIBUTE_PREFIX string = "@"
+	DEFAULT_TEXT_KEY         string = "#text"
+)
+
+// XmlParser parses XML into a map keyed by the name of the root element. Elements with attributes or child elements
+// become maps, with the attributes keyed by their name after the attribute prefix and any text keyed by the text
+// key, and other elements become their text. Repeated child elements become lists.
 type XmlParser struct {
+	AttributePrefix string
+	TextKey         string
 }
 
-func (XmlParser) ParseContent(data *[]byte) (interface{}, error) {
+// WithOptions returns a copy of the parser using the attribute_prefix and text_key options
+func (p XmlParser) WithOptions(options map[string]string) (XmlParser, error) {
+	for key, val := range options {
+		switch key {
+		case ATTRIBUTE_PREFIX_OPTION, TEXT_KEY_OPTION:
+			// an empty prefix or key would mix attributes and text up with child elements
+			if val == "" {
+				return p, errors.New(fmt.Sprintf("The xml parser's %s can't be empty", key))
+			}
+			if key == ATTRIBUTE_PREFIX_OPTION {
+				p.AttributePrefix = val
+			} else {
+				p.TextKey = val
+			}
+		default:
+			return p, errors.New(fmt.Sprintf("Unknown xml parser option '%s', expected %s or %s", key, ATTRIBUTE_PREFIX_OPTION, TEXT_KEY_OPTION))
+		}
+	}
+	return p, nil
+}
 
-	var object interface{}
-	data_ := *data
-	err := xml.Unmarshal(data_, &object)
-	if err != nil {
-		return nil, err
+func (p XmlParser) ParseContent(data *[]byte) (interface{}, error) {
+
+	decoder := xml.NewDecoder(bytes.NewReader(*data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil, errors.New("XML has no root element")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root, err := p.element(decoder, start)
+			if err != nil {
+				return nil, err
+			}
+			return map[string]interface{}{start.Name.Local: root}, nil
+		}
 	}
+}
 
-	return object, nil
+// element reads the element up to its end element
+func (p XmlParser) element(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+
+	object := make(map[string]interface{})
+	for _, attr := range start.Attr {
+		object[p.attributePrefix()+attr.Name.Local] = attr.Value
+	}
+
+	text := []byte{}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			child, err := p.element(decoder, t)
+			if err != nil {
+				return nil, err
+			}
+			// children are never lists themselves, so an existing list is a repeated child
+			switch existing := object[t.Name.Local].(type) {
+			case nil:
+				object[t.Name.Local] = child
+			case []interface{}:
+				object[t.Name.Local] = append(existing, child)
+			default:
+				object[t.Name.Local] = []interface{}{existing, child}
+			}
+		case xml.CharData:
+			text = append(text, t...)
+		case xml.EndElement:
+			trimmed := strings.TrimSpace(string(text))
+			if len(object) == 0 {
+				return trimmed, nil
+			}
+			if trimmed != "" {
+				object[p.textKey()] = trimmed
+			}
+			return object, nil
+		}
+	}
+}
+
+func (p XmlParser) attributePrefix() string {
+	if p.AttributePrefix == "" {
+		return DEFAULT_ATTRIBUTE_PREFIX
+	}
+	return p.AttributePrefix
+}
+
+func (p XmlParser) textKey() string {
+	if p.TextKey == "" {
+		return DEFAULT_TEXT_KEY
+	}
+	return p.TextKey
 }
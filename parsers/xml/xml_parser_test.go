@@ -0,0 +1,53 @@
+package xml
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParsesXml(t *testing.T) {
+
+	data := []byte(`<?xml version="1.0"?>
+<build id="42" status="failed">
+	<project>web</project>
+	<step name="test">npm test</step>
+	<step name="deploy"/>
+	<notes></notes>
+</build>`)
+	object, err := XmlParser{}.ParseContent(&data)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"build": map[string]interface{}{
+			"@id":     "42",
+			"@status": "failed",
+			"project": "web",
+			"step": []interface{}{
+				map[string]interface{}{"@name": "test", "#text": "npm test"},
+				map[string]interface{}{"@name": "deploy"},
+			},
+			"notes": "",
+		},
+	}, object)
+}
+
+func TestParsesXmlWithOptions(t *testing.T) {
+
+	parser, err := XmlParser{}.WithOptions(map[string]string{ATTRIBUTE_PREFIX_OPTION: "-", TEXT_KEY_OPTION: "value"})
+	assert.Nil(t, err)
+	data := []byte(`<status code="500">Internal error</status>`)
+	object, err := parser.ParseContent(&data)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"status": map[string]interface{}{"-code": "500", "value": "Internal error"}}, object)
+
+	_, err = XmlParser{}.WithOptions(map[string]string{ATTRIBUTE_PREFIX_OPTION: ""})
+	assert.NotNil(t, err)
+	_, err = XmlParser{}.WithOptions(map[string]string{"namespaces": "true"})
+	assert.NotNil(t, err)
+}
+
+func TestRejectsBadXml(t *testing.T) {
+	for _, data := range [][]byte{[]byte(""), []byte("<build><status>"), []byte("<build></status>")} {
+		_, err := XmlParser{}.ParseContent(&data)
+		assert.NotNil(t, err)
+	}
+}
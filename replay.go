@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events"
+	"github.com/diggs/connectrix/routes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// replay asks the running Connectrix daemon (via the management API) to replay stored events, so that
+// deliveries go out over the daemon's existing channel connections. Returns the exit code for the process.
+func replay(args []string) int {
+
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	ids := flags.String("ids", "", "comma separated list of event ids to replay")
+	since := flags.Duration("since", 0, "replay events received within this duration, e.g. 24h")
+	from := flags.String("from", "", "replay events received at or after this time (RFC 3339)")
+	until := flags.String("until", "", "replay events received before this time (RFC 3339)")
+	namespace := flags.String("namespace", "", "only replay events in this namespace")
+	source := flags.String("source", "", "only replay events from this source")
+	type_ := flags.String("type", "", "only replay events of this type")
+	limit := flags.Int("limit", 0, fmt.Sprintf("maximum number of events to replay (default %d)", events.DEFAULT_REPLAY_LIMIT))
+	route := flags.String("route", "", "only replay through the route with this name")
+	dryRun := flags.Bool("dry-run", false, "show what would be sent without sending it")
+	api := flags.String("api", "", "management API url (defaults to localhost on the configured management_port)")
+	token := flags.String("token", "", "management API token (defaults to the configured management_token)")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	request := &events.ReplayRequest{Route: *route, DryRun: *dryRun}
+	request.Namespace = *namespace
+	request.Source = *source
+	request.Type = *type_
+	request.Limit = *limit
+
+	if *ids != "" {
+		for _, idStr := range strings.Split(*ids, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(idStr))
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid event id: %s\n", idStr)
+				return 2
+			}
+			request.IDs = append(request.IDs, id)
+		}
+	}
+
+	if *since > 0 {
+		request.From = time.Now().Add(-*since)
+	}
+	for _, t := range []struct {
+		value  string
+		target *time.Time
+	}{{*from, &request.From}, {*until, &request.Until}} {
+		if t.value != "" {
+			parsed, err := time.Parse(time.RFC3339, t.value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid time: %v\n", err)
+				return 2
+			}
+			*t.target = parsed
+		}
+	}
+
+	if *api == "" {
+		*api = fmt.Sprintf("http://localhost:%s", config.Get().ManagementPort)
+	}
+	if *token == "" {
+		*token = config.Get().ManagementToken
+	}
+
+	results, err := postReplay(*api, *token, request)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Replay failed: %v\n", err)
+		return 1
+	}
+
+	failed := 0
+	for _, result := range results {
+		fmt.Printf("event %d -> %s: %s\n", result.EventID, result.Route, describeResult(result, request.DryRun))
+		if result.Error != "" {
+			failed++
+		}
+	}
+	fmt.Printf("%d result(s), %d failed\n", len(results), failed)
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func postReplay(api string, token string, request *events.ReplayRequest) ([]*routes.RouteResult, error) {
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", strings.TrimRight(api, "/")+"/replay", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	results := []*routes.RouteResult{}
+	err = json.Unmarshal(respBody, &results)
+	return results, err
+}
+
+func describeResult(result *routes.RouteResult, dryRun bool) string {
+	switch {
+	case result.Error != "":
+		return "error: " + result.Error
+	case !result.Matched:
+		return "skipped, rule did not match"
+	case dryRun:
+		return fmt.Sprintf("would send %q with args %v", result.Content, result.Args)
+	case result.Delivered:
+		return "delivered"
+	default:
+		return "not delivered"
+	}
+}
@@ -1,76 +1,200 @@
 package routes
 
 import (
+	"errors"
 	"fmt"
 	"github.com/diggs/connectrix/channels"
 	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/database"
 	"github.com/diggs/connectrix/events/event"
+	"github.com/diggs/connectrix/rules"
 	"github.com/diggs/connectrix/templates"
 	"github.com/diggs/glog"
 	"github.com/diggs/go-eval"
 	"sync"
+	"time"
+)
+
+const (
+	DEFAULT_MAX_ATTEMPTS  int           = 3
+	DEFAULT_RETRY_BACKOFF time.Duration = time.Second
+	MAX_RETRY_BACKOFF     time.Duration = 5 * time.Minute
 )
 
 var once sync.Once
+var routesLock sync.RWMutex
 var routesByPub map[string][]*config.Route = make(map[string][]*config.Route)
 
+func init() {
+	// rebuild the routes whenever the config changes, so changes take effect without a restart
+	config.OnChange(loadRoutes)
+}
+
+// deliveryError is returned by processEvent when the sub channel failed to drain the event on every attempt
+type deliveryError struct {
+	attempts int
+	err      error
+}
+
+func (e *deliveryError) Error() string {
+	return fmt.Sprintf("Delivery failed after %d attempt(s): %s", e.attempts, e.err.Error())
+}
+
 func makeRouteKey(namespace string, eventSource string, eventType string) string {
 	return fmt.Sprintf("ns:%s:src:%s:type:%s", namespace, eventSource, eventType)
 }
 
-func loadRoutes() {
-	routes := config.Get().Routes
+func loadRoutes(config_ *config.ConnectrixConfig) {
+	loaded := make(map[string][]*config.Route)
+	routes := config_.AllRoutes()
 	for i := range routes {
 		route := routes[i]
 		key := makeRouteKey(route.Namespace, route.EventSource, route.EventType)
-		if _, exists := routesByPub[key]; !exists {
-			routesByPub[key] = []*config.Route{route}
+		if _, exists := loaded[key]; !exists {
+			loaded[key] = []*config.Route{route}
 		} else {
-			routesByPub[key] = append(routesByPub[key], route)
+			loaded[key] = append(loaded[key], route)
 		}
 	}
+
+	routesLock.Lock()
+	defer routesLock.Unlock()
+	routesByPub = loaded
 }
 
-func processEvent(event *event.Event, route *config.Route, channel channels.SubChannel) error {
+func getRoutes(key string) ([]*config.Route, bool) {
+	once.Do(func() { loadRoutes(config.Get()) })
+	routesLock.RLock()
+	defer routesLock.RUnlock()
+	routes, exists := routesByPub[key]
+	return routes, exists
+}
+
+// FindRoute returns the route with the given name.
+func FindRoute(name string) (*config.Route, error) {
+	for _, route := range config.Get().AllRoutes() {
+		if route.Name == name {
+			return route, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("Unknown route: %s", name))
+}
+
+// maxAttempts returns the number of times delivery should be attempted for the route
+func maxAttempts(route *config.Route) int {
+	if route.MaxAttempts > 0 {
+		return route.MaxAttempts
+	}
+	return DEFAULT_MAX_ATTEMPTS
+}
+
+// retryBackoff returns how long to wait before the first retry for the route, subsequent retries double this
+func retryBackoff(route *config.Route) time.Duration {
+	if route.RetryBackoff != "" {
+		backoff, err := time.ParseDuration(route.RetryBackoff)
+		if err == nil {
+			return backoff
+		}
+		glog.Warningf("Invalid retry_backoff '%s' for route %s, using default: %v", route.RetryBackoff, route.Name, err)
+	}
+	return DEFAULT_RETRY_BACKOFF
+}
+
+// deliver drains the event to the channel, retrying with exponential backoff until it succeeds or
+// the route's max attempts are used up
+func deliver(event *event.Event, route *config.Route, channel channels.SubChannel, args map[string]string, content string) error {
+
+	attempts := maxAttempts(route)
+	backoff := retryBackoff(route)
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = channel.Drain(args, event, content)
+		if err == nil {
+			return nil
+		}
+		if attempt < attempts {
+			glog.Warningf("Attempt %d/%d to deliver event %d to '%s' failed, retrying in %v: %s", attempt, attempts, event.ID, route.Name, backoff, err.Error())
+			time.Sleep(backoff)
+			backoff *= 2
+			if backoff > MAX_RETRY_BACKOFF {
+				backoff = MAX_RETRY_BACKOFF
+			}
+		}
+	}
+
+	return &deliveryError{attempts: attempts, err: err}
+}
+
+// evalRule evaluates the route's rule against the event object. Rules in the original style are templated and then
+// evaluated as a go expression instead.
+func evalRule(event *event.Event, route *config.Route) (bool, error) {
+
+	if !rules.IsTemplated(route.Rule) {
+		return rules.Eval(rules.RouteName(route.Name), route.Rule, event.Namespace, event.Object)
+	}
+
+	tmplRule, err := templates.Template(event.Object, event.Namespace, templates.RouteName(route.Name, "rule"), route.Rule)
+	if err != nil {
+		return false, err
+	}
+	return goeval.EvalBool(tmplRule)
+}
+
+// delivery is an event that has been templated for a route and is ready to be drained
+type delivery struct {
+	content string
+	args    map[string]string
+}
+
+// prepareEvent templates the event for the route and evaluates the route's rule.
+// A nil delivery is returned if the rule failed and the event shouldn't be sent.
+func prepareEvent(event *event.Event, route *config.Route) (*delivery, error) {
 
 	// template the event, if a custom routing template is specified
 	var err error
 	content := event.Content
 	if route.Template != "" {
-		content, err = templates.Template(event.Object, route.Template)
+		content, err = templates.Template(event.Object, event.Namespace, templates.RouteName(route.Name, "template"), route.Template)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	// evaluate the routing ruile if specified
 	if route.Rule != "" {
-		tmplRule, err := templates.Template(event.Object, route.Rule)
+		rulePassed, err := evalRule(event, route)
 		if err != nil {
-			return err
-		}
-		rulePassed, err := goeval.EvalBool(tmplRule)
-		if err != nil {
-			return err
+			return nil, err
 		}
 		// the rule failed, so we shouldn't send the event
 		if !rulePassed {
-			return nil
+			return nil, nil
 		}
 	}
 
 	// template each of the routing args
 	templatedSubChannelArgs := make(map[string]string, len(route.SubChannelArgs))
 	for key, val := range route.SubChannelArgs {
-		tmplArg, err := templates.Template(event.Object, val)
+		tmplArg, err := templates.Template(event.Object, event.Namespace, templates.RouteName(route.Name, "args/"+key), val)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		templatedSubChannelArgs[key] = tmplArg
 	}
 
+	return &delivery{content: content, args: templatedSubChannelArgs}, nil
+}
+
+func processEvent(event *event.Event, route *config.Route, channel channels.SubChannel) error {
+
+	delivery_, err := prepareEvent(event, route)
+	if err != nil || delivery_ == nil {
+		return err
+	}
+
 	// send the event
-	err = channel.Drain(templatedSubChannelArgs, event, content)
+	err = deliver(event, route, channel, delivery_.args, delivery_.content)
 	if err != nil {
 		return err
 	}
@@ -80,14 +204,31 @@ func processEvent(event *event.Event, route *config.Route, channel channels.SubC
 	return nil
 }
 
+// deadLetter stores an event that couldn't be delivered so that it can be re-driven later
+func deadLetter(event *event.Event, route *config.Route, err *deliveryError) {
+	if event.ID <= 0 {
+		glog.Warningf("Unable to dead letter event '%v' for '%s' as it has not been stored", event, route.Name)
+		return
+	}
+	id, dbErr := database.InsertDeadLetter(&database.DeadLetter{
+		EventID:  event.ID,
+		Route:    route.Name,
+		Error:    err.err.Error(),
+		Attempts: err.attempts,
+	})
+	if dbErr != nil {
+		glog.Warningf("Unable to dead letter event %d for '%s': %s", event.ID, route.Name, dbErr.Error())
+		return
+	}
+	glog.Infof("Event %d for '%s' stored as dead letter %d", event.ID, route.Name, id)
+}
+
 func RouteEvent(event_ *event.Event) error {
 
-	once.Do(loadRoutes)
 	key := makeRouteKey(event_.Namespace, event_.Source, event_.Type)
 
 	glog.Debugf("Routing event based on key: %s", key)
-	if _, exists := routesByPub[key]; exists {
-		routes := routesByPub[key]
+	if routes, exists := getRoutes(key); exists {
 		glog.Debugf("Found %d route(s) for key: %s", len(routes), key)
 		for i := range routes {
 			route := routes[i]
@@ -99,6 +240,9 @@ func RouteEvent(event_ *event.Event) error {
 					err := processEvent(event, route, channel)
 					if err != nil {
 						glog.Warningf("Unable to deliver event '%v' to '%s': %s", event, route.SubChannelName, err.Error())
+						if deliveryErr, ok := err.(*deliveryError); ok {
+							deadLetter(event, route, deliveryErr)
+						}
 					}
 				}(event_, route, channel)
 			}
@@ -107,3 +251,109 @@ func RouteEvent(event_ *event.Event) error {
 
 	return nil
 }
+
+// RouteResult describes what happened when an event was replayed through a route
+type RouteResult struct {
+	EventID   int               `json:"event_id"`
+	Route     string            `json:"route"`
+	Matched   bool              `json:"matched"`
+	Delivered bool              `json:"delivered"`
+	Content   string            `json:"content,omitempty"`
+	Args      map[string]string `json:"args,omitempty"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// ReplayEvent routes the event in the same way as RouteEvent, but waits for each delivery to finish and
+// reports the result. If routeName is set only that route is used. If dryRun is set the event is templated
+// and the rules are evaluated, but nothing is delivered.
+func ReplayEvent(event_ *event.Event, routeName string, dryRun bool) []*RouteResult {
+
+	results := []*RouteResult{}
+	routes, _ := getRoutes(makeRouteKey(event_.Namespace, event_.Source, event_.Type))
+	for _, route := range routes {
+		if routeName != "" && route.Name != routeName {
+			continue
+		}
+
+		result := &RouteResult{EventID: event_.ID, Route: route.Name}
+		results = append(results, result)
+
+		delivery_, err := prepareEvent(event_, route)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+		if delivery_ == nil {
+			continue
+		}
+		result.Matched = true
+		result.Content = delivery_.content
+		result.Args = delivery_.args
+
+		if dryRun {
+			continue
+		}
+
+		channel, err := channels.GetSubChannel(route.SubChannelName)
+		if err != nil {
+			result.Error = err.Error()
+			continue
+		}
+
+		err = deliver(event_, route, channel, delivery_.args, delivery_.content)
+		if err != nil {
+			result.Error = err.Error()
+			if deliveryErr, ok := err.(*deliveryError); ok {
+				deadLetter(event_, route, deliveryErr)
+			}
+			continue
+		}
+		result.Delivered = true
+	}
+
+	return results
+}
+
+// ListDeadLetters returns the events that could not be delivered to their route.
+func ListDeadLetters() ([]*database.DeadLetter, error) {
+	return database.ListDeadLetters()
+}
+
+// RedriveDeadLetter attempts to deliver a dead letter again using the current config for its route.
+// The dead letter is removed if delivery succeeds, otherwise it is updated with the latest error.
+func RedriveDeadLetter(id int) error {
+
+	deadLetter, err := database.GetDeadLetter(id)
+	if err != nil {
+		return err
+	}
+
+	event_, err := database.GetEvent(deadLetter.EventID)
+	if err != nil {
+		return err
+	}
+
+	route, err := FindRoute(deadLetter.Route)
+	if err != nil {
+		return err
+	}
+
+	channel, err := channels.GetSubChannel(route.SubChannelName)
+	if err != nil {
+		return err
+	}
+
+	glog.Infof("Re-driving dead letter %d (event %d) to '%s'", id, event_.ID, route.Name)
+	err = processEvent(event_, route, channel)
+	if err != nil {
+		if deliveryErr, ok := err.(*deliveryError); ok {
+			updateErr := database.UpdateDeadLetter(id, deliveryErr.attempts, deliveryErr.err.Error())
+			if updateErr != nil {
+				glog.Warningf("Unable to update dead letter %d: %s", id, updateErr.Error())
+			}
+		}
+		return err
+	}
+
+	return database.DeleteDeadLetter(id)
+}
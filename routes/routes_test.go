@@ -0,0 +1,90 @@
+package routes
+
+import (
+	"errors"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/events/event"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// failingChannel fails the first `failures` calls to Drain
+type failingChannel struct {
+	failures int
+	calls    int
+}
+
+func (*failingChannel) Name() string                                      { return "failing" }
+func (*failingChannel) Description() string                               { return "" }
+func (*failingChannel) StartSubChannel(map[string]string) error           { return nil }
+func (*failingChannel) SubChannelArgs() []*channels.Arg                   { return nil }
+func (*failingChannel) ValidateSubChannelArgs(map[string]string) error    { return nil }
+func (*failingChannel) SubChannelInfo(map[string]string) []*channels.Info { return nil }
+
+func (ch *failingChannel) Drain(map[string]string, *event.Event, string) error {
+	ch.calls++
+	if ch.calls <= ch.failures {
+		return errors.New("drain failed")
+	}
+	return nil
+}
+
+func TestDeliverRetriesUntilSuccess(t *testing.T) {
+
+	channel := &failingChannel{failures: 2}
+	route := &config.Route{Name: "test", MaxAttempts: 3, RetryBackoff: "1ms"}
+
+	err := deliver(&event.Event{}, route, channel, nil, "")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, channel.calls)
+}
+
+func TestDeliverGivesUpAfterMaxAttempts(t *testing.T) {
+
+	channel := &failingChannel{failures: 5}
+	route := &config.Route{Name: "test", MaxAttempts: 2, RetryBackoff: "1ms"}
+
+	err := deliver(&event.Event{}, route, channel, nil, "")
+	assert.NotNil(t, err)
+	assert.Equal(t, 2, channel.calls)
+
+	deliveryErr, ok := err.(*deliveryError)
+	assert.True(t, ok)
+	assert.Equal(t, 2, deliveryErr.attempts)
+}
+
+func TestRetryDefaults(t *testing.T) {
+
+	route := &config.Route{}
+	assert.Equal(t, DEFAULT_MAX_ATTEMPTS, maxAttempts(route))
+	assert.Equal(t, DEFAULT_RETRY_BACKOFF, retryBackoff(route))
+
+	route = &config.Route{MaxAttempts: 7, RetryBackoff: "250ms"}
+	assert.Equal(t, 7, maxAttempts(route))
+	assert.Equal(t, 250*time.Millisecond, retryBackoff(route))
+}
+
+func TestReplayEventDryRun(t *testing.T) {
+
+	event_ := &event.Event{
+		ID:        1,
+		Namespace: "0",
+		Source:    "ConnectrixIRC",
+		Type:      "ping",
+		Object:    map[string]interface{}{"Sender": "diggs"},
+	}
+
+	results := ReplayEvent(event_, "", true)
+	assert.Len(t, results, 1)
+	assert.Equal(t, config.Get().Routes[0].Name, results[0].Route)
+	assert.True(t, results[0].Matched)
+	assert.False(t, results[0].Delivered)
+	assert.Equal(t, "@diggs pong", results[0].Content)
+	assert.Empty(t, results[0].Error)
+
+	// limiting the replay to a route that doesn't handle this event gives no results
+	results = ReplayEvent(event_, config.Get().Routes[1].Name, true)
+	assert.Empty(t, results)
+}
@@ -0,0 +1,441 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/kv"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// environment is what a rule is evaluated against: the event object and the namespace of the event
+type environment struct {
+	object    interface{}
+	namespace string
+}
+
+// node is a parsed part of a rule that evaluates to a value
+type node interface {
+	eval(env *environment) (interface{}, error)
+}
+
+type literalNode struct {
+	value interface{}
+}
+
+func (n *literalNode) eval(env *environment) (interface{}, error) {
+	return n.value, nil
+}
+
+// pathNode looks up a field of the event object. Missing fields (or fields of missing fields) evaluate to null.
+type pathNode struct {
+	keys []interface{}
+}
+
+func (n *pathNode) eval(env *environment) (interface{}, error) {
+	value := env.object
+	for _, key := range n.keys {
+		value = lookup(value, key)
+		if value == nil {
+			return nil, nil
+		}
+	}
+	return value, nil
+}
+
+func lookup(value interface{}, key interface{}) interface{} {
+
+	v := reflect.ValueOf(value)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	var found reflect.Value
+	switch v.Kind() {
+	case reflect.Map:
+		// maps decoded from yaml can have interface{} keys
+		if name, isString := key.(string); isString && (v.Type().Key().Kind() == reflect.String || v.Type().Key().Kind() == reflect.Interface) {
+			found = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+		}
+	case reflect.Struct:
+		if name, isString := key.(string); isString {
+			found = v.FieldByName(name)
+			if found.IsValid() && !found.CanInterface() {
+				return nil
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if index, isNumber := key.(float64); isNumber && index >= 0 && int(index) < v.Len() && float64(int(index)) == index {
+			found = v.Index(int(index))
+		}
+	}
+
+	if !found.IsValid() {
+		return nil
+	}
+	return found.Interface()
+}
+
+type listNode struct {
+	items []node
+}
+
+func (n *listNode) eval(env *environment) (interface{}, error) {
+	list := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		value, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = value
+	}
+	return list, nil
+}
+
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(env *environment) (interface{}, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(value), nil
+}
+
+type andNode struct {
+	left  node
+	right node
+}
+
+func (n *andNode) eval(env *environment) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil || !truthy(left) {
+		return false, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type orNode struct {
+	left  node
+	right node
+}
+
+func (n *orNode) eval(env *environment) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if truthy(left) {
+		return true, nil
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(right), nil
+}
+
+type comparisonNode struct {
+	op    string
+	left  node
+	right node
+	// regex is the compiled pattern when the right hand side of matches is a literal
+	regex *regexp.Regexp
+}
+
+func (n *comparisonNode) eval(env *environment) (interface{}, error) {
+
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return equal(left, right), nil
+	case "!=":
+		return !equal(left, right), nil
+	case "in":
+		return contains(right, left)
+	case "contains":
+		return contains(left, right)
+	case "matches":
+		return matches(left, right, n.regex)
+	}
+
+	// comparisons with a missing value are false rather than an error
+	if left == nil || right == nil {
+		return false, nil
+	}
+	order, err := compare(left, right)
+	if err != nil {
+		return nil, err
+	}
+	switch n.op {
+	case "<":
+		return order < 0, nil
+	case "<=":
+		return order <= 0, nil
+	case ">":
+		return order > 0, nil
+	default:
+		return order >= 0, nil
+	}
+}
+
+// truthy decides whether a value passes a rule: null, false, zero and empty values fail
+func truthy(value interface{}) bool {
+	if value == nil {
+		return false
+	}
+	if b, isBool := value.(bool); isBool {
+		return b
+	}
+	if number, isNumber := toNumber(value); isNumber {
+		return number != 0
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() > 0
+	case reflect.Ptr, reflect.Interface:
+		return !v.IsNil()
+	}
+	return true
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// toTime converts times, RFC 3339 strings and unix timestamps into times
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(time.RFC3339, v)
+	}
+	if number, isNumber := toNumber(value); isNumber {
+		seconds := int64(number)
+		return time.Unix(seconds, int64((number-float64(seconds))*1e9)), nil
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("Can't convert %s to a date", describe(value)))
+}
+
+func describe(value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	return fmt.Sprintf("%T %v", value, value)
+}
+
+func equal(left interface{}, right interface{}) bool {
+
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	if leftNumber, isNumber := toNumber(left); isNumber {
+		rightNumber, isNumber := toNumber(right)
+		return isNumber && leftNumber == rightNumber
+	}
+	_, leftIsTime := left.(time.Time)
+	_, rightIsTime := right.(time.Time)
+	if leftIsTime || rightIsTime {
+		order, err := compare(left, right)
+		return err == nil && order == 0
+	}
+	return reflect.DeepEqual(left, right)
+}
+
+// compare orders two numbers, strings or dates. Strings are compared as dates when the other side is a date.
+func compare(left interface{}, right interface{}) (int, error) {
+
+	_, leftIsTime := left.(time.Time)
+	_, rightIsTime := right.(time.Time)
+	if leftIsTime || rightIsTime {
+		leftTime, err := toTime(left)
+		if err != nil {
+			return 0, err
+		}
+		rightTime, err := toTime(right)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case leftTime.Before(rightTime):
+			return -1, nil
+		case leftTime.After(rightTime):
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	leftNumber, leftIsNumber := toNumber(left)
+	rightNumber, rightIsNumber := toNumber(right)
+	if leftIsNumber && rightIsNumber {
+		switch {
+		case leftNumber < rightNumber:
+			return -1, nil
+		case leftNumber > rightNumber:
+			return 1, nil
+		}
+		return 0, nil
+	}
+
+	leftString, leftIsString := left.(string)
+	rightString, rightIsString := right.(string)
+	if leftIsString && rightIsString {
+		return strings.Compare(leftString, rightString), nil
+	}
+
+	return 0, errors.New(fmt.Sprintf("Can't compare %s with %s", describe(left), describe(right)))
+}
+
+// contains reports whether the list contains the item, the string contains the substring or the map contains the key
+func contains(container interface{}, item interface{}) (bool, error) {
+
+	if container == nil {
+		return false, nil
+	}
+	if str, isString := container.(string); isString {
+		substr, isString := item.(string)
+		return isString && strings.Contains(str, substr), nil
+	}
+
+	v := reflect.ValueOf(container)
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if equal(v.Index(i).Interface(), item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case reflect.Map:
+		return lookup(container, item) != nil, nil
+	}
+
+	return false, errors.New(fmt.Sprintf("Can't look for a value in %s", describe(container)))
+}
+
+func matches(value interface{}, pattern interface{}, regex *regexp.Regexp) (bool, error) {
+
+	if value == nil {
+		return false, nil
+	}
+	str, isString := value.(string)
+	if !isString {
+		return false, errors.New(fmt.Sprintf("matches expects a string, got %s", describe(value)))
+	}
+	if regex == nil {
+		patternStr, isString := pattern.(string)
+		if !isString {
+			return false, errors.New(fmt.Sprintf("matches expects a regular expression string, got %s", describe(pattern)))
+		}
+		var err error
+		regex, err = regexp.Compile(patternStr)
+		if err != nil {
+			return false, err
+		}
+	}
+	return regex.MatchString(str), nil
+}
+
+// function is a function that can be called from a rule
+type function struct {
+	args int
+	call func(env *environment, args []interface{}) (interface{}, error)
+}
+
+var functions = map[string]function{
+	"len": {1, func(env *environment, args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return float64(0), nil
+		}
+		v := reflect.ValueOf(args[0])
+		switch v.Kind() {
+		case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+			return float64(v.Len()), nil
+		}
+		return nil, errors.New(fmt.Sprintf("len expects a string, list or map, got %s", describe(args[0])))
+	}},
+	"lower": {1, func(env *environment, args []interface{}) (interface{}, error) {
+		str, _ := args[0].(string)
+		return strings.ToLower(str), nil
+	}},
+	"upper": {1, func(env *environment, args []interface{}) (interface{}, error) {
+		str, _ := args[0].(string)
+		return strings.ToUpper(str), nil
+	}},
+	"date": {1, func(env *environment, args []interface{}) (interface{}, error) {
+		if args[0] == nil {
+			return nil, nil
+		}
+		return toTime(args[0])
+	}},
+	"now": {0, func(env *environment, args []interface{}) (interface{}, error) {
+		return time.Now(), nil
+	}},
+	"ago": {1, func(env *environment, args []interface{}) (interface{}, error) {
+		str, _ := args[0].(string)
+		duration, err := time.ParseDuration(str)
+		if err != nil {
+			return nil, err
+		}
+		return time.Now().Add(-duration), nil
+	}},
+	"kv": {1, func(env *environment, args []interface{}) (interface{}, error) {
+		key, isString := args[0].(string)
+		if !isString {
+			return nil, errors.New(fmt.Sprintf("kv expects a key, got %s", describe(args[0])))
+		}
+		value, err := kv.Get(env.namespace, key)
+		if err != nil || value == nil {
+			return nil, err
+		}
+		return value.Value, nil
+	}},
+}
+
+type callNode struct {
+	name     string
+	function function
+	args     []node
+}
+
+func (n *callNode) eval(env *environment) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return n.function.call(env, args)
+}
@@ -0,0 +1,410 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	value interface{}
+	pos   int
+}
+
+// operators are matched longest first
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "<", ">", "!", "(", ")", "[", "]", ",", "."}
+
+func syntaxError(pos int, format string, args ...interface{}) error {
+	return errors.New(fmt.Sprintf("Syntax error at position %d: %s", pos+1, fmt.Sprintf(format, args...)))
+}
+
+// lex reads the rule as runes, so field names can be in any language and positions count characters rather than bytes
+func lex(rule string) ([]token, error) {
+
+	runes := []rune(rule)
+	tokens := []token{}
+	pos := 0
+	for pos < len(runes) {
+		c := runes[pos]
+		switch {
+		case unicode.IsSpace(c):
+			pos++
+		case c == '"' || c == '\'' || c == '`':
+			end := pos + 1
+			for end < len(runes) && runes[end] != c {
+				if runes[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			if end >= len(runes) {
+				return nil, syntaxError(pos, "unterminated string")
+			}
+			text := string(runes[pos : end+1])
+			if c == '\'' {
+				// single quoted strings are unquoted as if they were double quoted
+				text = `"` + strings.Replace(strings.Replace(text[1:len(text)-1], `\'`, `'`, -1), `"`, `\"`, -1) + `"`
+			}
+			value, err := strconv.Unquote(text)
+			if err != nil {
+				return nil, syntaxError(pos, "invalid string %s", string(runes[pos:end+1]))
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[pos : end+1]), value: value, pos: pos})
+			pos = end + 1
+		case unicode.IsDigit(c) || (c == '-' && pos+1 < len(runes) && unicode.IsDigit(runes[pos+1])):
+			end := pos + 1
+			for end < len(runes) && (unicode.IsDigit(runes[end]) || runes[end] == '.' || runes[end] == 'e' || runes[end] == 'E') {
+				end++
+			}
+			text := string(runes[pos:end])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, syntaxError(pos, "invalid number %s", text)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, value: value, pos: pos})
+			pos = end
+		case unicode.IsLetter(c) || c == '_':
+			end := pos + 1
+			for end < len(runes) && (unicode.IsLetter(runes[end]) || unicode.IsDigit(runes[end]) || runes[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[pos:end]), pos: pos})
+			pos = end
+		default:
+			matched := false
+			for _, op := range operators {
+				// operators are ascii so their length in bytes is their length in runes
+				if pos+len(op) <= len(runes) && string(runes[pos:pos+len(op)]) == op {
+					tokens = append(tokens, token{kind: tokenOperator, text: op, pos: pos})
+					pos += len(op)
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, syntaxError(pos, "unexpected character %q", c)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// parser is a recursive descent parser for rules:
+//
+//	or         = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | comparison
+//	comparison = operand [ ( "==" | "!=" | "<" | "<=" | ">" | ">=" | "in" | "contains" | "matches" ) operand ]
+//	operand    = literal | path | call | list | "(" or ")"
+//	path       = ( [ "." ] name | "." index ) { "." name | index }
+//	name       = ident | string
+//	index      = "[" ( string | number ) "]"
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(text string) bool {
+	t := p.peek()
+	if (t.kind == tokenOperator || t.kind == tokenIdent) && t.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return p.unexpected(fmt.Sprintf("expected '%s'", text))
+	}
+	return nil
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	if t.kind == tokenEOF {
+		return syntaxError(t.pos, "unexpected end of rule, %s", expected)
+	}
+	return syntaxError(t.pos, "unexpected '%s', %s", t.text, expected)
+}
+
+func parse(rule string) (node, error) {
+
+	tokens, err := lex(rule)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	if p.peek().kind == tokenEOF {
+		return nil, errors.New("Rule is empty")
+	}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokenEOF {
+		return nil, p.unexpected("expected '&&' or '||'")
+	}
+	return n, nil
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.accept("!") {
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+var comparisonOperators = []string{"==", "!=", "<=", ">=", "<", ">", "in", "contains", "matches"}
+
+func (p *parser) parseComparison() (node, error) {
+
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range comparisonOperators {
+		t := p.peek()
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		comparison := &comparisonNode{op: op, left: left, right: right}
+		if op == "matches" {
+			// compile literal patterns up front so that a bad pattern is reported when the rule is loaded
+			if lit, ok := right.(*literalNode); ok {
+				pattern, isString := lit.value.(string)
+				if !isString {
+					return nil, syntaxError(t.pos, "matches expects a regular expression string")
+				}
+				comparison.regex, err = regexp.Compile(pattern)
+				if err != nil {
+					return nil, syntaxError(t.pos, "invalid regular expression: %v", err)
+				}
+			}
+		}
+		return comparison, nil
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseOperand() (node, error) {
+
+	t := p.peek()
+	switch t.kind {
+	case tokenNumber, tokenString:
+		p.next()
+		return &literalNode{value: t.value}, nil
+	case tokenIdent:
+		switch t.text {
+		case "true":
+			p.next()
+			return &literalNode{value: true}, nil
+		case "false":
+			p.next()
+			return &literalNode{value: false}, nil
+		case "null", "nil":
+			p.next()
+			return &literalNode{value: nil}, nil
+		}
+		if p.tokens[p.pos+1].text == "(" {
+			return p.parseCall()
+		}
+		return p.parsePath()
+	case tokenOperator:
+		switch t.text {
+		case ".":
+			return p.parsePath()
+		case "(":
+			p.next()
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			return p.parseList()
+		}
+	}
+
+	return nil, p.unexpected("expected a value")
+}
+
+func (p *parser) parsePath() (node, error) {
+
+	path := &pathNode{}
+	if p.accept(".") && p.accept("[") {
+		// a leading bracket lets a path start with a field named like a keyword, e.g. .["true"]
+		err := p.parseIndex(path)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		err := p.parseName(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		switch {
+		case p.accept("."):
+			err := p.parseName(path)
+			if err != nil {
+				return nil, err
+			}
+		case p.accept("["):
+			err := p.parseIndex(path)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return path, nil
+		}
+	}
+}
+
+// parseName reads a field name, which can be quoted when it isn't a valid identifier, e.g. payload."in"
+func (p *parser) parseName(path *pathNode) error {
+	switch p.peek().kind {
+	case tokenIdent:
+		path.keys = append(path.keys, p.next().text)
+	case tokenString:
+		path.keys = append(path.keys, p.next().value)
+	default:
+		return p.unexpected("expected a field name")
+	}
+	return nil
+}
+
+func (p *parser) parseIndex(path *pathNode) error {
+	if kind := p.peek().kind; kind != tokenString && kind != tokenNumber {
+		return p.unexpected("expected a field name or index")
+	}
+	path.keys = append(path.keys, p.next().value)
+	return p.expect("]")
+}
+
+func (p *parser) parseList() (node, error) {
+
+	p.expect("[")
+	list := &listNode{}
+	if p.accept("]") {
+		return list, nil
+	}
+	for {
+		item, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+		if p.accept("]") {
+			return list, nil
+		}
+		err = p.expect(",")
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parseCall() (node, error) {
+
+	name := p.next()
+	function, exists := functions[name.text]
+	if !exists {
+		return nil, syntaxError(name.pos, "unknown function '%s'", name.text)
+	}
+	p.expect("(")
+
+	call := &callNode{name: name.text, function: function}
+	if !p.accept(")") {
+		for {
+			arg, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, arg)
+			if p.accept(")") {
+				break
+			}
+			err = p.expect(",")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if len(call.args) != function.args {
+		return nil, syntaxError(name.pos, "%s expects %d argument(s), got %d", name.text, function.args, len(call.args))
+	}
+	return call, nil
+}
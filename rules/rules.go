@@ -0,0 +1,129 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/glog"
+	"strings"
+	"sync"
+)
+
+// Rule is a compiled rule expression, e.g. payload.status == "failed" && payload.branch in ["master", "release"]
+type Rule struct {
+	text string
+	root node
+}
+
+// Compile parses a rule expression. Syntax errors include the position of the problem.
+func Compile(rule string) (*Rule, error) {
+	root, err := parse(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &Rule{text: rule, root: root}, nil
+}
+
+// Eval evaluates the rule against an event object in the default namespace.
+func (r *Rule) Eval(object interface{}) (bool, error) {
+	return r.EvalInNamespace(config.DEFAULT_NAMESPACE, object)
+}
+
+// EvalInNamespace evaluates the rule against an event object of the namespace, which decides the key/value store
+// the kv function reads from.
+func (r *Rule) EvalInNamespace(namespace string, object interface{}) (bool, error) {
+	value, err := r.root.eval(&environment{object: object, namespace: namespace})
+	if err != nil {
+		return false, err
+	}
+	return truthy(value), nil
+}
+
+func (r *Rule) String() string {
+	return r.text
+}
+
+// IsTemplated returns true for rules written in the original style, which are templated and then evaluated
+// as a go expression, e.g. `{{.payload.status}}` == `failed`
+func IsTemplated(rule string) bool {
+	return strings.Contains(rule, "{{")
+}
+
+var cacheLock sync.RWMutex
+var cache map[string]*Rule = make(map[string]*Rule)
+
+func init() {
+	// recompile the configured rules whenever the config changes, dropping any that are no longer used
+	config.OnChange(CompileConfig)
+}
+
+// RouteName returns the name the rule of a route is cached under
+func RouteName(route string) string {
+	return fmt.Sprintf("routes/%s/rule", route)
+}
+
+// EventTypeName returns the name the rule of an event type of the namespace is cached under
+func EventTypeName(namespace string, source string, eventType string) string {
+	if namespace == "" || namespace == config.DEFAULT_NAMESPACE {
+		return fmt.Sprintf("sources/%s/%s/rule", source, eventType)
+	}
+	return fmt.Sprintf("namespaces/%s/sources/%s/%s/rule", namespace, source, eventType)
+}
+
+// CompileConfig compiles every rule in the config, replacing the rules compiled for the previous config.
+func CompileConfig(config_ *config.ConnectrixConfig) {
+
+	texts := make(map[string]string)
+	for _, namespace := range config_.AllNamespaces() {
+		for _, source := range namespace.Sources {
+			for _, eventType := range source.Events {
+				texts[EventTypeName(namespace.Name, source.Name, eventType.Type)] = eventType.Rule
+			}
+		}
+	}
+	for _, route := range config_.AllRoutes() {
+		if !IsTemplated(route.Rule) {
+			texts[RouteName(route.Name)] = route.Rule
+		}
+	}
+
+	compiledRules := make(map[string]*Rule)
+	for name, text := range texts {
+		if text == "" {
+			continue
+		}
+		rule, err := Compile(text)
+		if err != nil {
+			// leave it out, Eval will report the error when the rule is used
+			glog.Warningf("Unable to compile rule %s: %v", name, err)
+			continue
+		}
+		compiledRules[name] = rule
+	}
+
+	cacheLock.Lock()
+	cache = compiledRules
+	cacheLock.Unlock()
+}
+
+// Eval evaluates the named rule against an event object of the namespace. The rule is compiled the first time it is
+// used and reused after that for as long as its text stays the same.
+func Eval(name string, rule string, namespace string, object interface{}) (bool, error) {
+
+	cacheLock.RLock()
+	compiled, exists := cache[name]
+	cacheLock.RUnlock()
+
+	if !exists || compiled.text != rule {
+		var err error
+		compiled, err = Compile(rule)
+		if err != nil {
+			return false, errors.New(fmt.Sprintf("%s: %v", name, err))
+		}
+		cacheLock.Lock()
+		cache[name] = compiled
+		cacheLock.Unlock()
+	}
+
+	return compiled.EvalInNamespace(namespace, object)
+}
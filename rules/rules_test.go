@@ -0,0 +1,168 @@
+package rules
+
+import (
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/kv"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var testObject = map[string]interface{}{
+	"ref":        "refs/heads/master",
+	"size":       float64(3),
+	"forced":     false,
+	"created_at": "2015-07-25T12:00:00Z",
+	"labels":     []interface{}{"bug", "ci"},
+	"payload": map[string]interface{}{
+		"status":    "failed",
+		"build_num": float64(42),
+		"message":   "Merge `feature` into master",
+	},
+	"commits": []interface{}{
+		map[string]interface{}{"id": "abc", "author": map[string]interface{}{"name": "diggs"}},
+	},
+	"headers": map[string]interface{}{"X-GitHub-Event": "push"},
+	"größe":   float64(3),
+	"true":    "yes",
+	"in":      map[string]interface{}{"contains": "x"},
+}
+
+func TestRules(t *testing.T) {
+
+	tests := map[string]bool{
+		`payload.status == "failed"`:                         true,
+		`.payload.status == 'failed'`:                        true,
+		`payload.status != "failed"`:                         false,
+		`payload.build_num == 42`:                            true,
+		`payload.build_num > 9`:                              true,
+		`payload.build_num >= 42 && payload.build_num < 100`: true,
+		`size <= 2 || forced`:                                false,
+		`!forced`:                                            true,
+		`!(size == 3)`:                                       false,
+		`payload.message contains "` + "`feature`" + `"`:     true,
+		`"bug" in labels`:                                    true,
+		`"docs" in labels`:                                   false,
+		`labels contains "ci"`:                               true,
+		`payload.status in ["failed", "timedout"]`:           true,
+		`"status" in payload`:                                true,
+		`ref matches "^refs/heads/(master|release)$"`:        true,
+		`commits[0].author.name == "diggs"`:                  true,
+		`commits[1].author.name == "diggs"`:                  false,
+		`headers["X-GitHub-Event"] == "push"`:                true,
+		`missing.field == null`:                              true,
+		`missing.field > 3`:                                  false,
+		`missing.field matches "x"`:                          false,
+		`payload.build_num`:                                  true,
+		`missing`:                                            false,
+		`len(commits) == 1 && lower("CI") in labels`:         true,
+		`date(created_at) > date("2015-07-25T11:00:00Z")`:    true,
+		`date(created_at) > ago("1h")`:                       false,
+		`date(created_at) < now()`:                           true,
+		`größe == 3`:                                         true,
+		`.["true"] == "yes"`:                                 true,
+		`."true" == "yes"`:                                   true,
+		`in.contains == "x"`:                                 true,
+		`.["in"]."contains" == "x"`:                          true,
+	}
+
+	for text, expected := range tests {
+		rule, err := Compile(text)
+		if assert.Nil(t, err, text) {
+			result, err := rule.Eval(testObject)
+			assert.Nil(t, err, text)
+			assert.Equal(t, expected, result, text)
+		}
+	}
+}
+
+func TestRulesOnStructs(t *testing.T) {
+
+	type message struct {
+		Sender string
+		Args   map[string]string
+	}
+
+	rule, err := Compile(`Sender == "diggs" && Args["0"] == "ping"`)
+	assert.Nil(t, err)
+	result, err := rule.Eval(&message{Sender: "diggs", Args: map[string]string{"0": "ping"}})
+	assert.Nil(t, err)
+	assert.True(t, result)
+}
+
+func TestSyntaxErrors(t *testing.T) {
+
+	tests := map[string]string{
+		``:                          "Rule is empty",
+		`payload.status ==`:         "position 18: unexpected end of rule",
+		`payload.status = "failed"`: "position 16: unexpected character",
+		`payload.status == "failed`: "position 19: unterminated string",
+		`ref matches "("`:           "position 5: invalid regular expression",
+		`size == 3 size`:            "position 11: unexpected 'size'",
+		`unknown(ref)`:              "position 1: unknown function 'unknown'",
+		`len()`:                     "len expects 1 argument(s), got 0",
+		`payload.`:                  "expected a field name",
+		`größe = 3`:                 "position 7: unexpected character",
+		`.[true] == "yes"`:          "expected a field name or index",
+	}
+
+	for text, expected := range tests {
+		_, err := Compile(text)
+		if assert.NotNil(t, err, text) {
+			assert.Contains(t, err.Error(), expected, text)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+
+	// kv fails as there is no database to read from
+	for _, text := range []string{`ref > 3`, `size matches "3"`, `date(ref) < now()`, `kv("last_status") == "failed"`} {
+		rule, err := Compile(text)
+		assert.Nil(t, err, text)
+		_, err = rule.Eval(testObject)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestKvReadsTheEventNamespace(t *testing.T) {
+
+	defer kv.UseStore(kv.UseStore(kv.NewMemoryStore()))
+	assert.Nil(t, kv.Set("team-a", "last_status", "failed", 0))
+	assert.Nil(t, kv.Set("team-b", "last_status", "passed", 0))
+
+	rule, err := Compile(`kv("last_status") == "failed"`)
+	assert.Nil(t, err)
+
+	result, err := rule.EvalInNamespace("team-a", testObject)
+	assert.Nil(t, err)
+	assert.True(t, result)
+	result, err = rule.EvalInNamespace("team-b", testObject)
+	assert.Nil(t, err)
+	assert.False(t, result)
+
+	// missing values are null
+	rule, err = Compile(`kv("last_status") == null`)
+	assert.Nil(t, err)
+	result, err = rule.Eval(testObject)
+	assert.Nil(t, err)
+	assert.True(t, result)
+}
+
+func TestEvalRecompilesWhenTextChanges(t *testing.T) {
+
+	result, err := Eval("test", `size == 3`, config.DEFAULT_NAMESPACE, testObject)
+	assert.Nil(t, err)
+	assert.True(t, result)
+
+	result, err = Eval("test", `size == 4`, config.DEFAULT_NAMESPACE, testObject)
+	assert.Nil(t, err)
+	assert.False(t, result)
+
+	_, err = Eval("test", `size ==`, config.DEFAULT_NAMESPACE, testObject)
+	assert.Contains(t, err.Error(), "test: Syntax error")
+}
+
+func TestIsTemplated(t *testing.T) {
+	assert.True(t, IsTemplated("`{{.payload.status}}` == `failed`"))
+	assert.False(t, IsTemplated(`payload.status == "failed"`))
+}
@@ -0,0 +1,215 @@
+package templates
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/kv"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	template_ "text/template"
+	"time"
+	"unicode/utf8"
+)
+
+// timeLayouts are the layout names that can be given to parseTime and formatTime instead of a Go layout
+var timeLayouts = map[string]string{
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC822":      time.RFC822,
+	"Kitchen":     time.Kitchen,
+	"DateTime":    "2006-01-02 15:04:05",
+	"Date":        "2006-01-02",
+}
+
+// funcs are available to every template. Functions that take a value as their last argument can be used in pipelines,
+// e.g. {{.head_commit.message | truncate 50}}
+var funcs = template_.FuncMap{
+	"json":         toJson,
+	"default":      defaultValue,
+	"upper":        func(value interface{}) string { return strings.ToUpper(toString(value)) },
+	"lower":        func(value interface{}) string { return strings.ToLower(toString(value)) },
+	"truncate":     truncate,
+	"replace":      replace,
+	"regexReplace": regexReplace,
+	"base64":       func(value interface{}) string { return base64.StdEncoding.EncodeToString([]byte(toString(value))) },
+	"sha256":       sha256Hex,
+	"join":         join,
+	"now":          time.Now,
+	"parseTime":    parseTime,
+	"formatTime":   formatTime,
+	"credential":   credentialFunc(nil),
+	"kv":           kvFunc(config.DEFAULT_NAMESPACE),
+}
+
+// kvFunc returns the kv func for a namespace, which reads a value from its key/value store, e.g. {{kv "last_deploy"}}.
+// Missing values are empty.
+func kvFunc(namespace string) func(key string) (string, error) {
+	return func(key string) (string, error) {
+		value, err := kv.Get(namespace, key)
+		if err != nil || value == nil {
+			return "", err
+		}
+		return value.Value, nil
+	}
+}
+
+// credentialFunc returns the credential func for a namespace, which looks up one of its credentials by name,
+// e.g. {{credential "jira_token"}}
+func credentialFunc(credentials map[string]string) func(name string) (string, error) {
+	return func(name string) (string, error) {
+		credential, exists := credentials[name]
+		if !exists {
+			return "", errors.New(fmt.Sprintf("Unknown credential: %s", name))
+		}
+		return credential, nil
+	}
+}
+
+// toString converts a value decoded from an event into the string a template would print for it
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case float64:
+		// json numbers are decoded as floats, print them without an exponent
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// toJson serializes the value as json, e.g. {{json .commits}}
+func toJson(value interface{}) (string, error) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func isEmpty(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// defaultValue returns def if value is missing or empty, e.g. {{.pusher.email | default "unknown"}}
+func defaultValue(def interface{}, value interface{}) interface{} {
+	if isEmpty(value) {
+		return def
+	}
+	return value
+}
+
+// truncate shortens the value to at most length characters, e.g. {{.message | truncate 50}}
+func truncate(length int, value interface{}) string {
+	str := toString(value)
+	if length < 0 || utf8.RuneCountInString(str) <= length {
+		return str
+	}
+	return string([]rune(str)[:length])
+}
+
+// replace replaces every occurrence of old with new, e.g. {{.ref | replace "refs/heads/" ""}}
+func replace(old string, new string, value interface{}) string {
+	return strings.Replace(toString(value), old, new, -1)
+}
+
+// regexReplace replaces every match of the regular expression, e.g. {{.ref | regexReplace "^refs/(heads|tags)/" ""}}
+func regexReplace(pattern string, replacement string, value interface{}) (string, error) {
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", err
+	}
+	return regex.ReplaceAllString(toString(value), replacement), nil
+}
+
+func sha256Hex(value interface{}) string {
+	sum := sha256.Sum256([]byte(toString(value)))
+	return hex.EncodeToString(sum[:])
+}
+
+// join joins the items of a list, e.g. {{join ", " .labels}}
+func join(separator string, value interface{}) (string, error) {
+	if value == nil {
+		return "", nil
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return "", errors.New(fmt.Sprintf("join expects a list, got %T", value))
+	}
+	items := make([]string, v.Len())
+	for i := range items {
+		items[i] = toString(v.Index(i).Interface())
+	}
+	return strings.Join(items, separator), nil
+}
+
+func layout(name string) string {
+	if layout, exists := timeLayouts[name]; exists {
+		return layout
+	}
+	return name
+}
+
+// parseTime converts a timestamp into a time. Strings are parsed as RFC 3339 (which is what GitHub and CircleCI
+// send) unless a layout is given first, and numbers are treated as unix timestamps, e.g. {{parseTime .pushed_at}}
+// or {{parseTime "2006-01-02" .date}}
+func parseTime(args ...interface{}) (time.Time, error) {
+
+	if len(args) == 0 || len(args) > 2 {
+		return time.Time{}, errors.New("parseTime expects a value, optionally preceded by a layout")
+	}
+	value := args[len(args)-1]
+	layout_ := time.RFC3339
+	if len(args) == 2 {
+		layout_ = layout(toString(args[0]))
+	}
+
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case float64:
+		seconds := int64(v)
+		return time.Unix(seconds, int64((v-float64(seconds))*1e9)).UTC(), nil
+	case int:
+		return time.Unix(int64(v), 0).UTC(), nil
+	case int64:
+		return time.Unix(v, 0).UTC(), nil
+	case string:
+		// RFC 3339 parsing accepts fractional seconds, which CircleCI includes
+		return time.Parse(layout_, v)
+	}
+	return time.Time{}, errors.New(fmt.Sprintf("parseTime can't parse a %T", value))
+}
+
+// formatTime formats a time (or any timestamp parseTime understands) using a Go layout or one of the layout names,
+// e.g. {{.head_commit.timestamp | formatTime "Kitchen"}}
+func formatTime(layout_ string, value interface{}) (string, error) {
+	t, err := parseTime(value)
+	if err != nil {
+		return "", err
+	}
+	return t.Format(layout(layout_)), nil
+}
@@ -0,0 +1,59 @@
+package templates
+
+import (
+	"github.com/diggs/connectrix/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var funcsTestData = map[string]interface{}{
+	"ref":       "refs/heads/master",
+	"message":   "Fix the build\n\nIt was broken",
+	"labels":    []interface{}{"bug", "ci"},
+	"size":      float64(1500000),
+	"pushed_at": float64(1437825600),
+	"timestamp": "2015-07-25T12:00:00.123Z",
+	"commit":    map[string]interface{}{"id": "abc"},
+}
+
+func TestFuncs(t *testing.T) {
+
+	tests := map[string]string{
+		`{{json .commit}}`:                                    `{"id":"abc"}`,
+		`{{.email | default "unknown"}}`:                      "unknown",
+		`{{.ref | default "unknown"}}`:                        "refs/heads/master",
+		`{{.ref | upper}}`:                                    "REFS/HEADS/MASTER",
+		`{{"CI" | lower}}`:                                    "ci",
+		`{{.message | truncate 13}}`:                          "Fix the build",
+		`{{.ref | replace "refs/heads/" ""}}`:                 "master",
+		`{{.ref | regexReplace "^refs/(heads|tags)/" "$1:"}}`: "heads:master",
+		`{{"hello" | base64}}`:                                "aGVsbG8=",
+		`{{"hello" | sha256}}`:                                "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		`{{.ref | urlquery}}`:                                 "refs%2Fheads%2Fmaster",
+		`{{join ", " .labels}}`:                               "bug, ci",
+		`{{.size | upper}}`:                                   "1500000",
+		`{{.pushed_at | formatTime "RFC3339"}}`:               "2015-07-25T12:00:00Z",
+		`{{.timestamp | formatTime "Kitchen"}}`:               "12:00PM",
+		`{{(parseTime "2006-01-02" "2015-07-25").Year}}`:      "2015",
+	}
+
+	for text, expected := range tests {
+		data, err := Template(funcsTestData, config.DEFAULT_NAMESPACE, "test/funcs", text)
+		assert.Nil(t, err, text)
+		assert.Equal(t, expected, data, text)
+	}
+}
+
+func TestFuncErrors(t *testing.T) {
+
+	for _, text := range []string{
+		`{{join ", " .ref}}`,
+		`{{.ref | regexReplace "(" ""}}`,
+		`{{.ref | formatTime "Kitchen"}}`,
+		// there is no database to read from
+		`{{kv "last_build_status"}}`,
+	} {
+		_, err := Template(funcsTestData, config.DEFAULT_NAMESPACE, "test/funcs", text)
+		assert.NotNil(t, err, text)
+	}
+}
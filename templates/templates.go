@@ -2,16 +2,149 @@ package templates
 
 import (
 	"bytes"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/rules"
 	"github.com/diggs/glog"
+	"sync"
 	template_ "text/template"
 )
 
-func Template(data interface{}, template string) (string, error) {
+// compiled is a compiled template along with the text it was compiled from
+type compiled struct {
+	text string
+	tmpl *template_.Template
+	// bound is a clone of the template for each namespace it has been used for, with the credential and kv funcs
+	// bound to the namespace. The clones are dropped along with the template when the config changes.
+	lock  sync.Mutex
+	bound map[string]*template_.Template
+}
+
+// forNamespace returns the template with the credential and kv funcs bound to the namespace
+func (c *compiled) forNamespace(namespace string) (*template_.Template, error) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if tmpl, exists := c.bound[namespace]; exists {
+		return tmpl, nil
+	}
 
-	// TODO
-	//  Keep compiled templates in memory
-	//  Name templates appropriately (helps with error reporting)
-	tmpl, err := template_.New("temp").Parse(template)
+	credentials := map[string]string{}
+	if ns := config.Get().Namespace(namespace); ns != nil {
+		credentials = ns.Credentials
+	}
+	tmpl, err := c.tmpl.Clone()
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Funcs(template_.FuncMap{"credential": credentialFunc(credentials), "kv": kvFunc(namespace)})
+
+	if c.bound == nil {
+		c.bound = make(map[string]*template_.Template)
+	}
+	c.bound[namespace] = tmpl
+	return tmpl, nil
+}
+
+var cacheLock sync.RWMutex
+var cache map[string]*compiled = make(map[string]*compiled)
+
+func init() {
+	// recompile the configured templates whenever the config changes, dropping any that are no longer used
+	config.OnChange(CompileConfig)
+}
+
+// EventTypeName returns the name of the template used to create the content of events of the given namespace,
+// source and type
+func EventTypeName(namespace string, source string, eventType string) string {
+	if namespace == "" || namespace == config.DEFAULT_NAMESPACE {
+		return fmt.Sprintf("sources/%s/%s/template", source, eventType)
+	}
+	return fmt.Sprintf("namespaces/%s/sources/%s/%s/template", namespace, source, eventType)
+}
+
+// RouteName returns the name of a template used by the route, part being template, rule or args/<arg name>
+func RouteName(route string, part string) string {
+	return fmt.Sprintf("routes/%s/%s", route, part)
+}
+
+func compile(name string, text string) (*template_.Template, error) {
+	return template_.New(name).Funcs(funcs).Parse(text)
+}
+
+// CompileConfig compiles every template in the config, replacing the templates compiled for the previous config.
+func CompileConfig(config_ *config.ConnectrixConfig) {
+
+	texts := make(map[string]string)
+	for _, namespace := range config_.AllNamespaces() {
+		for _, source := range namespace.Sources {
+			for _, eventType := range source.Events {
+				texts[EventTypeName(namespace.Name, source.Name, eventType.Type)] = eventType.Template
+			}
+		}
+	}
+	for _, route := range config_.AllRoutes() {
+		texts[RouteName(route.Name, "template")] = route.Template
+		if rules.IsTemplated(route.Rule) {
+			texts[RouteName(route.Name, "rule")] = route.Rule
+		}
+		for key, val := range route.SubChannelArgs {
+			texts[RouteName(route.Name, "args/"+key)] = val
+		}
+	}
+
+	compiledTemplates := make(map[string]*compiled, len(texts))
+	for name, text := range texts {
+		if text == "" {
+			continue
+		}
+		tmpl, err := compile(name, text)
+		if err != nil {
+			// leave it out, Template will report the error when the template is used
+			glog.Warningf("Unable to compile template %s: %v", name, err)
+			continue
+		}
+		compiledTemplates[name] = &compiled{text: text, tmpl: tmpl}
+	}
+
+	cacheLock.Lock()
+	cache = compiledTemplates
+	cacheLock.Unlock()
+	glog.Debugf("Compiled %d template(s)", len(compiledTemplates))
+}
+
+// get returns the compiled template with the given name, compiling it if it hasn't been compiled yet or its text has changed
+func get(name string, text string) (*compiled, error) {
+
+	cacheLock.RLock()
+	entry, exists := cache[name]
+	cacheLock.RUnlock()
+	if exists && entry.text == text {
+		return entry, nil
+	}
+
+	tmpl, err := compile(name, text)
+	if err != nil {
+		return nil, err
+	}
+
+	entry = &compiled{text: text, tmpl: tmpl}
+	cacheLock.Lock()
+	cache[name] = entry
+	cacheLock.Unlock()
+	return entry, nil
+}
+
+// Template executes the named template against data on behalf of the namespace, which decides the credentials the
+// template can use. The template is compiled the first time it is used and reused after that for as long as its
+// text stays the same.
+func Template(data interface{}, namespace string, name string, template string) (string, error) {
+
+	entry, err := get(name, template)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := entry.forNamespace(namespace)
 	if err != nil {
 		return "", err
 	}
@@ -27,3 +160,9 @@ func Template(data interface{}, template string) (string, error) {
 
 	return outputString, nil
 }
+
+// Validate returns an error if the template doesn't compile.
+func Validate(template string) error {
+	_, err := compile("temp", template)
+	return err
+}
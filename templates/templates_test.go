@@ -1,6 +1,7 @@
 package templates
 
 import (
+	"github.com/diggs/connectrix/config"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
@@ -11,7 +12,81 @@ func TestParseJsonEvent(t *testing.T) {
 		Foo string
 	}
 
-	data, err := Template(test{Foo: "hello"}, "{{.Foo}}")
+	data, err := Template(test{Foo: "hello"}, config.DEFAULT_NAMESPACE, "test", "{{.Foo}}")
 	assert.Nil(t, err)
 	assert.Equal(t, "hello", data)
 }
+
+func TestTemplateRecompilesWhenTextChanges(t *testing.T) {
+
+	data, err := Template(nil, config.DEFAULT_NAMESPACE, "test/changes", "one")
+	assert.Nil(t, err)
+	assert.Equal(t, "one", data)
+
+	data, err = Template(nil, config.DEFAULT_NAMESPACE, "test/changes", "two")
+	assert.Nil(t, err)
+	assert.Equal(t, "two", data)
+}
+
+func TestErrorsNameTheTemplate(t *testing.T) {
+	_, err := Template(nil, config.DEFAULT_NAMESPACE, RouteName("push-to-irc", "rule"), "{{.ref")
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "routes/push-to-irc/rule")
+}
+
+func TestCompileConfig(t *testing.T) {
+
+	CompileConfig(&config.ConnectrixConfig{
+		Sources: []*config.EventSource{
+			&config.EventSource{Name: "GitHub", Events: []*config.EventType{&config.EventType{Type: "push", Template: "{{.ref}}"}}},
+		},
+		Routes: []*config.Route{
+			&config.Route{Name: "push-to-irc", Rule: "{{.ok}}", SubChannelArgs: map[string]string{"Nickname": "{{.bad"}},
+		},
+		Namespaces: []*config.Namespace{
+			&config.Namespace{
+				Name:    "team-a",
+				Sources: []*config.EventSource{&config.EventSource{Name: "GitHub", Events: []*config.EventType{&config.EventType{Type: "push", Template: "{{.after}}"}}}},
+				Routes:  []*config.Route{&config.Route{Name: "team-a-push", Template: "{{.ref}}"}},
+			},
+		},
+	})
+
+	assert.Contains(t, cache, EventTypeName(config.DEFAULT_NAMESPACE, "GitHub", "push"))
+	assert.Contains(t, cache, EventTypeName("team-a", "GitHub", "push"))
+	assert.Contains(t, cache, RouteName("team-a-push", "template"))
+	assert.Contains(t, cache, RouteName("push-to-irc", "rule"))
+	assert.NotContains(t, cache, RouteName("push-to-irc", "template"))
+	assert.NotContains(t, cache, RouteName("push-to-irc", "args/Nickname"))
+	assert.NotContains(t, cache, "test/changes")
+}
+
+func TestCredentials(t *testing.T) {
+
+	credential := credentialFunc(map[string]string{"jira_token": "secret"})
+	value, err := credential("jira_token")
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", value)
+	_, err = credential("missing")
+	assert.NotNil(t, err)
+
+	// namespaces that don't exist have no credentials
+	_, err = Template(nil, "missing", "test/credential", `{{credential "jira_token"}}`)
+	assert.NotNil(t, err)
+}
+
+func TestBindsEachNamespaceOnce(t *testing.T) {
+
+	entry, err := get("test/bound", "{{.}}")
+	assert.Nil(t, err)
+	first, err := entry.forNamespace("team-a")
+	assert.Nil(t, err)
+	again, err := entry.forNamespace("team-a")
+	assert.Nil(t, err)
+	other, err := entry.forNamespace("team-b")
+	assert.Nil(t, err)
+
+	assert.True(t, first == again)
+	assert.False(t, first == other)
+	assert.False(t, first == entry.tmpl)
+}
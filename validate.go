@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/validation"
+)
+
+// validate checks a config file without starting Connectrix, printing every problem found.
+// Returns the exit code for the process.
+func validate(args []string) int {
+
+	path := config.Path()
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	problems := validation.ValidateFile(path)
+	for _, problem := range problems {
+		fmt.Println(problem.String())
+	}
+
+	if len(problems) > 0 {
+		fmt.Printf("%s has %d problem(s)\n", path, len(problems))
+		return 1
+	}
+
+	fmt.Printf("%s is valid\n", path)
+	return 0
+}
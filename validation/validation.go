@@ -0,0 +1,363 @@
+package validation
+
+import (
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/channels"
+	"github.com/diggs/connectrix/config"
+	"github.com/diggs/connectrix/parsers"
+	"github.com/diggs/connectrix/rules"
+	"github.com/diggs/connectrix/templates"
+	"github.com/diggs/connectrix/verification"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Problem describes something wrong with the config, along with the JSON path to where the problem is
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p *Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// problems collects the problems found while validating a config
+type problems []*Problem
+
+func (p *problems) add(path string, format string, args ...interface{}) {
+	*p = append(*p, &Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// ValidateFile loads the config file at path and validates it, reporting a problem at the root of
+// the document if the file can't be loaded at all.
+func ValidateFile(path string) []*Problem {
+	config_, err := config.Load(path)
+	if err != nil {
+		return []*Problem{&Problem{Path: "$", Message: err.Error()}}
+	}
+	return Validate(config_)
+}
+
+// Check can be registered via config.AddValidator to stop an invalid config from replacing the current one.
+func Check(config_ *config.ConnectrixConfig) error {
+	found := Validate(config_)
+	if len(found) == 0 {
+		return nil
+	}
+	messages := make([]string, len(found))
+	for i, problem := range found {
+		messages[i] = problem.String()
+	}
+	return errors.New(fmt.Sprintf("Config is invalid:\n %s", strings.Join(messages, "\n ")))
+}
+
+// Validate returns every problem found in the config. Channels must have been registered with the
+// channels package for channel names and args to be validated.
+func Validate(config_ *config.ConnectrixConfig) []*Problem {
+
+	found := problems{}
+	routeNames := make(map[string]bool)
+	default_ := &scope{namespace: config_.Namespace(config.DEFAULT_NAMESPACE)}
+	validateScope(default_, routeNames, &found)
+	validateNamespaces(config_, routeNames, &found)
+	validateClaims(config_, &found)
+	validateParsers(config_, &found)
+	return found
+}
+
+// validateClaims checks that sources in different namespaces don't make the same claim on a publish channel, e.g.
+// use the same email recipient, as the events would all be created in one of the namespaces
+func validateClaims(config_ *config.ConnectrixConfig, found *problems) {
+
+	claimed := make(map[string]string)
+	check := func(path string, namespace string, sources []*config.EventSource) {
+		for i, source := range sources {
+			if source.PubChannelName == "" {
+				continue
+			}
+			claim := channels.PubChannelClaim(source.PubChannelName, source.PubChannelArgs)
+			if claim == "" {
+				continue
+			}
+			key := source.PubChannelName + ":" + claim
+			if existing, exists := claimed[key]; exists && existing != namespace {
+				found.add(fmt.Sprintf("%ssources[%d].pub_channel_args", path, i), "The %s is already used by namespace '%s', it can only be used by one namespace", claim, existing)
+				continue
+			}
+			claimed[key] = namespace
+		}
+	}
+
+	check("", config.DEFAULT_NAMESPACE, config_.Sources)
+	for i, namespace := range config_.Namespaces {
+		check(fmt.Sprintf("namespaces[%d].", i), namespace.Name, namespace.Sources)
+	}
+}
+
+// validateParsers checks the options given in the parsers section are for registered parsers that accept them
+func validateParsers(config_ *config.ConnectrixConfig, found *problems) {
+	for parserName, options := range config_.Parsers {
+		if err := parsers.ValidateOptions(parserName, options); err != nil {
+			found.add("parsers."+parserName, "%s", err.Error())
+		}
+	}
+}
+
+// scope is the namespace being validated along with the path to it, which is empty for the default namespace
+type scope struct {
+	path      string
+	namespace *config.Namespace
+	// namedArgs is the channel each of the namespace's named args belongs to
+	namedArgs map[string]string
+}
+
+func validateScope(s *scope, routeNames map[string]bool, found *problems) {
+	s.namedArgs = validateChannels(s, found)
+	validateSources(s, found)
+	validateRoutes(s, routeNames, found)
+}
+
+func validateNamespaces(config_ *config.ConnectrixConfig, routeNames map[string]bool, found *problems) {
+
+	names := make(map[string]bool)
+	for i, namespace := range config_.Namespaces {
+		path := fmt.Sprintf("namespaces[%d]", i)
+
+		switch {
+		case namespace.Name == "":
+			found.add(path+".name", "A namespace name is required")
+		case namespace.Name == config.DEFAULT_NAMESPACE:
+			found.add(path+".name", "Namespace '%s' is the default namespace, use the top level sources and routes instead", namespace.Name)
+		case strings.Contains(namespace.Name, "/"):
+			found.add(path+".name", "Namespace names can't contain '/'")
+		case names[namespace.Name]:
+			found.add(path+".name", "Namespace '%s' is defined more than once", namespace.Name)
+		}
+		names[namespace.Name] = true
+
+		validateScope(&scope{path: path + ".", namespace: namespace}, routeNames, found)
+	}
+}
+
+func isKnownChannel(channelName string) bool {
+	_, pubErr := channels.GetPubChannel(channelName)
+	_, subErr := channels.GetSubChannel(channelName)
+	return pubErr == nil || subErr == nil
+}
+
+// validateChannels checks the channels section and returns the channel each named arg belongs to
+func validateChannels(s *scope, found *problems) map[string]string {
+
+	namedArgs := make(map[string]string)
+	for channelName, channel := range s.namespace.Channels {
+		path := fmt.Sprintf("%schannels.%s", s.path, channelName)
+		if !isKnownChannel(channelName) {
+			found.add(path, "Unknown channel '%s'", channelName)
+		}
+		if s.path != "" && len(channel.Config) > 0 {
+			found.add(path+".config", "Channel config can only be set at the top level, namespaces can only add named args")
+		}
+		for name := range channel.NamedArgs {
+			if otherChannel, exists := namedArgs[name]; exists {
+				found.add(fmt.Sprintf("%s.named_args.%s", path, name), "Named args '%s' are also defined for channel '%s'", name, otherChannel)
+			}
+			namedArgs[name] = channelName
+		}
+	}
+	return namedArgs
+}
+
+func validateTemplate(path string, template string, found *problems) {
+	if template == "" {
+		return
+	}
+	err := templates.Validate(template)
+	if err != nil {
+		found.add(path, "Template does not compile: %s", err.Error())
+	}
+}
+
+func validateRule(path string, rule string, found *problems) {
+	if rule == "" || rules.IsTemplated(rule) {
+		validateTemplate(path, rule, found)
+		return
+	}
+	_, err := rules.Compile(rule)
+	if err != nil {
+		found.add(path, "Rule does not compile: %s", err.Error())
+	}
+}
+
+// resolveArgsPath returns the path to the channel args being used, which is the named args definition when the args
+// come from named args. Named args that don't exist are reported as a problem.
+func resolveArgsPath(argsPath string, namedArgsPath string, namedArgsName string, s *scope, found *problems) string {
+	if namedArgsName == "" {
+		return argsPath
+	}
+	channelName, exists := s.namedArgs[namedArgsName]
+	if !exists {
+		found.add(namedArgsPath, "Unknown named args '%s'", namedArgsName)
+		return namedArgsPath
+	}
+	return fmt.Sprintf("%schannels.%s.named_args.%s", s.path, channelName, namedArgsName)
+}
+
+func validateMatch(path string, match *config.Match, found *problems) {
+	if match == nil {
+		return
+	}
+	if len(match.All) == 0 && len(match.Any) == 0 {
+		found.add(path, "A match needs at least one matcher in all or any")
+	}
+	validateMatchers := func(name string, matchers []*config.Matcher) {
+		for i, matcher := range matchers {
+			err := parsers.ValidateMatcher(matcher)
+			if err != nil {
+				found.add(fmt.Sprintf("%s.%s[%d]", path, name, i), "%s", err.Error())
+			}
+		}
+	}
+	validateMatchers("all", match.All)
+	validateMatchers("any", match.Any)
+}
+
+func validateSources(s *scope, found *problems) {
+
+	names := make(map[string]bool)
+	for i, source := range s.namespace.Sources {
+		path := fmt.Sprintf("%ssources[%d]", s.path, i)
+
+		if source.Name == "" {
+			found.add(path+".name", "A source name is required")
+		} else if names[source.Name] {
+			found.add(path+".name", "Source '%s' is defined more than once", source.Name)
+		}
+		names[source.Name] = true
+
+		if source.Parser != "" {
+			err := parsers.ValidateParser(source.Parser)
+			if err != nil {
+				found.add(path+".parser", "%s", err.Error())
+			}
+		}
+		if err := parsers.ValidateContentTypes(source.ContentTypes); err != nil {
+			found.add(path+".content_types", "%s", err.Error())
+		}
+		for parserName, options := range source.ParserOptions {
+			if err := parsers.ValidateOptions(parserName, options); err != nil {
+				found.add(fmt.Sprintf("%s.parser_options.%s", path, parserName), "%s", err.Error())
+			}
+		}
+
+		validateMatch(path+".match", source.Match, found)
+
+		if source.Verify != nil {
+			err := verification.Validate(source.Verify)
+			if err != nil {
+				found.add(path+".verify", "%s", err.Error())
+			}
+		}
+
+		argsPath := resolveArgsPath(path+".pub_channel_args", path+".named_args", source.NamedArgs, s, found)
+
+		if source.PubChannelName != "" {
+			if _, err := channels.GetPubChannel(source.PubChannelName); err != nil {
+				found.add(path+".pub_channel_name", "Unknown publish channel '%s'", source.PubChannelName)
+			} else if err := channels.ValidatePubChannelArgs(source.PubChannelName, s.namespace.Name, source.PubChannelArgs); err != nil {
+				found.add(argsPath, "Invalid args for channel '%s': %s", source.PubChannelName, err.Error())
+			}
+		}
+
+		types := make(map[string]bool)
+		for j, eventType := range source.Events {
+			typePath := fmt.Sprintf("%s.events[%d]", path, j)
+			if eventType.Type == "" {
+				found.add(typePath+".type", "An event type is required")
+			} else if types[eventType.Type] {
+				found.add(typePath+".type", "Event type '%s' is defined more than once", eventType.Type)
+			}
+			types[eventType.Type] = true
+			validateMatch(typePath+".match", eventType.Match, found)
+			if eventType.Rule != "" {
+				if _, err := rules.Compile(eventType.Rule); err != nil {
+					found.add(typePath+".rule", "Rule does not compile: %s", err.Error())
+				}
+			}
+			validateTemplate(typePath+".template", eventType.Template, found)
+		}
+	}
+}
+
+func findEventType(namespace *config.Namespace, sourceName string, eventType string) (bool, bool) {
+	for _, source := range namespace.Sources {
+		if source.Name == sourceName {
+			for _, type_ := range source.Events {
+				if type_.Type == eventType {
+					return true, true
+				}
+			}
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// validateRoutes checks the routes of the namespace. Route names are unique across every namespace.
+func validateRoutes(s *scope, names map[string]bool, found *problems) {
+
+	for i, route := range s.namespace.Routes {
+		path := fmt.Sprintf("%sroutes[%d]", s.path, i)
+
+		if names[route.Name] {
+			found.add(path+".name", "Route '%s' is defined more than once", route.Name)
+		}
+		names[route.Name] = true
+
+		// routes only ever match events in their own namespace
+		if route.Namespace != "" && route.Namespace != s.namespace.Name {
+			found.add(path+".namespace", "Route is in namespace '%s' but is for namespace '%s', move it to that namespace's routes", s.namespace.Name, route.Namespace)
+		}
+
+		sourceExists, typeExists := findEventType(s.namespace, route.EventSource, route.EventType)
+		if !sourceExists {
+			found.add(path+".event_source", "Unknown event source '%s'", route.EventSource)
+		} else if !typeExists {
+			found.add(path+".event_type", "Unknown event type '%s' for source '%s'", route.EventType, route.EventSource)
+		}
+
+		argsPath := resolveArgsPath(path+".sub_channel_args", path+".named_args", route.NamedArgs, s, found)
+
+		if route.SubChannelName == "" {
+			if route.NamedArgs == "" {
+				found.add(path+".sub_channel_name", "A sub channel name or named args are required")
+			}
+		} else if _, err := channels.GetSubChannel(route.SubChannelName); err != nil {
+			found.add(path+".sub_channel_name", "Unknown subscription channel '%s'", route.SubChannelName)
+		} else if err := channels.ValidateSubChannelArgs(route.SubChannelName, route.SubChannelArgs); err != nil {
+			found.add(argsPath, "Invalid args for channel '%s': %s", route.SubChannelName, err.Error())
+		}
+
+		keys := make([]string, 0, len(route.SubChannelArgs))
+		for key := range route.SubChannelArgs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			validateTemplate(fmt.Sprintf("%s.%s", argsPath, key), route.SubChannelArgs[key], found)
+		}
+		validateTemplate(path+".template", route.Template, found)
+		validateRule(path+".rule", route.Rule, found)
+
+		if route.MaxAttempts < 0 {
+			found.add(path+".max_attempts", "max_attempts can't be negative")
+		}
+		if route.RetryBackoff != "" {
+			if _, err := time.ParseDuration(route.RetryBackoff); err != nil {
+				found.add(path+".retry_backoff", "Invalid duration: %s", err.Error())
+			}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package validation
+
+import (
+	_ "github.com/diggs/connectrix/channels/http"
+	_ "github.com/diggs/connectrix/channels/irc"
+	_ "github.com/diggs/connectrix/channels/smtp"
+	"github.com/diggs/connectrix/config"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func validConfig() *config.ConnectrixConfig {
+	return &config.ConnectrixConfig{
+		Channels: map[string]config.Channel{
+			"irc": config.Channel{
+				NamedArgs: map[string]map[string]string{
+					"bot": {"IRC Server": "irc.freenode.net", "IRC Channel": "#connectrix", "Nickname": "bot"},
+				},
+			},
+		},
+		Sources: []*config.EventSource{
+			&config.EventSource{
+				Name:   "GitHub",
+				Parser: "json",
+				Events: []*config.EventType{&config.EventType{Type: "push", Template: "{{.pusher.name}}"}},
+			},
+		},
+		Routes: []*config.Route{
+			&config.Route{
+				Name:           "push-to-http",
+				EventSource:    "GitHub",
+				EventType:      "push",
+				SubChannelName: "http",
+				SubChannelArgs: map[string]string{"URL": "http://example.com/{{.repository.name}}"},
+			},
+			&config.Route{
+				Name:        "push-to-irc",
+				EventSource: "GitHub",
+				EventType:   "push",
+				NamedArgs:   "bot",
+			},
+		},
+	}
+}
+
+func paths(problems []*Problem) []string {
+	paths := []string{}
+	for _, problem := range problems {
+		paths = append(paths, problem.Path)
+	}
+	return paths
+}
+
+func TestValidConfigHasNoProblems(t *testing.T) {
+	config_ := validConfig()
+	assert.Empty(t, Validate(config_))
+	assert.Nil(t, Check(config_))
+}
+
+func TestReportsEveryProblemWithPath(t *testing.T) {
+
+	config_ := validConfig()
+	config_.Sources[0].Parser = "jsn"
+	config_.Sources[0].Events[0].Template = "{{.pusher.name"
+	config_.Sources[0].Events[0].Rule = "{{.ref}} == master"
+	config_.Sources[0].Verify = &config.Verification{Scheme: "github"}
+	config_.Sources[0].Match = &config.Match{Any: []*config.Matcher{
+		&config.Matcher{Key: "header:User-Agent", Op: "prefix", Value: "GitHub-Hookshot/"},
+		&config.Matcher{Key: "header:User-Agent", Op: "regex", Value: "("},
+	}}
+	config_.Routes[0].SubChannelArgs["URL"] = "not a url"
+	config_.Routes[0].Rule = "{{.ref"
+	config_.Routes[0].RetryBackoff = "soon"
+	config_.Routes[1].NamedArgs = "missing"
+	config_.Routes[1].EventType = "pull"
+	config_.Routes[1].Rule = "repository.name =="
+	config_.Routes = append(config_.Routes, &config.Route{
+		Name:           "unknown-channel",
+		EventSource:    "GitLab",
+		SubChannelName: "carrier-pigeon",
+	})
+
+	found := paths(Validate(config_))
+	assert.Contains(t, found, "sources[0].parser")
+	assert.Contains(t, found, "sources[0].match.any[1]")
+	assert.Contains(t, found, "sources[0].verify")
+	assert.Contains(t, found, "sources[0].events[0].template")
+	assert.Contains(t, found, "sources[0].events[0].rule")
+	assert.Contains(t, found, "routes[0].sub_channel_args")
+	assert.Contains(t, found, "routes[0].rule")
+	assert.Contains(t, found, "routes[0].retry_backoff")
+	assert.Contains(t, found, "routes[1].named_args")
+	assert.Contains(t, found, "routes[1].event_type")
+	assert.Contains(t, found, "routes[1].rule")
+	assert.Contains(t, found, "routes[2].event_source")
+	assert.Contains(t, found, "routes[2].sub_channel_name")
+	assert.Len(t, found, 13)
+
+	assert.NotNil(t, Check(config_))
+}
+
+func TestValidatesNamespaces(t *testing.T) {
+
+	teamA := func() *config.Namespace {
+		return &config.Namespace{
+			Name: "team-a",
+			Sources: []*config.EventSource{
+				&config.EventSource{Name: "GitHub", Parser: "json", Events: []*config.EventType{&config.EventType{Type: "push"}}},
+			},
+			Routes: []*config.Route{
+				&config.Route{Name: "team-a-push", EventSource: "GitHub", EventType: "push", SubChannelName: "http", SubChannelArgs: map[string]string{"URL": "http://example.com"}},
+			},
+		}
+	}
+
+	config_ := validConfig()
+	config_.Namespaces = []*config.Namespace{teamA()}
+	assert.Empty(t, Validate(config_))
+
+	invalid := teamA()
+	invalid.Channels = map[string]config.Channel{"http": config.Channel{Config: map[string]string{"Port": "8080"}}}
+	invalid.Routes = append(invalid.Routes,
+		// named args and route names can't be shared between namespaces
+		&config.Route{Name: "push-to-irc", EventSource: "GitHub", EventType: "push", NamedArgs: "bot"},
+		&config.Route{Name: "team-b-push", Namespace: "team-b", EventSource: "GitHub", EventType: "push", SubChannelName: "http", SubChannelArgs: map[string]string{"URL": "http://example.com"}},
+	)
+	config_.Namespaces = []*config.Namespace{invalid, teamA(), &config.Namespace{Name: "0"}}
+	config_.Routes[0].Namespace = "team-a"
+
+	found := paths(Validate(config_))
+	assert.Contains(t, found, "routes[0].namespace")
+	assert.Contains(t, found, "namespaces[0].channels.http.config")
+	assert.Contains(t, found, "namespaces[0].routes[1].name")
+	assert.Contains(t, found, "namespaces[0].routes[1].named_args")
+	assert.Contains(t, found, "namespaces[0].routes[2].namespace")
+	assert.Contains(t, found, "namespaces[1].name")
+	assert.Contains(t, found, "namespaces[1].routes[0].name")
+	assert.Contains(t, found, "namespaces[2].name")
+	assert.Len(t, found, 8)
+}
+
+func TestClaimsCantBeSharedBetweenNamespaces(t *testing.T) {
+
+	source := func(name string, recipient string) *config.EventSource {
+		return &config.EventSource{
+			Name:           name,
+			PubChannelName: "smtp",
+			PubChannelArgs: map[string]string{"Recipient": recipient},
+			Events:         []*config.EventType{&config.EventType{Type: "alert"}},
+		}
+	}
+
+	config_ := validConfig()
+	config_.Sources = append(config_.Sources, source("Alerts", "alerts@example.com"), source("Backups", "alerts@example.com"))
+	config_.Namespaces = []*config.Namespace{
+		&config.Namespace{Name: "team-a", Sources: []*config.EventSource{source("Alerts", "team-a@example.com")}},
+	}
+	// sources in the same namespace can share a recipient
+	assert.Empty(t, Validate(config_))
+
+	config_.Namespaces = append(config_.Namespaces,
+		&config.Namespace{Name: "team-b", Sources: []*config.EventSource{source("Alerts", "Alerts@Example.com")}})
+	found := Validate(config_)
+	assert.Equal(t, []string{"namespaces[1].sources[0].pub_channel_args"}, paths(found))
+	assert.Contains(t, found[0].Message, "recipient alerts@example.com is already used by namespace '0'")
+}
+
+func TestValidatesParserOptions(t *testing.T) {
+
+	config_ := validConfig()
+	config_.Parsers = map[string]map[string]string{
+		"csv":  {"delimiter": ";"},
+		"json": {"strict": "true"},
+	}
+	config_.Sources[0].ParserOptions = map[string]map[string]string{
+		"xml": {"attribute_prefix": "-"},
+		"csv": {"delimiter": "::"},
+	}
+
+	found := paths(Validate(config_))
+	assert.Equal(t, []string{"sources[0].parser_options.csv", "parsers.json"}, found)
+}
@@ -0,0 +1,155 @@
+package verification
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/diggs/connectrix/config"
+	"hash"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SCHEME_GITHUB string = "github"
+	SCHEME_HMAC   string = "hmac"
+	SCHEME_SLACK  string = "slack"
+	SCHEME_TOKEN  string = "token"
+
+	GITHUB_SIGNATURE_HEADER string = "X-Hub-Signature-256"
+	SLACK_SIGNATURE_HEADER  string = "X-Slack-Signature"
+	SLACK_TIMESTAMP_HEADER  string = "X-Slack-Request-Timestamp"
+	DEFAULT_TOKEN_HEADER    string = "X-Connectrix-Token"
+
+	DEFAULT_SLACK_TOLERANCE time.Duration = 5 * time.Minute
+)
+
+var hashes = map[string]func() hash.Hash{
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// Validate returns an error if the verification config is incomplete or uses an unknown scheme.
+func Validate(verification *config.Verification) error {
+
+	if verification.Secret == "" {
+		return errors.New("A secret is required")
+	}
+
+	switch verification.Scheme {
+	case SCHEME_GITHUB, SCHEME_TOKEN:
+	case SCHEME_HMAC:
+		if verification.Header == "" {
+			return errors.New("The hmac scheme needs the header the signature is sent in")
+		}
+		if _, exists := hashes[strings.ToLower(verification.Algorithm)]; verification.Algorithm != "" && !exists {
+			return errors.New(fmt.Sprintf("Unknown algorithm '%s', expected sha1, sha256 or sha512", verification.Algorithm))
+		}
+		if encoding := strings.ToLower(verification.Encoding); encoding != "" && encoding != "hex" && encoding != "base64" {
+			return errors.New(fmt.Sprintf("Unknown encoding '%s', expected hex or base64", verification.Encoding))
+		}
+	case SCHEME_SLACK:
+		if verification.Tolerance != "" {
+			if _, err := time.ParseDuration(verification.Tolerance); err != nil {
+				return errors.New(fmt.Sprintf("Invalid tolerance: %v", err))
+			}
+		}
+	default:
+		return errors.New(fmt.Sprintf("Unknown verification scheme '%s', expected %s, %s, %s or %s", verification.Scheme, SCHEME_GITHUB, SCHEME_HMAC, SCHEME_SLACK, SCHEME_TOKEN))
+	}
+
+	return nil
+}
+
+// Verify checks the request headers and body, returning why the request failed verification or an empty string
+// if it passed
+func Verify(verification *config.Verification, header http.Header, body []byte, now time.Time) string {
+
+	switch verification.Scheme {
+	case SCHEME_GITHUB:
+		return checkSignature(header.Get(GITHUB_SIGNATURE_HEADER), "sha256=", sign(sha256.New, verification.Secret, body), hex.DecodeString)
+
+	case SCHEME_HMAC:
+		algorithm := verification.Algorithm
+		if algorithm == "" {
+			algorithm = "sha256"
+		}
+		newHash, exists := hashes[strings.ToLower(algorithm)]
+		if !exists {
+			return fmt.Sprintf("unknown algorithm %s", algorithm)
+		}
+		decode := hex.DecodeString
+		if strings.ToLower(verification.Encoding) == "base64" {
+			decode = base64.StdEncoding.DecodeString
+		}
+		return checkSignature(header.Get(verification.Header), verification.Prefix, sign(newHash, verification.Secret, body), decode)
+
+	case SCHEME_SLACK:
+		timestamp := header.Get(SLACK_TIMESTAMP_HEADER)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return fmt.Sprintf("missing or invalid %s header", SLACK_TIMESTAMP_HEADER)
+		}
+		tolerance := DEFAULT_SLACK_TOLERANCE
+		if verification.Tolerance != "" {
+			tolerance, _ = time.ParseDuration(verification.Tolerance)
+		}
+		// reject old requests so that a captured request can't be replayed
+		if math.Abs(now.Sub(time.Unix(seconds, 0)).Seconds()) > tolerance.Seconds() {
+			return "request timestamp is too old"
+		}
+		signed := append([]byte(fmt.Sprintf("v0:%s:", timestamp)), body...)
+		return checkSignature(header.Get(SLACK_SIGNATURE_HEADER), "v0=", sign(sha256.New, verification.Secret, signed), hex.DecodeString)
+
+	case SCHEME_TOKEN:
+		headerName := verification.Header
+		if headerName == "" {
+			headerName = DEFAULT_TOKEN_HEADER
+		}
+		token := header.Get(headerName)
+		if token == "" {
+			return fmt.Sprintf("missing %s header", headerName)
+		}
+		if subtle.ConstantTimeCompare([]byte(token), []byte(verification.Secret)) != 1 {
+			return "token does not match"
+		}
+		return ""
+	}
+
+	return fmt.Sprintf("unknown verification scheme %s", verification.Scheme)
+}
+
+func sign(newHash func() hash.Hash, secret string, data []byte) []byte {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// checkSignature decodes the signature sent with the request and compares it to the expected signature in constant
+// time, so upper and lower case hex are both accepted
+func checkSignature(sent string, prefix string, expected []byte, decode func(string) ([]byte, error)) string {
+	if sent == "" {
+		return "missing signature"
+	}
+	if !strings.HasPrefix(sent, prefix) {
+		return fmt.Sprintf("signature should start with %s", prefix)
+	}
+	signature, err := decode(strings.TrimPrefix(sent, prefix))
+	if err != nil {
+		return "signature is not encoded correctly"
+	}
+	if !hmac.Equal(signature, expected) {
+		return "signature does not match"
+	}
+	return ""
+}
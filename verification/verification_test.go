@@ -0,0 +1,82 @@
+package verification
+
+import (
+	"github.com/diggs/connectrix/config"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+var verifyBody = []byte(`{"ref":"refs/heads/master"}`)
+
+func TestVerifyGitHub(t *testing.T) {
+
+	verification := &config.Verification{Scheme: SCHEME_GITHUB, Secret: "It's a Secret to Everybody"}
+
+	header := http.Header{}
+	header.Set(GITHUB_SIGNATURE_HEADER, "sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17")
+	assert.Empty(t, Verify(verification, header, []byte("Hello, World!"), time.Now()))
+
+	assert.Equal(t, "signature does not match", Verify(verification, header, verifyBody, time.Now()))
+	assert.Equal(t, "missing signature", Verify(verification, http.Header{}, verifyBody, time.Now()))
+
+	// the signature is compared as bytes, so upper case hex is fine
+	header.Set(GITHUB_SIGNATURE_HEADER, "sha256=757107EA0EB2509FC211221CCE984B8A37570B6D7586C22C46F4379C8B043E17")
+	assert.Empty(t, Verify(verification, header, []byte("Hello, World!"), time.Now()))
+	header.Set(GITHUB_SIGNATURE_HEADER, "sha256=not hex")
+	assert.Equal(t, "signature is not encoded correctly", Verify(verification, header, []byte("Hello, World!"), time.Now()))
+}
+
+func TestVerifyHmac(t *testing.T) {
+
+	verification := &config.Verification{Scheme: SCHEME_HMAC, Secret: "secret", Header: "X-Signature", Algorithm: "sha1", Encoding: "base64"}
+
+	header := http.Header{}
+	header.Set("X-Signature", "rLC+VC59CA5+AlO/qojF/JXij+I=")
+	assert.Empty(t, Verify(verification, header, verifyBody, time.Now()))
+
+	verification.Prefix = "sha1="
+	assert.Equal(t, "signature should start with sha1=", Verify(verification, header, verifyBody, time.Now()))
+}
+
+func TestVerifySlack(t *testing.T) {
+
+	verification := &config.Verification{Scheme: SCHEME_SLACK, Secret: "secret"}
+	now := time.Unix(1437825600, 0)
+
+	header := http.Header{}
+	header.Set(SLACK_TIMESTAMP_HEADER, "1437825600")
+	header.Set(SLACK_SIGNATURE_HEADER, "v0=c7e3de0af4d8174458373288058c9593840b8f24b2a23c718f7ad71dd0c0c6d7")
+	assert.Empty(t, Verify(verification, header, verifyBody, now))
+
+	assert.Equal(t, "request timestamp is too old", Verify(verification, header, verifyBody, now.Add(10*time.Minute)))
+	verification.Tolerance = "1h"
+	assert.Empty(t, Verify(verification, header, verifyBody, now.Add(10*time.Minute)))
+
+	header.Set(SLACK_TIMESTAMP_HEADER, "1437825601")
+	assert.Equal(t, "signature does not match", Verify(verification, header, verifyBody, now))
+}
+
+func TestVerifyToken(t *testing.T) {
+
+	verification := &config.Verification{Scheme: SCHEME_TOKEN, Secret: "secret"}
+
+	header := http.Header{}
+	header.Set(DEFAULT_TOKEN_HEADER, "secret")
+	assert.Empty(t, Verify(verification, header, verifyBody, time.Now()))
+
+	verification.Header = "X-Gitlab-Token"
+	assert.Equal(t, "missing X-Gitlab-Token header", Verify(verification, header, verifyBody, time.Now()))
+	header.Set("X-Gitlab-Token", "guess")
+	assert.Equal(t, "token does not match", Verify(verification, header, verifyBody, time.Now()))
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate(&config.Verification{Scheme: SCHEME_GITHUB, Secret: "secret"}))
+	assert.NotNil(t, Validate(&config.Verification{Scheme: SCHEME_GITHUB}))
+	assert.NotNil(t, Validate(&config.Verification{Scheme: "md5", Secret: "secret"}))
+	assert.NotNil(t, Validate(&config.Verification{Scheme: SCHEME_HMAC, Secret: "secret"}))
+	assert.NotNil(t, Validate(&config.Verification{Scheme: SCHEME_HMAC, Secret: "secret", Header: "X-Signature", Algorithm: "md5"}))
+	assert.NotNil(t, Validate(&config.Verification{Scheme: SCHEME_SLACK, Secret: "secret", Tolerance: "soon"}))
+}